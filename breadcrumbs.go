@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxBreadcrumbs bounds the trail kept per page, in case a page marks up a whole menu as breadcrumbs
+const maxBreadcrumbs = 10
+
+// extractBreadcrumbs returns the page's position in the site hierarchy, root first, from a
+// BreadcrumbList in JSON-LD or else from a <nav aria-label="breadcrumb"> element
+func extractBreadcrumbs(doc *goquery.Document) []string {
+	if trail := jsonLDBreadcrumbs(doc); len(trail) > 0 {
+		return trail
+	}
+	return navBreadcrumbs(doc)
+}
+
+// jsonLDBreadcrumbs reads the first BreadcrumbList entity, ordering its items by position
+func jsonLDBreadcrumbs(doc *goquery.Document) []string {
+	var trail []string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var block interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &block); err != nil {
+			return true
+		}
+		for _, entity := range jsonLDEntities(block) {
+			if jsonLDType(entity) != "BreadcrumbList" {
+				continue
+			}
+			if trail = breadcrumbListItems(entity); len(trail) > 0 {
+				return false
+			}
+		}
+		return true
+	})
+	return trail
+}
+
+func breadcrumbListItems(list map[string]interface{}) []string {
+	elements, _ := list["itemListElement"].([]interface{})
+	type crumb struct {
+		position float64
+		name     string
+	}
+	var crumbs []crumb
+	for i, element := range elements {
+		item, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := item["name"].(string)
+		if nested, ok := item["item"].(map[string]interface{}); ok && name == "" {
+			name, _ = nested["name"].(string)
+		}
+		position, ok := item["position"].(float64)
+		if !ok {
+			position = float64(i + 1)
+		}
+		if name = allWhitespace.ReplaceAllString(strings.TrimSpace(name), " "); name != "" {
+			crumbs = append(crumbs, crumb{position: position, name: name})
+		}
+	}
+	sort.SliceStable(crumbs, func(i, j int) bool { return crumbs[i].position < crumbs[j].position })
+
+	var trail []string
+	for _, c := range crumbs {
+		trail = append(trail, c.name)
+	}
+	return limitBreadcrumbs(trail)
+}
+
+// navBreadcrumbs reads the list items, or else the links, of a nav labelled as breadcrumbs
+func navBreadcrumbs(doc *goquery.Document) []string {
+	var trail []string
+	doc.Find("nav[aria-label]").EachWithBreak(func(i int, nav *goquery.Selection) bool {
+		if !strings.Contains(strings.ToLower(nav.AttrOr("aria-label", "")), "breadcrumb") {
+			return true
+		}
+		items := nav.Find("li")
+		if items.Length() == 0 {
+			items = nav.Find("a")
+		}
+		items.Each(func(j int, item *goquery.Selection) {
+			if text := contactText(item); text != "" {
+				trail = append(trail, text)
+			}
+		})
+		return len(trail) == 0
+	})
+	return limitBreadcrumbs(trail)
+}
+
+func limitBreadcrumbs(trail []string) []string {
+	if len(trail) > maxBreadcrumbs {
+		return trail[:maxBreadcrumbs]
+	}
+	return trail
+}
+
+// formatBreadcrumbs renders a trail as "Products > Widgets > Blue Widget"
+func formatBreadcrumbs(trail []string) string {
+	return strings.Join(trail, " > ")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a map bounded to maxEntries that evicts the least recently used entry when a new
+// key doesn't fit. Expiry is left to the callers, which check the age of what get returns.
+type lruCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxEntries int        // 0 for unbounded
+	order      *list.List // Most recently used first
+	items      map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](maxEntries int) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[K]*list.Element),
+	}
+}
+
+// get returns the value stored for key and marks it as recently used
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.items[key]
+	if !exists {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry[K, V]).value, true
+}
+
+// put stores value for key as the most recently used entry, evicting the least recently used one
+// when the cache is full
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.items[key]; exists {
+		element.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+func (c *lruCache[K, V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// clear removes all entries
+func (c *lruCache[K, V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[K]*list.Element)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// cachedKeys returns which of keys are in the cache, without marking them as used
+func cachedKeys(c *lruCache[string, int], keys ...string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var present []string
+	for _, key := range keys {
+		if _, ok := c.items[key]; ok {
+			present = append(present, key)
+		}
+	}
+	return present
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRUCache[string, int](3)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.put("c", 3)
+	c.get("a") // b is now the least recently used
+
+	c.put("d", 4)
+	if got := fmt.Sprint(cachedKeys(c, "a", "b", "c", "d")); got != "[a c d]" {
+		t.Errorf("after adding d: cached %s, want [a c d]", got)
+	}
+
+	c.put("e", 5)
+	if got := fmt.Sprint(cachedKeys(c, "a", "b", "c", "d", "e")); got != "[a d e]" {
+		t.Errorf("after adding e: cached %s, want [a d e]", got)
+	}
+	if c.len() != 3 {
+		t.Errorf("len = %d, want 3", c.len())
+	}
+}
+
+func TestLRUCacheUpdatesExistingKey(t *testing.T) {
+	c := newLRUCache[string, int](2)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.put("a", 10) // Replaces the value and makes a the most recently used, without evicting
+
+	if c.len() != 2 {
+		t.Errorf("len = %d, want 2", c.len())
+	}
+	if value, ok := c.get("a"); !ok || value != 10 {
+		t.Errorf("get(a) = %d, %v, want 10, true", value, ok)
+	}
+
+	c.put("c", 3)
+	if got := fmt.Sprint(cachedKeys(c, "a", "b", "c")); got != "[a c]" {
+		t.Errorf("cached %s, want [a c]", got)
+	}
+}
+
+func TestLRUCacheUnbounded(t *testing.T) {
+	c := newLRUCache[string, int](0)
+	for i := 0; i < 1000; i++ {
+		c.put(fmt.Sprint(i), i)
+	}
+	if c.len() != 1000 {
+		t.Errorf("len = %d, want 1000", c.len())
+	}
+	if value, ok := c.get("0"); !ok || value != 0 {
+		t.Errorf("get(0) = %d, %v, want the first entry", value, ok)
+	}
+}
+
+func TestLRUCacheClear(t *testing.T) {
+	c := newLRUCache[string, int](2)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.clear()
+
+	if c.len() != 0 {
+		t.Errorf("len = %d after clear, want 0", c.len())
+	}
+	if _, ok := c.get("a"); ok {
+		t.Error("get(a) found an entry after clear")
+	}
+
+	// The cache stays usable and bounded
+	c.put("c", 3)
+	c.put("d", 4)
+	c.put("e", 5)
+	if got := fmt.Sprint(cachedKeys(c, "c", "d", "e")); got != "[d e]" {
+		t.Errorf("cached %s, want [d e]", got)
+	}
+}
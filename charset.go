@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+// decodeBody transcodes the body of a page response to UTF-8, see decodeHTML
+func decodeBody(resp *http.Response, raw []byte) (string, error) {
+	return decodeHTML(resp.Header.Get("Content-Type"), raw)
+}
+
+// decodeHTML transcodes an HTML document to UTF-8. The charset is taken from a byte order mark, the
+// Content-Type header (empty if unknown) or a <meta charset>/<meta http-equiv> tag in the first
+// 1024 bytes, in that order. Without a BOM or header, a document that is valid UTF-8 is kept as is;
+// otherwise the <meta> charset or, failing that, Windows-1252 is used as browsers do.
+func decodeHTML(contentType string, raw []byte) (string, error) {
+	encoding, name, certain := charset.DetermineEncoding(raw, contentType)
+	if name == "utf-8" || (!certain && utf8.Valid(raw)) {
+		return string(raw), nil
+	}
+
+	decoded, err := encoding.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s body: %w", name, err)
+	}
+	return string(decoded), nil
+}
+
+// readHTML reads a page response within MAX_DOWNLOAD_BYTES, transcodes it to UTF-8 and parses it
+func (w *WebScraper) readHTML(resp *http.Response, pageURL string) (*goquery.Document, error) {
+	body, err := limitBody(resp, pageURL, w.maxDownloadBytes)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := decodeBody(resp, raw)
+	if err != nil {
+		return nil, err
+	}
+	return goquery.NewDocumentFromReader(strings.NewReader(text))
+}
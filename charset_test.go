@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cyrillicWindows1251 is "Привет, мир" encoded as Windows-1251
+const cyrillicWindows1251 = "\xcf\xf0\xe8\xe2\xe5\xf2, \xec\xe8\xf0"
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:        "charset in Content-Type",
+			contentType: "text/html; charset=windows-1251",
+			body:        "<p>" + cyrillicWindows1251 + "</p>",
+			want:        "<p>Привет, мир</p>",
+		},
+		{
+			name:        "charset in meta tag",
+			contentType: "text/html",
+			body:        `<meta charset="windows-1251"><p>` + cyrillicWindows1251 + "</p>",
+			want:        `<meta charset="windows-1251"><p>Привет, мир</p>`,
+		},
+		{
+			name:        "Content-Type wins over meta tag",
+			contentType: "text/html; charset=windows-1251",
+			body:        `<meta charset="iso-8859-1"><p>` + cyrillicWindows1251 + "</p>",
+			want:        `<meta charset="iso-8859-1"><p>Привет, мир</p>`,
+		},
+		{
+			name: "unlabeled UTF-8 is kept",
+			body: "<p>Привет, мир</p>",
+			want: "<p>Привет, мир</p>",
+		},
+		{
+			name: "unlabeled non-UTF-8 falls back to Windows-1252",
+			body: "<p>Caf\xe9</p>",
+			want: "<p>Café</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+			got, err := decodeBody(resp, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("decodeBody: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapeWindows1251Page(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		fmt.Fprintf(rw, "<html><head><title>%s</title></head><body><p>%s</p></body></html>", cyrillicWindows1251, cyrillicWindows1251)
+	}))
+	defer server.Close()
+
+	content, err := newTestScraper(t).ScrapeWebsite(server.URL)
+	if err != nil {
+		t.Fatalf("ScrapeWebsite: %v", err)
+	}
+	if content.Title != "Привет, мир" {
+		t.Errorf("title = %q, want %q", content.Title, "Привет, мир")
+	}
+	if !strings.Contains(content.Text, "Привет, мир") {
+		t.Errorf("text = %q, want the transcoded Cyrillic text", content.Text)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,77 +16,361 @@ type Chatbot struct {
 	scraper       *WebScraper
 	ollamaService *OllamaService
 	websiteURL    string
-	websiteData   *WebsiteContent
-	lastDataFetch time.Time
+	websiteData   *WebsiteContent // Guarded by dataMu, replaced as a whole on refresh
+	lastDataFetch time.Time       // Guarded by dataMu
+	dataMu        sync.RWMutex
+	scrapeMu      sync.Mutex // Serializes scraping between chat requests and background refreshes
+	autoRefresh   bool
+	staleAfter    time.Duration
+	noContentMsg  string
+	snippetCount  int
+	conversations *conversationStore
+
+	// Lifetime counters for GET /stats
+	llmAnswers      atomic.Int64
+	fallbackAnswers atomic.Int64
+	ollamaFailures  atomic.Int64
 }
 
 type ChatMessage struct {
-	Message   string    `json:"message"`
-	Response  string    `json:"response"`
-	Timestamp time.Time `json:"timestamp"`
+	Message          string        `json:"message"`
+	Response         string        `json:"response"`
+	Timestamp        time.Time     `json:"timestamp"`
+	ContentUpdatedAt time.Time     `json:"content_updated_at"`
+	ContentHash      string        `json:"content_hash,omitempty"`
+	Stats            *RequestStats `json:"stats,omitempty"`
+	Snippets         []Snippet     `json:"snippets,omitempty"`
 }
 
 func NewChatbot(scraper *WebScraper, ollamaService *OllamaService) *Chatbot {
 	websiteURL := os.Getenv("WEBSITE_URL")
 	// Note: WEBSITE_URL validation is handled in main()
 
+	// Parse content age after which a question triggers a background refresh (default: 60 minutes)
+	staleMinutes := 60
+	if staleMinutesStr := os.Getenv("STALE_CONTENT_MINUTES"); staleMinutesStr != "" {
+		if parsed, err := strconv.Atoi(staleMinutesStr); err == nil && parsed > 0 {
+			staleMinutes = parsed
+		}
+	}
+
+	// Answer used when no site content could be loaded, distinct from the Ollama-unavailable fallback
+	noContentMsg := os.Getenv("NO_CONTENT_MESSAGE")
+	if noContentMsg == "" {
+		noContentMsg = "I couldn't load the site content right now, so I can't answer questions about it yet. Please try again later."
+	}
+
+	// Parse number of relevant content snippets returned with each answer (default: 0, disabled)
+	snippetCount := 0
+	if snippetCountStr := os.Getenv("SNIPPET_COUNT"); snippetCountStr != "" {
+		if parsed, err := strconv.Atoi(snippetCountStr); err == nil && parsed >= 0 {
+			snippetCount = parsed
+		}
+	}
+
+	// Parse number of earlier exchanges of a session included in the prompt (default: 6, 0 disables)
+	maxHistoryTurns := 6
+	if turnsStr := os.Getenv("MAX_HISTORY_TURNS"); turnsStr != "" {
+		if parsed, err := strconv.Atoi(turnsStr); err == nil && parsed >= 0 {
+			maxHistoryTurns = parsed
+		}
+	}
+
+	// Parse maximum number of sessions whose history is kept (default: 1000)
+	maxSessions := 1000
+	if sessionsStr := os.Getenv("MAX_SESSIONS"); sessionsStr != "" {
+		if parsed, err := strconv.Atoi(sessionsStr); err == nil && parsed > 0 {
+			maxSessions = parsed
+		}
+	}
+
+	// Parse how long an idle session keeps its history (default: 30 minutes)
+	sessionIdleMinutes := 30
+	if idleStr := os.Getenv("SESSION_IDLE_TIMEOUT_MINUTES"); idleStr != "" {
+		if parsed, err := strconv.Atoi(idleStr); err == nil && parsed > 0 {
+			sessionIdleMinutes = parsed
+		}
+	}
+
+	conversations := newConversationStore(maxHistoryTurns, maxSessions, time.Duration(sessionIdleMinutes)*time.Minute)
+
+	// Check if session history should survive restarts (default: false, it stores what users asked)
+	if strings.ToLower(os.Getenv("PERSIST_SESSIONS")) == "true" {
+		sessionsDir := os.Getenv("SESSIONS_DIR")
+		if sessionsDir == "" {
+			sessionsDir = "sessions"
+		}
+
+		// Parse how long after it started a persisted session is kept (default: 24h),
+		// as a duration like "12h" or a number of minutes
+		sessionTTL := 24 * time.Hour
+		if ttlStr := os.Getenv("SESSION_TTL"); ttlStr != "" {
+			if parsed, err := time.ParseDuration(ttlStr); err == nil && parsed > 0 {
+				sessionTTL = parsed
+			} else if minutes, err := strconv.Atoi(ttlStr); err == nil && minutes > 0 {
+				sessionTTL = time.Duration(minutes) * time.Minute
+			} else {
+				logWarning("invalid_config", "Invalid SESSION_TTL, using 24h", "setting", "SESSION_TTL", "value", ttlStr)
+			}
+		}
+
+		if err := conversations.persist(sessionsDir, sessionTTL); err != nil {
+			logWarning("session_load_failed", "Sessions won't be persisted", "path", sessionsDir, "error", err)
+		}
+	}
+
+	// Embed scraped content for semantic search when it is enabled
+	if ollamaService != nil && ollamaService.semanticSearch {
+		scraper.embedder = ollamaService
+	}
+
 	return &Chatbot{
 		scraper:       scraper,
 		ollamaService: ollamaService,
 		websiteURL:    websiteURL,
+		autoRefresh:   strings.ToLower(os.Getenv("AUTO_REFRESH_ON_STALE_QUERY")) == "true",
+		staleAfter:    time.Duration(staleMinutes) * time.Minute,
+		noContentMsg:  noContentMsg,
+		snippetCount:  snippetCount,
+		conversations: conversations,
 	}
 }
 
-func (c *Chatbot) refreshWebsiteData() error {
-	if c.websiteData != nil && time.Since(c.lastDataFetch) < 1*time.Hour {
+func (c *Chatbot) refreshWebsiteData(ctx context.Context) error {
+	c.scrapeMu.Lock()
+	defer c.scrapeMu.Unlock()
+
+	c.dataMu.RLock()
+	fresh := c.websiteData != nil && time.Since(c.lastDataFetch) < 1*time.Hour
+	c.dataMu.RUnlock()
+	if fresh {
 		return nil
 	}
 
 	// Clear previous scraping logs for a fresh session
 	c.scraper.ClearScrapedUrls()
 
-	data, err := c.scraper.ScrapeWebsite(c.websiteURL)
+	data, err := c.scraper.ScrapeWebsiteContext(ctx, c.websiteURL)
 	if err != nil {
-		return fmt.Errorf("failed to refresh website data: %v", err)
+		return fmt.Errorf("failed to refresh website data: %w", err)
 	}
 
 	// Print scraping summary after successful scraping
 	c.scraper.PrintScrapedUrls()
 
+	c.dataMu.Lock()
 	c.websiteData = data
 	c.lastDataFetch = time.Now()
+	c.dataMu.Unlock()
 	return nil
 }
 
-func (c *Chatbot) ProcessMessage(message string) (*ChatMessage, error) {
-	if err := c.refreshWebsiteData(); err != nil {
-		return nil, err
+// ForceRefresh scrapes the website again bypassing every cache, returning the counters of the
+// scraping log of that crawl. Questions are answered from the current content until the fresh content replaces it;
+// if the scrape fails, the current content is kept.
+func (c *Chatbot) ForceRefresh(ctx context.Context) (ScrapeLogStats, error) {
+	c.scrapeMu.Lock()
+	defer c.scrapeMu.Unlock()
+
+	c.scraper.ClearScrapedUrls()
+	data, err := c.scraper.ForceRefreshWebsite(ctx, c.websiteURL)
+	stats := c.GetScrapeLogStats()
+	if err != nil {
+		return stats, fmt.Errorf("failed to refresh website data: %w", err)
 	}
+	c.scraper.PrintScrapedUrls()
+
+	c.dataMu.Lock()
+	c.websiteData = data
+	c.lastDataFetch = time.Now()
+	c.dataMu.Unlock()
+	return stats, nil
+}
 
-	response := c.generateResponse(message)
+// currentData returns the content questions are answered from. Callers keep the returned pointer
+// for the whole request, so a concurrent refresh can't mix old and new content in one answer.
+func (c *Chatbot) currentData() *WebsiteContent {
+	c.dataMu.RLock()
+	defer c.dataMu.RUnlock()
+	return c.websiteData
+}
 
-	return &ChatMessage{
+// hasSiteContent reports whether anything usable was scraped: text from the site or its documents, or links
+func hasSiteContent(content *WebsiteContent) bool {
+	return content != nil && (extractedTextLength(content) > 0 || len(content.Links) > 0)
+}
+
+// noContentMessage answers with the configured no-content message instead of generating from nothing
+func (c *Chatbot) noContentMessage(message string, data *WebsiteContent, stats *RequestStats) *ChatMessage {
+	c.fallbackAnswers.Add(1)
+	stats.Fallback = true
+	stats.NoContent = true
+
+	chatMessage := &ChatMessage{
 		Message:   message,
-		Response:  response,
+		Response:  c.noContentMsg,
 		Timestamp: time.Now(),
-	}, nil
+		Stats:     stats,
+	}
+	if data != nil {
+		chatMessage.ContentUpdatedAt = data.LastUpdated
+		chatMessage.ContentHash = data.ContentHash
+	}
+	return chatMessage
 }
 
-func (c *Chatbot) generateResponse(message string) string {
+// refreshIfStale starts a non-blocking scrape when the content is older than staleAfter.
+// The current question is answered from the cache; the next one picks up the fresh data.
+func (c *Chatbot) refreshIfStale(data *WebsiteContent) {
+	if !c.autoRefresh || data == nil || time.Since(data.LastUpdated) < c.staleAfter {
+		return
+	}
+
+	go func() {
+		if !c.scrapeMu.TryLock() {
+			return // A scrape is already running
+		}
+		defer c.scrapeMu.Unlock()
+
+		fmt.Printf("Content is %s old, refreshing in the background\n", time.Since(data.LastUpdated).Round(time.Minute))
+		c.scraper.ClearScrapedUrls()
+		if _, err := c.scraper.RefreshWebsite(c.websiteURL); err != nil {
+			c.scraper.warn("background_refresh_failed", "Background refresh failed", "url", c.websiteURL, "error", err)
+			return
+		}
+		c.scraper.PrintScrapedUrls()
+
+		// Make the next question reload the freshly cached content
+		c.dataMu.Lock()
+		c.lastDataFetch = time.Time{}
+		c.dataMu.Unlock()
+	}()
+}
+
+// ProcessMessage answers a message; cancelling ctx (e.g. the client disconnecting) stops scraping and generation.
+// Messages with the same non-empty sessionID are answered with the earlier exchanges of that session.
+// Overrides, if not nil, replace the model and temperature for this message. If out is not nil, generated
+// tokens are sent to it as they arrive; fallback answers are only returned.
+func (c *Chatbot) ProcessMessage(ctx context.Context, sessionID, message string, overrides *OllamaOptions, out chan<- string) (*ChatMessage, error) {
+	stats := &RequestStats{}
+
+	refreshErr := c.refreshWebsiteData(ctx)
+	data := c.currentData()
+	if refreshErr != nil {
+		if data != nil || errors.Is(refreshErr, context.Canceled) {
+			return nil, refreshErr
+		}
+		// Nothing scraped and nothing cached: don't let the model answer from empty content
+		logWarning("no_site_content", "No site content available", "url", c.websiteURL, "error", refreshErr)
+		return c.noContentMessage(message, nil, stats), nil
+	}
+	if !hasSiteContent(data) {
+		return c.noContentMessage(message, data, stats), nil
+	}
+
+	c.refreshIfStale(data)
+
+	response, err := c.generateResponse(ctx, data, c.conversations.history(sessionID), message, overrides, stats, out)
+	if err != nil {
+		return nil, err
+	}
+
+	chatMessage := &ChatMessage{
+		Message:          message,
+		Response:         response,
+		Timestamp:        time.Now(),
+		ContentUpdatedAt: data.LastUpdated,
+		ContentHash:      data.ContentHash,
+		Stats:            stats,
+		Snippets:         selectSnippets(data, c.websiteURL, message, c.snippetCount),
+	}
+	c.conversations.record(sessionID, message, response)
+	return chatMessage, nil
+}
+
+func (c *Chatbot) generateResponse(ctx context.Context, data *WebsiteContent, history []exchange, message string, overrides *OllamaOptions, stats *RequestStats, out chan<- string) (string, error) {
 	// Always try to use Ollama first with all available content
 	if c.ollamaService != nil && c.ollamaService.IsEnabled() {
-		response, err := c.ollamaService.GenerateIntelligentResponse(c.websiteData, message)
+		response, err := c.ollamaService.GenerateIntelligentResponse(ctx, data, history, message, overrides, stats, out)
 		if err == nil {
-			return response
+			c.llmAnswers.Add(1)
+			return response, nil
 		}
-		fmt.Printf("Ollama service error: %v\n", err)
+		// Surface backpressure and cancellation to the caller instead of answering with the fallback
+		if errors.Is(err, ErrOllamaQueueFull) || errors.Is(err, context.Canceled) {
+			return "", err
+		}
+		c.ollamaFailures.Add(1)
+		logWarning("ollama_failed", "Ollama service error, answering with the fallback", "error", err)
 	}
 
-	return "Not available"
+	c.fallbackAnswers.Add(1)
+	stats.Fallback = true
+	return "Not available", nil
 	//	// Fallback to rule-based responses only if Ollama is not available
 	//	return c.getRuleBasedResponse(message)
 }
 
+// GetScrapedUrls returns the scraping log of the last crawl, only the entries of urlType if it isn't empty
+func (c *Chatbot) GetScrapedUrls(urlType string) []ScrapedUrl {
+	scrapedUrls := c.scraper.GetScrapedUrls()
+	if urlType == "" {
+		return scrapedUrls
+	}
+
+	var filtered []ScrapedUrl
+	for _, scraped := range scrapedUrls {
+		if scraped.Type == urlType {
+			filtered = append(filtered, scraped)
+		}
+	}
+	return filtered
+}
+
+// GetScrapeLogStats returns the counters of the scraping log, which cover entries rotated out of it
+func (c *Chatbot) GetScrapeLogStats() ScrapeLogStats {
+	return c.scraper.GetScrapeLogStats()
+}
+
+// GetScrapeWarnings returns the warnings raised while scraping, when COLLECT_SCRAPE_WARNINGS is enabled
+func (c *Chatbot) GetScrapeWarnings() []ScrapeWarning {
+	return c.scraper.GetWarnings()
+}
+
+// ContentDiff compares the cached content of a site, defaulting to the configured website, with
+// the version it replaced
+func (c *Chatbot) ContentDiff(targetUrl string) (*ContentDiff, error) {
+	if targetUrl == "" {
+		targetUrl = c.websiteURL
+	}
+	return c.scraper.ContentDiff(targetUrl)
+}
+
+// Health checks the dependencies answers rely on: whether Ollama responds (nil service reports
+// disabled) and whether the configured website can be reached
+func (c *Chatbot) Health(ctx context.Context) (ollama string, websiteErr error) {
+	ollama = "disabled"
+	if c.ollamaService != nil {
+		ollama = "down"
+		if c.ollamaService.IsEnabled() {
+			ollama = "up"
+		}
+	}
+	return ollama, c.scraper.CheckReachable(ctx, c.websiteURL)
+}
+
+// GetSiteContent returns the knowledge base for a site, defaulting to the configured website.
+// Only already scraped content is returned; nothing is fetched.
+func (c *Chatbot) GetSiteContent(targetUrl string) (*WebsiteContent, error) {
+	if targetUrl == "" {
+		targetUrl = c.websiteURL
+	}
+	if data := c.currentData(); targetUrl == c.websiteURL && data != nil {
+		return data, nil
+	}
+	return c.scraper.GetCachedContent(targetUrl)
+}
+
 func (c *Chatbot) getRuleBasedResponse(message string) string {
 	lowerMsg := strings.ToLower(message)
 
@@ -143,7 +432,8 @@ Is there something specific you'd like to know?`
 }
 
 func (c *Chatbot) getPersonInfo() string {
-	if c.websiteData == nil {
+	data := c.currentData()
+	if data == nil {
 		return "I'm having trouble accessing the website data right now. Please try again in a moment."
 	}
 
@@ -188,18 +478,21 @@ func (c *Chatbot) getBlogInfo() string {
 }
 
 func (c *Chatbot) getCVInfo() string {
+	data := c.currentData()
 	cv := c.findLinkByKeyword("cv")
 	if cv != nil {
 		response := fmt.Sprintf("You can view the CV/Resume here: %s", cv.URL)
 
-		if c.websiteData != nil && c.websiteData.PDFContent != nil {
-			if pdfContent, exists := c.websiteData.PDFContent[cv.URL]; exists {
+		if data != nil && data.PDFContent != nil {
+			// PDF content is keyed by the resolved absolute URL
+			if pdfContent, exists := data.PDFContent[c.scraper.resolveDocumentURL(c.websiteURL, cv.URL)]; exists {
 				if c.ollamaService != nil && c.ollamaService.IsEnabled() {
 					aiAnalysis, err := c.ollamaService.AnalyzePDFContent(pdfContent, "Provide a comprehensive summary of this CV including key skills, experience, and qualifications.")
 					if err == nil {
 						response += "\n\nAI Analysis of the CV:\n" + aiAnalysis
 						return response
 					}
+					logWarning("summarization_failed", "PDF summarization failed", "title", pdfContent.Title, "error", err)
 				}
 
 				keyInfo := c.extractPDFKeyInfo(pdfContent)
@@ -239,11 +532,12 @@ Just ask me anything about these topics!`, aiStatus)
 }
 
 func (c *Chatbot) findLinkByKeyword(keyword string) *Link {
-	if c.websiteData == nil {
+	data := c.currentData()
+	if data == nil {
 		return nil
 	}
 
-	for _, link := range c.websiteData.Links {
+	for _, link := range data.Links {
 		if strings.Contains(strings.ToLower(link.URL), keyword) ||
 			strings.Contains(strings.ToLower(link.Title), keyword) {
 			return &link
@@ -253,7 +547,8 @@ func (c *Chatbot) findLinkByKeyword(keyword string) *Link {
 }
 
 func (c *Chatbot) getProfileLinks() []Link {
-	if c.websiteData == nil {
+	data := c.currentData()
+	if data == nil {
 		return []Link{}
 	}
 
@@ -297,17 +592,23 @@ func (c *Chatbot) extractPDFKeyInfo(pdfContent *PDFContent) string {
 		}
 	}
 
+	if len(pdfContent.Phones) > 0 {
+		result = append(result, "Phone: "+strings.Join(pdfContent.Phones, ", "))
+	}
+
 	return strings.Join(result, "\n")
 }
 
 func (c *Chatbot) getSkillsInfo() string {
-	if c.websiteData != nil && c.websiteData.PDFContent != nil {
-		for _, pdfContent := range c.websiteData.PDFContent {
+	data := c.currentData()
+	if data != nil && data.PDFContent != nil {
+		for _, pdfContent := range data.PDFContent {
 			if c.ollamaService != nil && c.ollamaService.IsEnabled() {
 				aiAnalysis, err := c.ollamaService.AnalyzePDFContent(pdfContent, "Extract and analyze all technical skills, programming languages, frameworks, and technologies mentioned in this CV. Organize them by category.")
 				if err == nil {
 					return fmt.Sprintf("AI Analysis of Technical Skills:\n%s\n\nFor more details, check the CV and GitHub profile.", aiAnalysis)
 				}
+				logWarning("summarization_failed", "PDF summarization failed", "title", pdfContent.Title, "error", err)
 			}
 
 			extractor := NewPDFExtractor()
@@ -323,13 +624,15 @@ func (c *Chatbot) getSkillsInfo() string {
 }
 
 func (c *Chatbot) getExperienceInfo() string {
-	if c.websiteData != nil && c.websiteData.PDFContent != nil {
-		for _, pdfContent := range c.websiteData.PDFContent {
+	data := c.currentData()
+	if data != nil && data.PDFContent != nil {
+		for _, pdfContent := range data.PDFContent {
 			if c.ollamaService != nil && c.ollamaService.IsEnabled() {
 				aiAnalysis, err := c.ollamaService.AnalyzePDFContent(pdfContent, "Analyze and summarize the professional work experience, including companies, roles, responsibilities, and key achievements. Focus on career progression and impact.")
 				if err == nil {
 					return fmt.Sprintf("AI Analysis of Professional Experience:\n%s\n\nFor complete work history, please check the full CV and LinkedIn profile.", aiAnalysis)
 				}
+				logWarning("summarization_failed", "PDF summarization failed", "title", pdfContent.Title, "error", err)
 			}
 
 			extractor := NewPDFExtractor()
@@ -348,13 +651,15 @@ func (c *Chatbot) getExperienceInfo() string {
 }
 
 func (c *Chatbot) getEducationInfo() string {
-	if c.websiteData != nil && c.websiteData.PDFContent != nil {
-		for _, pdfContent := range c.websiteData.PDFContent {
+	data := c.currentData()
+	if data != nil && data.PDFContent != nil {
+		for _, pdfContent := range data.PDFContent {
 			if c.ollamaService != nil && c.ollamaService.IsEnabled() {
 				aiAnalysis, err := c.ollamaService.AnalyzePDFContent(pdfContent, "Extract and analyze educational background including degrees, institutions, graduation dates, academic achievements, and relevant coursework.")
 				if err == nil {
 					return fmt.Sprintf("AI Analysis of Educational Background:\n%s\n\nFor more details, check the full CV.", aiAnalysis)
 				}
+				logWarning("summarization_failed", "PDF summarization failed", "title", pdfContent.Title, "error", err)
 			}
 
 			extractor := NewPDFExtractor()
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestSite serves a small profile page
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	site := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(rw, `<html><head><title>Jane Doe</title></head><body>
+			<h1>Jane Doe</h1>
+			<p>Jane is a software engineer who builds distributed systems in Go.</p>
+		</body></html>`)
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+// newTestChatbot builds a chatbot for siteURL answering through the Ollama at ollamaURL
+func newTestChatbot(t *testing.T, siteURL, ollamaURL string) *Chatbot {
+	t.Helper()
+	t.Setenv("WEBSITE_URL", siteURL)
+	t.Setenv("OLLAMA_URL", ollamaURL)
+	return NewChatbot(newTestScraper(t), NewOllamaService())
+}
+
+func TestProcessMessageDuringRefresh(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	c := newTestChatbot(t, site.URL, ollama.URL)
+	// Concurrent callers reuse the result of an in-flight probe, which starts out unhealthy
+	if !c.ollamaService.IsEnabled() {
+		t.Fatal("fake Ollama not reachable")
+	}
+
+	const clients, messages, refreshes = 8, 5, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, clients*messages+refreshes)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(client int) {
+			defer wg.Done()
+			for j := 0; j < messages; j++ {
+				reply, err := c.ProcessMessage(context.Background(), fmt.Sprintf("session-%d", client), "What does Jane do?", nil, nil)
+				if err != nil {
+					errs <- err
+				} else if reply.Response != ollama.answer {
+					errs <- fmt.Errorf("client %d got %q, want the model's answer", client, reply.Response)
+				}
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < refreshes; j++ {
+			if _, err := c.ForceRefresh(context.Background()); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConversationHistoryInPrompt(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	c := newTestChatbot(t, site.URL, ollama.URL)
+
+	const first = "What does Jane do?"
+	for _, message := range []struct{ session, text string }{
+		{"session-a", first},
+		{"session-a", "Which language does she use for that?"},
+		{"session-b", "Who is this site about?"},
+	} {
+		if _, err := c.ProcessMessage(context.Background(), message.session, message.text, nil, nil); err != nil {
+			t.Fatalf("ProcessMessage(%q): %v", message.text, err)
+		}
+	}
+
+	prompts := ollama.prompts()
+	if len(prompts) != 3 {
+		t.Fatalf("Ollama got %d prompts, want 3", len(prompts))
+	}
+	if strings.Contains(prompts[0], "CONVERSATION SO FAR") {
+		t.Error("first prompt of a session has a conversation section")
+	}
+	if !strings.Contains(prompts[1], "CONVERSATION SO FAR") || !strings.Contains(prompts[1], "User: "+first) {
+		t.Errorf("second prompt doesn't contain the first question:\n%s", prompts[1])
+	}
+	if strings.Contains(prompts[2], first) {
+		t.Error("another session's prompt contains the first session's question")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// contactCardSelectors match h-card microformats, their legacy hCard form, and <address> elements
+const contactCardSelectors = ".h-card, .vcard, address"
+
+// maxContactCards bounds how many contact cards are kept per page
+const maxContactCards = 10
+
+// ContactCard is contact information marked up in an <address> element or an h-card microformat
+type ContactCard struct {
+	Name    string   `json:",omitempty"`
+	Org     string   `json:",omitempty"`
+	Emails  []string `json:",omitempty"`
+	Phones  []string `json:",omitempty"`
+	URLs    []string `json:",omitempty"`
+	Address string   `json:",omitempty"` // Postal address, or the whole text of an <address> element
+	Source  string   // "h-card" or "address"
+}
+
+// extractContacts parses h-cards and <address> elements into contact cards. Elements nested in an
+// already parsed card are part of it, and cards without any detail are skipped.
+func extractContacts(doc *goquery.Document, phones phoneNormalizer) []ContactCard {
+	var cards []ContactCard
+	seen := make(map[string]bool)
+	doc.Find(contactCardSelectors).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if len(cards) >= maxContactCards {
+			return false
+		}
+		if s.ParentsFiltered(contactCardSelectors).Length() > 0 {
+			return true
+		}
+
+		var card ContactCard
+		if goquery.NodeName(s) == "address" && !s.HasClass("h-card") && !s.HasClass("vcard") {
+			card = parseAddressElement(s, phones)
+		} else {
+			card = parseHCard(s, phones)
+		}
+
+		key := fmt.Sprintf("%v", card)
+		if card.isEmpty() || seen[key] {
+			return true
+		}
+		seen[key] = true
+		cards = append(cards, card)
+		return true
+	})
+	return cards
+}
+
+// parseHCard reads the common h-card properties (p-name, p-org, u-email, p-tel, u-url, p-adr) and
+// their legacy hCard class names
+func parseHCard(s *goquery.Selection, phones phoneNormalizer) ContactCard {
+	card := ContactCard{
+		Name:    contactText(s.Find(".p-name, .fn").First()),
+		Org:     contactText(s.Find(".p-org, .org").First()),
+		Address: contactText(s.Find(".p-adr, .h-adr, .adr").First()),
+		Source:  "h-card",
+	}
+	if card.Address == "" {
+		card.Address = contactText(s.Find(".p-street-address, .street-address, .p-locality, .locality, .p-country-name, .country-name"))
+	}
+
+	s.Find(".u-email, .email, a[href^='mailto:']").Each(func(i int, e *goquery.Selection) {
+		card.Emails = appendUnique(card.Emails, contactValue(e, "mailto:"))
+	})
+	s.Find(".p-tel, .tel, a[href^='tel:']").Each(func(i int, e *goquery.Selection) {
+		card.Phones = appendUnique(card.Phones, phones.clean(contactValue(e, "tel:")))
+	})
+	s.Find(".u-url, a.url").Each(func(i int, e *goquery.Selection) {
+		card.URLs = appendUnique(card.URLs, e.AttrOr("href", contactText(e)))
+	})
+	return card
+}
+
+// parseAddressElement keeps the text of an <address> element as the address, picking out its
+// mailto: and tel: links
+func parseAddressElement(s *goquery.Selection, phones phoneNormalizer) ContactCard {
+	card := ContactCard{Address: addressText(s), Source: "address"}
+	s.Find("a[href^='mailto:']").Each(func(i int, e *goquery.Selection) {
+		card.Emails = appendUnique(card.Emails, contactValue(e, "mailto:"))
+	})
+	s.Find("a[href^='tel:']").Each(func(i int, e *goquery.Selection) {
+		card.Phones = appendUnique(card.Phones, phones.clean(contactValue(e, "tel:")))
+	})
+	return card
+}
+
+// contactValue returns the address of a mailto:/tel: link, without query parameters, or the
+// element's text when it isn't such a link
+func contactValue(s *goquery.Selection, scheme string) string {
+	if href, ok := s.Attr("href"); ok && strings.HasPrefix(strings.ToLower(href), scheme) {
+		value, _, _ := strings.Cut(href[len(scheme):], "?")
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		return strings.TrimSpace(value)
+	}
+	return contactText(s)
+}
+
+// addressText returns the text of an <address> element with its lines (separated by <br> or
+// block elements) joined by ", ", leaving out mailto:/tel: links that are kept separately
+func addressText(s *goquery.Selection) string {
+	var b strings.Builder
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "a":
+				if href := strings.ToLower(goquery.NewDocumentFromNode(n).AttrOr("href", "")); strings.HasPrefix(href, "mailto:") || strings.HasPrefix(href, "tel:") {
+					return
+				}
+			case "br", "p", "div", "li":
+				b.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	for _, n := range s.Nodes {
+		collect(n)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = allWhitespace.ReplaceAllString(strings.TrimSpace(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, ", ")
+}
+
+// contactText returns the whitespace-collapsed text of a selection
+func contactText(s *goquery.Selection) string {
+	return allWhitespace.ReplaceAllString(strings.TrimSpace(s.Text()), " ")
+}
+
+func appendUnique(values []string, value string) []string {
+	if value == "" || slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+func (c ContactCard) isEmpty() bool {
+	return c.Name == "" && c.Org == "" && c.Address == "" && len(c.Emails) == 0 && len(c.Phones) == 0 && len(c.URLs) == 0
+}
+
+// formatContactCard renders a card as one line for the prompt
+func formatContactCard(c ContactCard) string {
+	var parts []string
+	name := c.Name
+	if c.Org != "" {
+		name = strings.TrimSpace(fmt.Sprintf("%s (%s)", c.Name, c.Org))
+	}
+	if name != "" {
+		parts = append(parts, name)
+	}
+	if len(c.Emails) > 0 {
+		parts = append(parts, "Email: "+strings.Join(c.Emails, ", "))
+	}
+	if len(c.Phones) > 0 {
+		parts = append(parts, "Phone: "+strings.Join(c.Phones, ", "))
+	}
+	if len(c.URLs) > 0 {
+		parts = append(parts, "Web: "+strings.Join(c.URLs, ", "))
+	}
+	if c.Address != "" {
+		parts = append(parts, "Address: "+c.Address)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Content budget modes for MAX_TOTAL_CONTENT_LENGTH
+const (
+	BudgetGlobal    = "global"     // Cut the assembled content at the limit; later sections may be dropped
+	BudgetPerSource = "per_source" // Give every source a slice of the limit
+)
+
+// sectionWeights sets each category's share of the budget in per-source mode. CVs carry the most
+// answers, so PDFs get the largest share; unlisted categories weigh 1.
+var sectionWeights = map[string]int{
+	SectionPDF:  4,
+	SectionMain: 2,
+	SectionFile: 2,
+}
+
+func sectionWeight(category string) int {
+	if weight, ok := sectionWeights[category]; ok {
+		return weight
+	}
+	return 1
+}
+
+// allocateBudget splits total characters across sections by category weight. Sections smaller than
+// their share are kept whole and the rest of their share goes to the larger ones, so short sources
+// are never cut and every non-empty source gets some room.
+func allocateBudget(sections []promptSection, total int) []int {
+	budgets := make([]int, len(sections))
+	if total <= 0 {
+		return budgets
+	}
+
+	var pending []int
+	for i, section := range sections {
+		if len(section.text) > 0 {
+			pending = append(pending, i)
+		}
+	}
+
+	remaining := total
+	for len(pending) > 0 {
+		weightSum := 0
+		for _, i := range pending {
+			weightSum += sectionWeight(sections[i].category)
+		}
+		share := func(i int) int {
+			return remaining * sectionWeight(sections[i].category) / weightSum
+		}
+
+		var fitting, unfilled []int
+		for _, i := range pending {
+			if len(sections[i].text) <= share(i) {
+				fitting = append(fitting, i)
+			} else {
+				unfilled = append(unfilled, i)
+			}
+		}
+		if len(fitting) == 0 {
+			// Every remaining section is larger than its share: cut them all to it
+			for _, i := range unfilled {
+				budgets[i] = share(i)
+			}
+			break
+		}
+		for _, i := range fitting {
+			budgets[i] = len(sections[i].text)
+			remaining -= budgets[i]
+		}
+		pending = unfilled
+	}
+	return budgets
+}
+
+// sequentialBudgets is the global mode's allocation: sections are filled in order until the total is used up
+func sequentialBudgets(sections []promptSection, total int) []int {
+	budgets := make([]int, len(sections))
+	used := 0
+	for i, section := range sections {
+		budgets[i] = max(min(len(section.text), total-used), 0)
+		used += len(section.text)
+	}
+	return budgets
+}
+
+// truncateSections cuts each section to its budget, marking cuts with "...", and joins them with separator
+func truncateSections(sections []promptSection, budgets []int, separator string) string {
+	var parts []string
+	for i, section := range sections {
+		text := section.text
+		if budgets[i] <= 0 {
+			continue
+		}
+		if len(text) > budgets[i] {
+			cut := max(budgets[i]-len("..."), 0)
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "..."
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, separator)
+}
+
+// sectionCoverage reports which section categories got part of the content budget and which were
+// cut partially or entirely. A category counts as truncated when any of its sections was.
+func sectionCoverage(sections []promptSection, budgets []int) (included, truncated []string) {
+	for i, section := range sections {
+		if budgets[i] > 0 && !slices.Contains(included, section.category) {
+			included = append(included, section.category)
+		}
+		if budgets[i] < len(section.text) && !slices.Contains(truncated, section.category) {
+			truncated = append(truncated, section.category)
+		}
+	}
+	return included, truncated
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sectionsOf returns count sections of one category with text of the given length
+func sectionsOf(category string, count, length int) []promptSection {
+	sections := make([]promptSection, count)
+	for i := range sections {
+		sections[i] = promptSection{category: category, text: strings.Repeat("x", length)}
+	}
+	return sections
+}
+
+// repeatBudget returns count budgets of value
+func repeatBudget(count, value int) []int {
+	budgets := make([]int, count)
+	for i := range budgets {
+		budgets[i] = value
+	}
+	return budgets
+}
+
+func TestAllocateBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections []promptSection
+		total    int
+		want     []int
+	}{
+		{
+			name:     "everything fits",
+			sections: append(sectionsOf(SectionPDF, 1, 300), sectionsOf(SectionMain, 1, 200)...),
+			total:    1000,
+			want:     []int{300, 200},
+		},
+		{
+			// Shares are 4/7, 2/7 and 1/7 of 1000; main and linked fit, so the PDF gets all that's left
+			name:     "one huge source takes what the others leave",
+			sections: append(append(sectionsOf(SectionPDF, 1, 10000), sectionsOf(SectionMain, 1, 100)...), sectionsOf(SectionLinked, 1, 50)...),
+			total:    1000,
+			want:     []int{850, 100, 50},
+		},
+		{
+			name:     "sources larger than their share are cut by weight",
+			sections: append(sectionsOf(SectionPDF, 1, 1000), sectionsOf(SectionMain, 1, 1000)...),
+			total:    600,
+			want:     []int{400, 200},
+		},
+		{
+			// Each linked page's share is 500/22, so all 20 are kept whole next to the cut main page
+			name:     "many tiny sources beside a huge one",
+			sections: append(sectionsOf(SectionMain, 1, 5000), sectionsOf(SectionLinked, 20, 10)...),
+			total:    500,
+			want:     append([]int{300}, repeatBudget(20, 10)...),
+		},
+		{
+			name:     "many tiny sources over the total share it evenly",
+			sections: sectionsOf(SectionLinked, 100, 10),
+			total:    500,
+			want:     repeatBudget(100, 5),
+		},
+		{
+			name:     "empty sections get nothing",
+			sections: append(sectionsOf(SectionReviews, 1, 0), sectionsOf(SectionMain, 1, 2000)...),
+			total:    1000,
+			want:     []int{0, 1000},
+		},
+		{
+			name:     "no budget",
+			sections: sectionsOf(SectionMain, 2, 100),
+			total:    0,
+			want:     []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocateBudget(tt.sections, tt.total)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocateBudget() = %v, want %v", got, tt.want)
+			}
+			sum := 0
+			for _, budget := range got {
+				sum += budget
+			}
+			if sum > tt.total {
+				t.Errorf("budgets add up to %d, over the total of %d", sum, tt.total)
+			}
+		})
+	}
+}
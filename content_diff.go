@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// previousSnapshotFile holds the cached content a changed scrape replaced, next to content.json
+const previousSnapshotFile = "previous.json"
+
+// contentVersionPattern matches the timestamped versions written with CACHE_VERSIONS; the
+// timestamps sort chronologically as strings
+const contentVersionPattern = "content-*.json"
+
+// contentVersionTime formats the timestamp in a version's file name
+const contentVersionTime = "20060102T150405.000Z"
+
+// maxDiffSections bounds the added and removed sections returned by one diff
+const maxDiffSections = 200
+
+// ErrNoSnapshot is returned by ContentDiff when there is no earlier version to compare with
+var ErrNoSnapshot = errors.New("no previous snapshot")
+
+// contentSnapshot is the content.json wrapper written by saveContentToDisk
+type contentSnapshot struct {
+	URL     string          `json:"url"`
+	SavedAt time.Time       `json:"saved_at"`
+	Content *WebsiteContent `json:"content"`
+}
+
+// ContentDiff lists the text that changed between the previous and the current cached content
+type ContentDiff struct {
+	URL             string        `json:"url"`
+	PreviousSavedAt time.Time     `json:"previous_saved_at"`
+	CurrentSavedAt  time.Time     `json:"current_saved_at"`
+	Added           []DiffSection `json:"added"`
+	Removed         []DiffSection `json:"removed"`
+	Truncated       bool          `json:"truncated,omitempty"` // More than maxDiffSections changes
+}
+
+// DiffSection is one added or removed line of text and where it came from
+type DiffSection struct {
+	Source string `json:"source"` // "main" or the URL of a linked page or document
+	Text   string `json:"text"`
+}
+
+func readContentSnapshot(filePath string) (*contentSnapshot, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot contentSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal content: %v", err)
+	}
+	if snapshot.Content == nil {
+		return nil, fmt.Errorf("no content in %s", filePath)
+	}
+	return &snapshot, nil
+}
+
+// keepPreviousSnapshot moves the cached content at filePath aside as previous.json when the content
+// about to replace it has a different hash, so unchanged re-scrapes keep the last real change
+func (w *WebScraper) keepPreviousSnapshot(filePath string, content *WebsiteContent) {
+	existing, err := readContentSnapshot(filePath)
+	if err != nil {
+		return // Nothing cached yet
+	}
+	if existing.Content.ContentHash == "" {
+		existing.Content.ContentHash = computeContentHash(existing.Content)
+	}
+	if existing.Content.ContentHash == content.ContentHash {
+		return
+	}
+	if err := os.Rename(filePath, filepath.Join(filepath.Dir(filePath), previousSnapshotFile)); err != nil {
+		w.warn("snapshot_failed", "Could not keep the previous content snapshot", "path", filePath, "error", err)
+	}
+}
+
+// saveContentVersion writes data, the content.json just saved, as content-<timestamp>.json and
+// removes the oldest versions beyond cacheVersions. Nothing is written when the newest version
+// has the same hash, so unchanged re-scrapes don't push real changes out.
+func (w *WebScraper) saveContentVersion(dir string, data []byte, content *WebsiteContent, savedAt time.Time) {
+	versions := contentVersions(dir)
+	if len(versions) > 0 {
+		if latest, err := readContentSnapshot(versions[len(versions)-1]); err == nil && latest.Content.ContentHash == content.ContentHash {
+			return
+		}
+	}
+
+	versionPath := filepath.Join(dir, "content-"+savedAt.UTC().Format(contentVersionTime)+".json")
+	if err := os.WriteFile(versionPath, data, 0644); err != nil {
+		w.warn("snapshot_failed", "Could not write a content version", "path", versionPath, "error", err)
+		return
+	}
+
+	versions = append(versions, versionPath)
+	for len(versions) > w.cacheVersions {
+		if err := os.Remove(versions[0]); err != nil {
+			w.warn("snapshot_failed", "Could not remove an old content version", "path", versions[0], "error", err)
+		}
+		versions = versions[1:]
+	}
+}
+
+// contentVersions returns the paths of the timestamped content versions in dir, oldest first
+func contentVersions(dir string) []string {
+	versions, _ := filepath.Glob(filepath.Join(dir, contentVersionPattern))
+	sort.Strings(versions)
+	return versions
+}
+
+// previousVersion returns the newest content version whose hash differs from current, if any
+func previousVersion(dir string, current *WebsiteContent) (*contentSnapshot, error) {
+	versions := contentVersions(dir)
+	for i := len(versions) - 1; i >= 0; i-- {
+		version, err := readContentSnapshot(versions[i])
+		if err == nil && version.Content.ContentHash != current.ContentHash {
+			return version, nil
+		}
+	}
+	return nil, ErrNoSnapshot
+}
+
+// ContentDiff compares the cached content of a URL with the snapshot it replaced, or with the
+// newest differing version kept by CACHE_VERSIONS when there is no such snapshot
+func (w *WebScraper) ContentDiff(targetUrl string) (*ContentDiff, error) {
+	currentPath := w.getContentFilePath(targetUrl)
+	current, err := readContentSnapshot(currentPath)
+	if err != nil {
+		return nil, fmt.Errorf("no cached content for %s: %w", targetUrl, err)
+	}
+	previous, err := readContentSnapshot(filepath.Join(filepath.Dir(currentPath), previousSnapshotFile))
+	if err != nil {
+		if previous, err = previousVersion(filepath.Dir(currentPath), current.Content); err != nil {
+			return nil, ErrNoSnapshot
+		}
+	}
+
+	diff := &ContentDiff{
+		URL:             targetUrl,
+		PreviousSavedAt: previous.SavedAt,
+		CurrentSavedAt:  current.SavedAt,
+	}
+	previousTexts, currentTexts := sourceTexts(previous.Content), sourceTexts(current.Content)
+	for _, source := range sortedKeys(mergeKeys(previousTexts, currentTexts)) {
+		added, removed := diffLines(previousTexts[source], currentTexts[source])
+		for _, line := range added {
+			diff.Added = append(diff.Added, DiffSection{Source: source, Text: line})
+		}
+		for _, line := range removed {
+			diff.Removed = append(diff.Removed, DiffSection{Source: source, Text: line})
+		}
+	}
+
+	if len(diff.Added) > maxDiffSections {
+		diff.Added, diff.Truncated = diff.Added[:maxDiffSections], true
+	}
+	if len(diff.Removed) > maxDiffSections {
+		diff.Removed, diff.Truncated = diff.Removed[:maxDiffSections], true
+	}
+	return diff, nil
+}
+
+// sourceTexts maps "main" and each linked page and document URL to its text
+func sourceTexts(content *WebsiteContent) map[string]string {
+	texts := map[string]string{
+		"main": strings.Join([]string{content.Title, content.Description, content.Text}, "\n"),
+	}
+	for pageURL, page := range content.LinkedContent {
+		texts[pageURL] = page.Text
+	}
+	for pdfURL, pdf := range content.PDFContent {
+		texts[pdfURL] = pdf.Text
+	}
+	for fileURL, file := range content.FileContent {
+		texts[fileURL] = file.Text
+	}
+	return texts
+}
+
+func mergeKeys(a, b map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// diffLines returns the non-empty lines only in current (added) and only in previous (removed),
+// in their original order. Repeated lines are matched by count.
+func diffLines(previous, current string) (added, removed []string) {
+	previousCounts := countLines(previous)
+	currentCounts := countLines(current)
+
+	for _, line := range splitTextLines(current) {
+		if previousCounts[line] > 0 {
+			previousCounts[line]--
+		} else {
+			added = append(added, line)
+		}
+	}
+	for _, line := range splitTextLines(previous) {
+		if currentCounts[line] > 0 {
+			currentCounts[line]--
+		} else {
+			removed = append(removed, line)
+		}
+	}
+	return added, removed
+}
+
+func countLines(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, line := range splitTextLines(text) {
+		counts[line]++
+	}
+	return counts
+}
+
+func splitTextLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSnapshotContent returns site content with text, hashed like a scrape would
+func testSnapshotContent(text string) *WebsiteContent {
+	content := &WebsiteContent{Title: "Jane Doe", Text: text}
+	content.ContentHash = computeContentHash(content)
+	return content
+}
+
+// writeSnapshot writes content as a content.json wrapper to path and returns the bytes written
+func writeSnapshot(t *testing.T, path string, content *WebsiteContent, savedAt time.Time) []byte {
+	t.Helper()
+	data, err := json.Marshal(contentSnapshot{URL: "https://jane.example", SavedAt: savedAt, Content: content})
+	if err != nil {
+		t.Fatalf("encoding snapshot: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// snapshotText returns the text of the snapshot at path, or "" if there is none
+func snapshotText(path string) string {
+	snapshot, err := readContentSnapshot(path)
+	if err != nil {
+		return ""
+	}
+	return snapshot.Content.Text
+}
+
+func TestKeepPreviousSnapshot(t *testing.T) {
+	tests := []struct {
+		name         string
+		cached       *WebsiteContent // nil for nothing cached
+		next         *WebsiteContent
+		wantPrevious string // Text of previous.json afterwards, "" for none
+	}{
+		{name: "nothing cached", next: testSnapshotContent("Go engineer")},
+		{name: "unchanged", cached: testSnapshotContent("Go engineer"), next: testSnapshotContent("Go engineer")},
+		{name: "changed", cached: testSnapshotContent("Go engineer"), next: testSnapshotContent("Rust engineer"), wantPrevious: "Go engineer"},
+		{
+			name:   "unchanged cache written without a hash",
+			cached: &WebsiteContent{Title: "Jane Doe", Text: "Go engineer"},
+			next:   testSnapshotContent("Go engineer"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestScraper(t)
+			currentPath := w.getContentFilePath("https://jane.example")
+			if tt.cached != nil {
+				writeSnapshot(t, currentPath, tt.cached, time.Now())
+			}
+
+			w.keepPreviousSnapshot(currentPath, tt.next)
+			previousPath := filepath.Join(filepath.Dir(currentPath), previousSnapshotFile)
+			if got := snapshotText(previousPath); got != tt.wantPrevious {
+				t.Errorf("previous.json text = %q, want %q", got, tt.wantPrevious)
+			}
+			// A replaced snapshot is moved aside, an unchanged one stays for the save to overwrite
+			_, err := os.Stat(currentPath)
+			if kept := err == nil; kept != (tt.cached != nil && tt.wantPrevious == "") {
+				t.Errorf("content.json kept = %v", kept)
+			}
+		})
+	}
+}
+
+func TestSaveContentVersionRotation(t *testing.T) {
+	w := newTestScraper(t)
+	w.cacheVersions = 2
+	dir := filepath.Join(w.cacheDir, "jane.example")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		text string
+		want []string // Texts of the versions kept afterwards, oldest first
+	}{
+		{"v1", []string{"v1"}},
+		{"v2", []string{"v1", "v2"}},
+		{"v2", []string{"v1", "v2"}}, // Unchanged re-scrapes don't push v1 out
+		{"v3", []string{"v2", "v3"}},
+		{"v4", []string{"v3", "v4"}},
+	}
+	for i, step := range steps {
+		content := testSnapshotContent(step.text)
+		savedAt := start.Add(time.Duration(i) * time.Minute)
+		data := writeSnapshot(t, filepath.Join(dir, "content.json"), content, savedAt)
+		w.saveContentVersion(dir, data, content, savedAt)
+
+		var got []string
+		for _, version := range contentVersions(dir) {
+			got = append(got, snapshotText(version))
+		}
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d (%s): versions %q, want %q", i+1, step.text, got, step.want)
+		}
+	}
+}
+
+func TestContentDiff(t *testing.T) {
+	const targetURL = "https://jane.example"
+	savedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var manyLines []string
+	for i := 0; i < maxDiffSections+50; i++ {
+		manyLines = append(manyLines, fmt.Sprintf("Project %d", i))
+	}
+
+	tests := []struct {
+		name          string
+		previous      string   // Text of previous.json, "" for none
+		versions      []string // Texts of CACHE_VERSIONS versions, oldest first
+		current       string
+		wantErr       error
+		wantAdded     int
+		wantRemoved   []string
+		wantTruncated bool
+	}{
+		{name: "no snapshot", current: "Go engineer", wantErr: ErrNoSnapshot},
+		{
+			name:        "previous snapshot",
+			previous:    "Go engineer\nLives in Berlin",
+			current:     "Go engineer\nLives in Hamburg",
+			wantAdded:   1,
+			wantRemoved: []string{"Lives in Berlin"},
+		},
+		{
+			// The newest version is the current content itself, so the one before it is compared
+			name:        "falls back to the newest differing version",
+			versions:    []string{"Go engineer\nLives in Berlin", "Go engineer\nLives in Hamburg"},
+			current:     "Go engineer\nLives in Hamburg",
+			wantAdded:   1,
+			wantRemoved: []string{"Lives in Berlin"},
+		},
+		{
+			name:     "only versions of the current content",
+			versions: []string{"Go engineer"},
+			current:  "Go engineer",
+			wantErr:  ErrNoSnapshot,
+		},
+		{
+			name:          "truncated",
+			previous:      "Go engineer",
+			current:       "Go engineer\n" + strings.Join(manyLines, "\n"),
+			wantAdded:     maxDiffSections,
+			wantTruncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestScraper(t)
+			currentPath := w.getContentFilePath(targetURL)
+			dir := filepath.Dir(currentPath)
+			writeSnapshot(t, currentPath, testSnapshotContent(tt.current), savedAt)
+			if tt.previous != "" {
+				writeSnapshot(t, filepath.Join(dir, previousSnapshotFile), testSnapshotContent(tt.previous), savedAt.Add(-time.Hour))
+			}
+			for i, text := range tt.versions {
+				versionTime := savedAt.Add(time.Duration(i-len(tt.versions)) * time.Hour)
+				writeSnapshot(t, filepath.Join(dir, "content-"+versionTime.Format(contentVersionTime)+".json"), testSnapshotContent(text), versionTime)
+			}
+
+			diff, err := w.ContentDiff(targetURL)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ContentDiff() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ContentDiff(): %v", err)
+			}
+
+			var removed []string
+			for _, section := range diff.Removed {
+				removed = append(removed, section.Text)
+			}
+			if len(diff.Added) != tt.wantAdded || !reflect.DeepEqual(removed, tt.wantRemoved) || diff.Truncated != tt.wantTruncated {
+				t.Errorf("diff = %d added, removed %q, truncated %v; want %d added, removed %q, truncated %v",
+					len(diff.Added), removed, diff.Truncated, tt.wantAdded, tt.wantRemoved, tt.wantTruncated)
+			}
+			if !diff.PreviousSavedAt.Before(diff.CurrentSavedAt) {
+				t.Errorf("previous saved at %v, not before the current %v", diff.PreviousSavedAt, diff.CurrentSavedAt)
+			}
+		})
+	}
+
+	t.Run("no cached content", func(t *testing.T) {
+		w := newTestScraper(t)
+		if _, err := w.ContentDiff(targetURL); err == nil || errors.Is(err, ErrNoSnapshot) {
+			t.Errorf("ContentDiff() error = %v, want a missing cache error", err)
+		}
+		if _, err := os.Stat(filepath.Dir(w.getContentFilePath(targetURL))); !os.IsNotExist(err) {
+			t.Errorf("ContentDiff() created the cache directory: %v", err)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// defaultMaxDownloadBytes caps a single page or document download when MAX_DOWNLOAD_BYTES is unset
+const defaultMaxDownloadBytes = 25 << 20
+
+// DownloadTooLargeError reports a response body larger than MAX_DOWNLOAD_BYTES
+type DownloadTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+func (e *DownloadTooLargeError) Error() string {
+	return fmt.Sprintf("download of %s exceeds MAX_DOWNLOAD_BYTES (%d bytes)", e.URL, e.Limit)
+}
+
+// parseMaxDownloadBytes reads MAX_DOWNLOAD_BYTES (default: 25MB, 0 = unlimited)
+func parseMaxDownloadBytes() int64 {
+	if maxBytesStr := os.Getenv("MAX_DOWNLOAD_BYTES"); maxBytesStr != "" {
+		if parsed, err := strconv.ParseInt(maxBytesStr, 10, 64); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultMaxDownloadBytes
+}
+
+// limitBody returns the body of resp read through an io.LimitReader, so at most limit bytes are
+// ever buffered. A declared Content-Length over the limit fails before anything is read; a body
+// that turns out longer fails with DownloadTooLargeError once the limit is reached.
+func limitBody(resp *http.Response, downloadURL string, limit int64) (io.Reader, error) {
+	if limit <= 0 {
+		return resp.Body, nil
+	}
+	if resp.ContentLength > limit {
+		return nil, &DownloadTooLargeError{URL: downloadURL, Limit: limit}
+	}
+	return &limitedBody{
+		reader: io.LimitReader(resp.Body, limit+1),
+		err:    &DownloadTooLargeError{URL: downloadURL, Limit: limit},
+		left:   limit,
+	}, nil
+}
+
+// limitedBody reads one byte past the limit to tell a body of exactly limit bytes from a longer one
+type limitedBody struct {
+	reader io.Reader
+	err    error
+	left   int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if int64(n) > b.left {
+		n, b.left = int(b.left), 0
+		return n, b.err
+	}
+	b.left -= int64(n)
+	return n, err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxEmbeddedChunks bounds the chunks embedded per site, so a huge crawl doesn't take hours to embed
+const maxEmbeddedChunks = 500
+
+// ContentChunk is a passage of scraped text with its embedding, for semantic search
+type ContentChunk struct {
+	Source string // "main" or the URL of a linked page or document
+	Text   string
+	Vector []float32
+}
+
+// contentEmbedder fills WebsiteContent.Chunks; the chatbot sets it on the scraper when
+// ENABLE_SEMANTIC_SEARCH is on
+type contentEmbedder interface {
+	EmbedContent(ctx context.Context, content *WebsiteContent) error
+	EmbeddingModel() string
+}
+
+// Embed returns the embedding of text from OLLAMA_EMBED_MODEL
+func (s *OllamaService) Embed(text string) ([]float32, error) {
+	return s.embed(context.Background(), text)
+}
+
+// embed requests one embedding, sharing the generation slots and the breaker with generations
+func (s *OllamaService) embed(ctx context.Context, text string) ([]float32, error) {
+	if s.breaker.isOpen() {
+		return nil, ErrOllamaCircuitOpen
+	}
+
+	jsonData, err := json.Marshal(map[string]string{"model": s.embedModel, "prompt": text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := s.acquireSlot(ctx); err != nil {
+		return nil, err
+	}
+	defer s.releaseSlot()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL+"/api/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// A client going away says nothing about Ollama
+		if !errors.Is(err, context.Canceled) {
+			s.breaker.record(false)
+		}
+		return nil, fmt.Errorf("Ollama API error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.breaker.record(false)
+		return nil, fmt.Errorf("Ollama API returned status code: %d", resp.StatusCode)
+	}
+	s.breaker.record(true)
+
+	var embedResp struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding: %v", err)
+	}
+	if len(embedResp.Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding from Ollama API")
+	}
+	return embedResp.Embedding, nil
+}
+
+// EmbeddingModel is the model the chunks of scraped content are embedded with
+func (s *OllamaService) EmbeddingModel() string {
+	return s.embedModel
+}
+
+// EmbedContent splits the text of the main page, linked pages and documents into chunks and
+// embeds each one. It runs inside the scrape, so it gives up after SEMANTIC_EMBED_TIMEOUT_SECONDS.
+// Content is left unchanged if any chunk fails or time runs out.
+func (s *OllamaService) EmbedContent(ctx context.Context, content *WebsiteContent) error {
+	ctx, cancel := context.WithTimeout(ctx, s.embedTimeout)
+	defer cancel()
+
+	texts := sourceTexts(content)
+	var chunks []ContentChunk
+	for _, source := range sortedKeys(texts) {
+		for _, text := range chunkText(texts[source], s.chunkSize) {
+			if len(chunks) == maxEmbeddedChunks {
+				break
+			}
+			chunks = append(chunks, ContentChunk{Source: source, Text: text})
+		}
+	}
+
+	started := time.Now()
+	for i := range chunks {
+		vector, err := s.embed(ctx, chunks[i].Text)
+		if err != nil {
+			return fmt.Errorf("embedding chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		chunks[i].Vector = vector
+	}
+	warnIfSlow(s.slowOpThreshold, "Content embedding", fmt.Sprintf("%d chunks", len(chunks)), started)
+
+	content.Chunks = chunks
+	content.EmbeddingModel = s.embedModel
+	return nil
+}
+
+// semanticPassages embeds the question and renders the semanticTopK most similar chunks as a prompt
+// section, or returns "" when the content has no chunks from the current model or embedding fails
+func (s *OllamaService) semanticPassages(ctx context.Context, content *WebsiteContent, question string) string {
+	if content == nil || len(content.Chunks) == 0 || content.EmbeddingModel != s.embedModel {
+		return ""
+	}
+	query, err := s.embed(ctx, question)
+	if err != nil {
+		logWarning("embedding_failed", "Could not embed the question, answering without semantic search", "error", err)
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("MOST RELEVANT PASSAGES (semantic search over all sources):\n")
+	for _, chunk := range nearestChunks(content.Chunks, query, s.semanticTopK) {
+		b.WriteString(fmt.Sprintf("\n--- FROM: %s ---\n%s\n", chunk.Source, chunk.Text))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// nearestChunks returns the k chunks most similar to query by cosine similarity, best first
+func nearestChunks(chunks []ContentChunk, query []float32, k int) []ContentChunk {
+	type scored struct {
+		chunk ContentChunk
+		score float64
+	}
+	candidates := make([]scored, 0, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Vector) == len(query) {
+			candidates = append(candidates, scored{chunk, cosineSimilarity(chunk.Vector, query)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	nearest := make([]ContentChunk, 0, k)
+	for i := 0; i < len(candidates) && i < k; i++ {
+		nearest = append(nearest, candidates[i].chunk)
+	}
+	return nearest
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// chunkText splits text into chunks of whole lines up to size characters; longer lines are split
+func chunkText(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, line := range splitTextLines(text) {
+		for len(line) > size {
+			flush()
+			cut := size
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			chunks = append(chunks, line[:cut])
+			line = line[cut:]
+		}
+		if current.Len() > 0 && current.Len()+1+len(line) > size {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return chunks
+}
+
+// embedIfStale embeds content whose chunks are missing or from another model, reporting whether it did
+func (w *WebScraper) embedIfStale(ctx context.Context, content *WebsiteContent) bool {
+	if w.embedder == nil || extractedTextLength(content) == 0 {
+		return false
+	}
+	if len(content.Chunks) > 0 && content.EmbeddingModel == w.embedder.EmbeddingModel() {
+		return false
+	}
+	if err := w.embedder.EmbedContent(ctx, content); err != nil {
+		w.warn("embedding_failed", "Could not embed the content for semantic search", "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{name: "empty", text: "\n  \n", size: 10},
+		{name: "lines packed up to the size", text: "a\nbb\nccc", size: 5, want: []string{"a\nbb", "ccc"}},
+		{name: "blank lines dropped", text: "Go\n\n\n  Rust  \n", size: 20, want: []string{"Go\nRust"}},
+		{name: "long line split", text: "abcdefghijkl", size: 5, want: []string{"abcde", "fghij", "kl"}},
+		{name: "long line after a short one", text: "ab\ncdefgh", size: 4, want: []string{"ab", "cdef", "gh"}},
+		{name: "split on rune boundaries", text: "ääää", size: 5, want: []string{"ää", "ää"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkText(tt.text, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunkText(%q, %d) = %q, want %q", tt.text, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"same direction", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, -1}, []float32{-1, 1}, -1},
+		{"45 degrees", []float32{1, 0}, []float32{1, 1}, 1 / math.Sqrt2},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearestChunks(t *testing.T) {
+	chunks := []ContentChunk{
+		{Source: "main", Text: "east", Vector: []float32{1, 0}},
+		{Source: "main", Text: "north", Vector: []float32{0, 1}},
+		{Source: "main", Text: "north-east", Vector: []float32{1, 1}},
+		{Source: "main", Text: "other model", Vector: []float32{1, 0, 0}},
+		{Source: "main", Text: "west", Vector: []float32{-1, 0}},
+	}
+	tests := []struct {
+		name  string
+		query []float32
+		k     int
+		want  []string
+	}{
+		{"best first", []float32{1, 0.1}, 3, []string{"east", "north-east", "north"}},
+		{"k above the chunk count", []float32{0, 1}, 10, []string{"north", "north-east", "east", "west"}},
+		{"no matching dimensions", []float32{1}, 3, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, chunk := range nearestChunks(chunks, tt.query, tt.k) {
+				got = append(got, chunk.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nearestChunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedContentTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	ollama := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embeddings" {
+			http.NotFound(rw, r)
+			return
+		}
+		// An Ollama too slow to answer
+		select {
+		case <-r.Context().Done():
+		case <-stop:
+		}
+	}))
+	defer ollama.Close()
+	defer close(stop)
+	t.Setenv("OLLAMA_URL", ollama.URL)
+	t.Setenv("SEMANTIC_EMBED_TIMEOUT_SECONDS", "1")
+	s := NewOllamaService()
+
+	content := &WebsiteContent{Text: "Jane builds distributed systems in Go."}
+	started := time.Now()
+	if err := s.EmbedContent(context.Background(), content); err == nil {
+		t.Fatal("EmbedContent() succeeded against an Ollama that never answers")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("EmbedContent() took %v, want about SEMANTIC_EMBED_TIMEOUT_SECONDS", elapsed)
+	}
+	if content.Chunks != nil || content.EmbeddingModel != "" {
+		t.Errorf("content changed by a failed embedding: %d chunks, model %q", len(content.Chunks), content.EmbeddingModel)
+	}
+}
+
+func TestEmbedFailuresOpenBreaker(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "model not found", http.StatusNotFound)
+	}))
+	defer ollama.Close()
+	t.Setenv("OLLAMA_URL", ollama.URL)
+	t.Setenv("OLLAMA_BREAKER_FAILURES", "2")
+	s := NewOllamaService()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Embed("Jane"); err == nil {
+			t.Fatalf("embedding %d succeeded", i+1)
+		}
+	}
+	if !s.breaker.isOpen() {
+		t.Fatal("breaker still closed after failing embeddings")
+	}
+	if _, err := s.Embed("Jane"); !errors.Is(err, ErrOllamaCircuitOpen) {
+		t.Errorf("embedding with the breaker open: %v, want ErrOllamaCircuitOpen", err)
+	}
+	if len(s.inflight) != 0 {
+		t.Errorf("%d generation slots still held after embedding", len(s.inflight))
+	}
+}
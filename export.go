@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RenderKnowledgeMarkdown renders everything known about a site as a single Markdown document:
+// main page, linked pages, PDFs and parsed files, in the same shape the chatbot feeds to the model
+func RenderKnowledgeMarkdown(siteURL string, content *WebsiteContent) string {
+	var b strings.Builder
+
+	title := content.Title
+	if title == "" {
+		title = siteURL
+	}
+	b.WriteString(fmt.Sprintf("# %s\n\n", title))
+	b.WriteString(fmt.Sprintf("- URL: %s\n", siteURL))
+	b.WriteString(fmt.Sprintf("- Last updated: %s\n", content.LastUpdated.Format("2006-01-02 15:04:05")))
+	if content.Description != "" {
+		b.WriteString(fmt.Sprintf("- Description: %s\n", content.Description))
+	}
+	if len(content.Breadcrumbs) > 0 {
+		b.WriteString(fmt.Sprintf("- Location: %s\n", formatBreadcrumbs(content.Breadcrumbs)))
+	}
+	b.WriteString("\n")
+
+	if len(content.Metadata) > 0 {
+		b.WriteString("## Metadata\n\n")
+		for _, key := range sortedKeys(content.Metadata) {
+			b.WriteString(fmt.Sprintf("- %s: %s\n", key, content.Metadata[key]))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(content.Outline) > 0 {
+		b.WriteString("## Outline\n\n")
+		b.WriteString(formatOutline(content.Outline))
+		b.WriteString("\n")
+	}
+
+	if content.Text != "" {
+		b.WriteString("## Main Content\n\n")
+		b.WriteString(content.Text)
+		b.WriteString("\n\n")
+	}
+
+	if len(content.Reviews) > 0 {
+		b.WriteString("## User Reviews\n\n")
+		if content.ReviewSummary != "" {
+			b.WriteString(fmt.Sprintf("Overall sentiment: %s\n\n", content.ReviewSummary))
+		}
+		for _, review := range content.Reviews {
+			b.WriteString(fmt.Sprintf("- %s\n", review))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(content.Links) > 0 {
+		b.WriteString("## Links\n\n")
+		for _, link := range content.Links {
+			b.WriteString(fmt.Sprintf("- [%s](%s) (%s)\n", link.Title, link.URL, link.Type))
+			if link.PageTitle != "" || link.PageDescription != "" {
+				b.WriteString(fmt.Sprintf("  - %s\n", strings.TrimSpace(link.PageTitle+" "+link.PageDescription)))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if len(content.LinkedContent) > 0 {
+		b.WriteString("## Linked Pages\n\n")
+		for _, pageURL := range sortedKeys(content.LinkedContent) {
+			page := content.LinkedContent[pageURL]
+			b.WriteString(fmt.Sprintf("### %s\n\n", firstNonEmpty(page.Title, pageURL)))
+			b.WriteString(fmt.Sprintf("- URL: %s\n", pageURL))
+			if page.ContentType != "" {
+				b.WriteString(fmt.Sprintf("- Content type: %s\n", page.ContentType))
+			}
+			if page.Description != "" {
+				b.WriteString(fmt.Sprintf("- Description: %s\n", page.Description))
+			}
+			if len(page.Keywords) > 0 {
+				b.WriteString(fmt.Sprintf("- Keywords: %s\n", strings.Join(page.Keywords, ", ")))
+			}
+			b.WriteString("\n")
+			if page.Text != "" {
+				b.WriteString(page.Text)
+				b.WriteString("\n\n")
+			}
+			for _, firstLevel := range page.FirstLevelLinks {
+				b.WriteString(fmt.Sprintf("#### %s\n\n- URL: %s\n\n", firstNonEmpty(firstLevel.Title, firstLevel.URL), firstLevel.URL))
+				if firstLevel.Text != "" {
+					b.WriteString(firstLevel.Text)
+					b.WriteString("\n\n")
+				}
+			}
+		}
+	}
+
+	if len(content.PDFContent) > 0 {
+		b.WriteString("## PDF Documents\n\n")
+		for _, pdfURL := range sortedKeys(content.PDFContent) {
+			pdf := content.PDFContent[pdfURL]
+			b.WriteString(fmt.Sprintf("### %s\n\n", firstNonEmpty(pdf.Title, pdfURL)))
+			b.WriteString(fmt.Sprintf("- URL: %s\n- Pages: %d\n", pdfURL, pdf.Pages))
+			if pdf.Author != "" {
+				b.WriteString(fmt.Sprintf("- Author: %s\n", pdf.Author))
+			}
+			b.WriteString("\n")
+			b.WriteString(pdf.Text)
+			b.WriteString("\n\n")
+		}
+	}
+
+	if len(content.FileContent) > 0 {
+		b.WriteString("## Files\n\n")
+		for _, fileURL := range sortedKeys(content.FileContent) {
+			file := content.FileContent[fileURL]
+			b.WriteString(fmt.Sprintf("### %s\n\n", firstNonEmpty(file.FileName, fileURL)))
+			b.WriteString(fmt.Sprintf("- URL: %s\n- Type: %s\n", fileURL, strings.ToUpper(file.FileType)))
+			if len(file.SheetNames) > 0 {
+				b.WriteString(fmt.Sprintf("- Sheets: %s\n", strings.Join(file.SheetNames, ", ")))
+			}
+			for _, key := range sortedKeys(file.Metadata) {
+				b.WriteString(fmt.Sprintf("- %s: %s\n", key, file.Metadata[key]))
+			}
+			b.WriteString("\n```\n")
+			b.WriteString(strings.TrimRight(file.Text, "\n"))
+			b.WriteString("\n```\n\n")
+		}
+	}
+
+	return b.String()
+}
+
+// sortedKeys returns map keys in a stable order for deterministic output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
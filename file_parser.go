@@ -1,22 +1,40 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/csv"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"baliance.com/gooxml/document"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/tealeg/xlsx/v3"
 )
 
 type FileParser struct {
-	client *http.Client
+	client             *http.Client
+	preserveEmptyCells bool
+	maxXLSXSheets      int
+	sheetInclude       *regexp.Regexp
+	sheetExclude       *regexp.Regexp
+	useDispositionName bool
+	maxDownloadBytes   int64
+	parseHTMLFiles     bool
+	maxHTMLDepth       int
 }
 
 type FileContent struct {
@@ -31,24 +49,107 @@ type FileContent struct {
 }
 
 func NewFileParser() *FileParser {
+	// Keep positional empty CSV/XLSX cells so columns stay aligned (default: false, compact rows)
+	preserveEmptyCells := strings.ToLower(os.Getenv("PRESERVE_EMPTY_CELLS")) == "true"
+
+	// Parse maximum number of XLSX sheets parsed per workbook (default: 0, unlimited)
+	maxXLSXSheets := 0
+	if maxSheetsStr := os.Getenv("MAX_XLSX_SHEETS"); maxSheetsStr != "" {
+		if parsed, err := strconv.Atoi(maxSheetsStr); err == nil && parsed >= 0 {
+			maxXLSXSheets = parsed
+		}
+	}
+
+	// Parse optional sheet name patterns; only matching (include) and non-matching (exclude) sheets are parsed
+	sheetInclude := parseSheetPattern("XLSX_SHEET_INCLUDE_PATTERN")
+	sheetExclude := parseSheetPattern("XLSX_SHEET_EXCLUDE_PATTERN")
+
 	return &FileParser{
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		preserveEmptyCells: preserveEmptyCells,
+		maxXLSXSheets:      maxXLSXSheets,
+		sheetInclude:       sheetInclude,
+		sheetExclude:       sheetExclude,
+		useDispositionName: strings.ToLower(os.Getenv("USE_CONTENT_DISPOSITION_FILENAME")) != "false",
+		maxDownloadBytes:   parseMaxDownloadBytes(),
+		parseHTMLFiles:     strings.ToLower(os.Getenv("PARSE_HTML_FILES")) == "true",
+		maxHTMLDepth:       parseMaxHTMLDepth(),
+	}
+}
+
+// dispositionFileName returns the file name from a Content-Disposition header (filename or the
+// RFC 5987 filename*), without any directory part, or "" if there is none or the option is off
+func (p *FileParser) dispositionFileName(contentDisposition string) string {
+	if !p.useDispositionName || contentDisposition == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(contentDisposition)
+	if err != nil {
+		return ""
+	}
+	fileName := strings.TrimSpace(params["filename"])
+	if fileName == "" {
+		return ""
+	}
+
+	// Never trust directories in a server-supplied name
+	fileName = filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "." || fileName == "/" {
+		return ""
+	}
+	return fileName
+}
+
+// parseSheetPattern compiles a sheet name regex from the environment, ignoring it if invalid
+func parseSheetPattern(envName string) *regexp.Regexp {
+	pattern := os.Getenv(envName)
+	if pattern == "" {
+		return nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		logWarning("invalid_config", "Invalid pattern, ignoring it", "setting", envName, "value", pattern, "error", err)
+		return nil
+	}
+	return compiled
+}
+
+// includeSheet reports whether a sheet passes the configured name patterns
+func (p *FileParser) includeSheet(name string) bool {
+	if p.sheetInclude != nil && !p.sheetInclude.MatchString(name) {
+		return false
 	}
+	return p.sheetExclude == nil || !p.sheetExclude.MatchString(name)
 }
 
-func (p *FileParser) ParseFromURL(fileURL string) (*FileContent, error) {
-	resp, err := p.client.Get(fileURL)
+func (p *FileParser) ParseFromURL(ctx context.Context, fileURL string) (*FileContent, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch file from %s: %v", fileURL, err)
+		return nil, fmt.Errorf("failed to create request for %s: %w", fileURL, err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file from %s: %w", fileURL, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download file: status code %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to download file: %w", &HTTPStatusError{StatusCode: resp.StatusCode})
+	}
+
+	body, err := limitBody(resp, fileURL, p.maxDownloadBytes)
+	if err != nil {
+		return nil, err
 	}
+	return p.ParseFromReader(fileURL, resp.Header.Get("Content-Disposition"), body)
+}
 
+// ParseFromReader parses an already downloaded file, picking the format from the URL's extension.
+// contentDisposition is the response's Content-Disposition header, which may carry the real file name.
+func (p *FileParser) ParseFromReader(fileURL, contentDisposition string, body io.Reader) (*FileContent, error) {
 	parsedURL, err := url.Parse(fileURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %v", err)
@@ -57,13 +158,28 @@ func (p *FileParser) ParseFromURL(fileURL string) (*FileContent, error) {
 	fileName := filepath.Base(parsedURL.Path)
 	fileExt := strings.ToLower(filepath.Ext(fileName))
 
+	// Download endpoints often serve a generic path and name the file in Content-Disposition
+	if served := p.dispositionFileName(contentDisposition); served != "" {
+		fileName = served
+		if fileExt == "" {
+			fileExt = strings.ToLower(filepath.Ext(served))
+		}
+	}
+
 	switch fileExt {
 	case ".xlsx":
-		return p.parseXLSX(resp.Body, fileName)
+		return p.parseXLSX(body, fileName)
 	case ".docx":
-		return p.parseDOCX(resp.Body, fileName)
+		return p.parseDOCX(body, fileName)
+	case ".pptx":
+		return p.parsePPTX(body, fileName)
 	case ".csv":
-		return p.parseCSV(resp.Body, fileName)
+		return p.parseCSV(body, fileName)
+	case ".html", ".htm":
+		if p.parseHTMLFiles {
+			return p.parseHTML(body, fileName)
+		}
+		return nil, fmt.Errorf("unsupported file type: %s", fileExt)
 	default:
 		return nil, fmt.Errorf("unsupported file type: %s", fileExt)
 	}
@@ -89,8 +205,14 @@ func (p *FileParser) parseXLSX(reader io.Reader, fileName string) (*FileContent,
 
 	var textBuilder strings.Builder
 	var totalRows, totalCols int
+	var skippedSheets []string
 
 	for _, sheet := range wb.Sheets {
+		if !p.includeSheet(sheet.Name) || (p.maxXLSXSheets > 0 && len(content.SheetNames) >= p.maxXLSXSheets) {
+			skippedSheets = append(skippedSheets, sheet.Name)
+			continue
+		}
+
 		content.SheetNames = append(content.SheetNames, sheet.Name)
 		textBuilder.WriteString(fmt.Sprintf("=== SHEET: %s ===\n", sheet.Name))
 
@@ -109,17 +231,15 @@ func (p *FileParser) parseXLSX(reader io.Reader, fileName string) (*FileContent,
 
 			var rowData []string
 			for colIndex := 0; colIndex < maxCol; colIndex++ {
-				cell := row.GetCell(colIndex)
-				if cell != nil {
-					cellValue, _ := cell.FormattedValue()
-					if strings.TrimSpace(cellValue) != "" {
-						rowData = append(rowData, cellValue)
-					}
+				cellValue := ""
+				if cell := row.GetCell(colIndex); cell != nil {
+					cellValue, _ = cell.FormattedValue()
 				}
+				rowData = append(rowData, cellValue)
 			}
 
-			if len(rowData) > 0 {
-				textBuilder.WriteString(strings.Join(rowData, " | "))
+			if cells := p.rowCells(rowData); len(cells) > 0 {
+				textBuilder.WriteString(strings.Join(cells, " | "))
 				textBuilder.WriteString("\n")
 			}
 		}
@@ -130,6 +250,10 @@ func (p *FileParser) parseXLSX(reader io.Reader, fileName string) (*FileContent,
 	content.RowCount = totalRows
 	content.ColumnCount = totalCols
 	content.Metadata["sheets_count"] = fmt.Sprintf("%d", len(wb.Sheets))
+	if len(skippedSheets) > 0 {
+		content.Metadata["sheets_parsed"] = fmt.Sprintf("%d", len(content.SheetNames))
+		content.Metadata["sheets_skipped"] = strings.Join(skippedSheets, ", ")
+	}
 
 	return content, nil
 }
@@ -188,6 +312,336 @@ func (p *FileParser) parseDOCX(reader io.Reader, fileName string) (*FileContent,
 	return content, nil
 }
 
+// pptxSlidePattern matches the slide parts of a PPTX package and captures the slide number
+var pptxSlidePattern = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+func (p *FileParser) parsePPTX(reader io.Reader, fileName string) (*FileContent, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PPTX data: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PPTX file: %v", err)
+	}
+
+	slideFiles := pptxSlideFiles(archive)
+
+	content := &FileContent{
+		FileName:    fileName,
+		FileType:    "pptx",
+		LastUpdated: time.Now(),
+		Metadata:    make(map[string]string),
+	}
+
+	notesFiles := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		if strings.HasPrefix(file.Name, "ppt/notesSlides/") {
+			notesFiles[file.Name] = file
+		}
+	}
+
+	var textBuilder strings.Builder
+	notesCount := 0
+	for index, slideFile := range slideFiles {
+		number := index + 1
+		lines, err := readSlideText(slideFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read slide %d: %v", number, err)
+		}
+
+		textBuilder.WriteString(fmt.Sprintf("=== SLIDE %d ===\n", number))
+		for _, line := range lines {
+			textBuilder.WriteString(line)
+			textBuilder.WriteString("\n")
+		}
+
+		// Speaker notes follow the slide text
+		if notesFile := notesFiles[notesSlidePath(archive, slideFile.Name)]; notesFile != nil {
+			notes, err := readNotesText(notesFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read notes of slide %d: %v", number, err)
+			}
+			if len(notes) > 0 {
+				notesCount++
+				textBuilder.WriteString("--- NOTES ---\n")
+				for _, line := range notes {
+					textBuilder.WriteString(line)
+					textBuilder.WriteString("\n")
+				}
+			}
+		}
+		textBuilder.WriteString("\n")
+	}
+
+	content.Text = textBuilder.String()
+	content.Metadata["slides_count"] = fmt.Sprintf("%d", len(slideFiles))
+	content.Metadata["notes_count"] = fmt.Sprintf("%d", notesCount)
+
+	return content, nil
+}
+
+// readSlideText returns the non-empty paragraphs of a slide, title placeholders first and then
+// the body text of the other shapes and tables in document order
+func readSlideText(file *zip.File) ([]string, error) {
+	shapes, err := readSlideShapes(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var titles, body []string
+	for _, shape := range shapes {
+		if shape.placeholder == "title" || shape.placeholder == "ctrTitle" {
+			titles = append(titles, shape.lines...)
+		} else {
+			body = append(body, shape.lines...)
+		}
+	}
+	return append(titles, body...), nil
+}
+
+// readNotesText returns the speaker notes of a notes slide, i.e. its body placeholder, leaving
+// out the slide number, header and footer placeholders
+func readNotesText(file *zip.File) ([]string, error) {
+	shapes, err := readSlideShapes(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []string
+	for _, shape := range shapes {
+		if shape.placeholder == "body" {
+			notes = append(notes, shape.lines...)
+		}
+	}
+	return notes, nil
+}
+
+// slideShape is the text of one shape of a slide, or of text outside shapes such as tables
+type slideShape struct {
+	placeholder string // Placeholder type ("title", "body", ...), empty for other shapes
+	lines       []string
+}
+
+// readSlideShapes returns the non-empty paragraphs of a slide or notes slide grouped by shape
+func readSlideShapes(file *zip.File) ([]slideShape, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var shapes []slideShape
+	var shape slideShape
+	var paragraph strings.Builder
+	inShape, inText := false, false
+
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "sp":
+				inShape, shape = true, slideShape{}
+			case "ph":
+				// A placeholder without a type is a body placeholder
+				shape.placeholder = "body"
+				for _, attr := range element.Attr {
+					if attr.Name.Local == "type" {
+						shape.placeholder = attr.Value
+					}
+				}
+			case "p":
+				paragraph.Reset()
+			case "t":
+				inText = true
+			case "br":
+				paragraph.WriteString(" ")
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(element)
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				line := strings.TrimSpace(paragraph.String())
+				if line == "" {
+					break
+				}
+				if inShape {
+					shape.lines = append(shape.lines, line)
+				} else {
+					shapes = append(shapes, slideShape{lines: []string{line}})
+				}
+			case "sp":
+				if len(shape.lines) > 0 {
+					shapes = append(shapes, shape)
+				}
+				inShape = false
+			}
+		}
+	}
+
+	return shapes, nil
+}
+
+// notesSlidePath returns the notes slide part linked from a slide's relationships, or "" if the
+// slide has no speaker notes
+func notesSlidePath(archive *zip.Reader, slidePath string) string {
+	for _, rel := range readPartRelationships(archive, slidePath) {
+		if strings.HasSuffix(rel.Type, "/notesSlide") {
+			return rel.Target
+		}
+	}
+	return ""
+}
+
+// pptxRelationship is a relationship of a package part, with Target resolved to a part path
+type pptxRelationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// readPartRelationships returns the relationships of a part from its _rels/<part>.rels file, or
+// nil if it has none or they can't be read
+func readPartRelationships(archive *zip.Reader, partPath string) []pptxRelationship {
+	relsFile, err := archive.Open(path.Join(path.Dir(partPath), "_rels", path.Base(partPath)+".rels"))
+	if err != nil {
+		return nil
+	}
+	defer relsFile.Close()
+
+	var rels struct {
+		Relationships []pptxRelationship `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(relsFile).Decode(&rels); err != nil {
+		return nil
+	}
+	for i, rel := range rels.Relationships {
+		// Targets are relative to the part's folder, or absolute within the package
+		if strings.HasPrefix(rel.Target, "/") {
+			rels.Relationships[i].Target = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			rels.Relationships[i].Target = path.Join(path.Dir(partPath), rel.Target)
+		}
+	}
+	return rels.Relationships
+}
+
+// pptxSlideFiles returns the slide parts of a deck in presentation order, which the slide list of
+// ppt/presentation.xml gives. Slide parts keep their names when slides are reordered, so their
+// numbers are only the fallback order when the slide list can't be read.
+func pptxSlideFiles(archive *zip.Reader) []*zip.File {
+	parts := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+	if slideFiles := presentationSlideFiles(archive, parts); slideFiles != nil {
+		return slideFiles
+	}
+
+	var numbers []int
+	numbered := make(map[int]*zip.File)
+	for _, file := range archive.File {
+		if match := pptxSlidePattern.FindStringSubmatch(file.Name); match != nil {
+			number, _ := strconv.Atoi(match[1])
+			numbered[number] = file
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	slideFiles := make([]*zip.File, 0, len(numbers))
+	for _, number := range numbers {
+		slideFiles = append(slideFiles, numbered[number])
+	}
+	return slideFiles
+}
+
+// presentationSlideFiles resolves the slide IDs listed in ppt/presentation.xml to their parts
+// through ppt/_rels/presentation.xml.rels, returning nil if any step fails
+func presentationSlideFiles(archive *zip.Reader, parts map[string]*zip.File) []*zip.File {
+	const presentationPath = "ppt/presentation.xml"
+	presentationFile, err := archive.Open(presentationPath)
+	if err != nil {
+		return nil
+	}
+	defer presentationFile.Close()
+
+	var presentation struct {
+		SlideIDs []struct {
+			RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.NewDecoder(presentationFile).Decode(&presentation); err != nil || len(presentation.SlideIDs) == 0 {
+		return nil
+	}
+
+	targets := make(map[string]string)
+	for _, rel := range readPartRelationships(archive, presentationPath) {
+		targets[rel.ID] = rel.Target
+	}
+	slideFiles := make([]*zip.File, 0, len(presentation.SlideIDs))
+	for _, slideID := range presentation.SlideIDs {
+		file := parts[targets[slideID.RelationshipID]]
+		if file == nil {
+			return nil
+		}
+		slideFiles = append(slideFiles, file)
+	}
+	return slideFiles
+}
+
+// parseHTML extracts the text of a standalone HTML document, such as a report, the way linked
+// pages are walked: scripts and styles are dropped and definition lists and tables keep their structure
+func (p *FileParser) parseHTML(reader io.Reader, fileName string) (*FileContent, error) {
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTML data: %v", err)
+	}
+	text, err := decodeHTML("", raw)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML file: %v", err)
+	}
+
+	content := &FileContent{
+		FileName:    fileName,
+		FileType:    "html",
+		LastUpdated: time.Now(),
+		Metadata:    make(map[string]string),
+	}
+	if title := strings.TrimSpace(doc.Find("title").First().Text()); title != "" {
+		content.Metadata["title"] = title
+	}
+
+	// walk skips these elements but not their text in an enclosing element's text
+	doc.Find("script, style, noscript, template").Remove()
+
+	var b strings.Builder
+	doc.Find("body").Each(func(i int, s *goquery.Selection) {
+		walk(&b, s.Nodes[0], 0, walkOptions{structured: true, maxDepth: p.maxHTMLDepth})
+	})
+	content.Text = normalizeWhitespace(b.String(), WhitespacePreserve)
+
+	return content, nil
+}
+
 func (p *FileParser) parseCSV(reader io.Reader, fileName string) (*FileContent, error) {
 	csvReader := csv.NewReader(reader)
 	csvReader.FieldsPerRecord = -1
@@ -216,15 +670,7 @@ func (p *FileParser) parseCSV(reader io.Reader, fileName string) (*FileContent,
 			maxCols = len(record)
 		}
 
-		var cleanRecord []string
-		for _, field := range record {
-			field = strings.TrimSpace(field)
-			if field != "" {
-				cleanRecord = append(cleanRecord, field)
-			}
-		}
-
-		if len(cleanRecord) > 0 {
+		if cleanRecord := p.rowCells(record); len(cleanRecord) > 0 {
 			textBuilder.WriteString(strings.Join(cleanRecord, " | "))
 			textBuilder.WriteString("\n")
 		}
@@ -239,6 +685,28 @@ func (p *FileParser) parseCSV(reader io.Reader, fileName string) (*FileContent,
 	return content, nil
 }
 
+// rowCells trims row cells, keeping empty ones as placeholders when preserveEmptyCells is set
+// so values stay aligned with their columns. Rows without any value return nil.
+func (p *FileParser) rowCells(row []string) []string {
+	var cells []string
+	hasValue := false
+
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell != "" {
+			hasValue = true
+		} else if !p.preserveEmptyCells {
+			continue
+		}
+		cells = append(cells, cell)
+	}
+
+	if !hasValue {
+		return nil
+	}
+	return cells
+}
+
 func (p *FileParser) ExtractKeyInformation(content *FileContent) map[string]string {
 	info := make(map[string]string)
 	text := strings.ToLower(content.Text)
@@ -266,7 +734,7 @@ func (p *FileParser) ExtractKeyInformation(content *FileContent) map[string]stri
 		info["detected_skills"] = strings.Join(skills, ", ")
 	}
 
-	dataTypes := p.detectDataTypes(text)
+	dataTypes := detectDataTypes(text)
 	if len(dataTypes) > 0 {
 		info["data_types"] = strings.Join(dataTypes, ", ")
 	}
@@ -296,7 +764,8 @@ func (p *FileParser) extractSkills(text string) []string {
 	return skills
 }
 
-func (p *FileParser) detectDataTypes(text string) []string {
+// detectDataTypes returns the kinds of data mentioned in lowercased file text (email, financial, resume_data, ...)
+func detectDataTypes(text string) []string {
 	var dataTypes []string
 
 	if strings.Contains(text, "email") || strings.Contains(text, "@") {
@@ -333,5 +802,7 @@ func (p *FileParser) isValidFileURL(rawURL string) bool {
 	path := strings.ToLower(parsedURL.Path)
 	return strings.HasSuffix(path, ".xlsx") ||
 		strings.HasSuffix(path, ".docx") ||
-		strings.HasSuffix(path, ".csv")
+		strings.HasSuffix(path, ".pptx") ||
+		strings.HasSuffix(path, ".csv") ||
+		(p.parseHTMLFiles && (strings.HasSuffix(path, ".html") || strings.HasSuffix(path, ".htm")))
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildZip packages the given parts, e.g. the XML parts of an Office document
+func buildZip(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, data := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := part.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pptxShape is a slide shape with the given placeholder type ("" for none) and paragraphs
+func pptxShape(placeholder string, paragraphs ...string) string {
+	var b strings.Builder
+	b.WriteString("<p:sp><p:nvSpPr><p:nvPr>")
+	if placeholder != "" {
+		fmt.Fprintf(&b, `<p:ph type="%s"/>`, placeholder)
+	}
+	b.WriteString("</p:nvPr></p:nvSpPr><p:txBody>")
+	for _, paragraph := range paragraphs {
+		fmt.Fprintf(&b, "<a:p><a:r><a:t>%s</a:t></a:r></a:p>", paragraph)
+	}
+	b.WriteString("</p:txBody></p:sp>")
+	return b.String()
+}
+
+func pptxSlide(shapes ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+		`xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:cSld><p:spTree>` +
+		strings.Join(shapes, "") + "</p:spTree></p:cSld></p:sld>"
+}
+
+func TestParsePPTX(t *testing.T) {
+	deck := buildZip(t, map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		// The body shape comes before the title in the document; the title is still listed first
+		"ppt/slides/slide1.xml":  pptxSlide(pptxShape("", "Built with Go"), pptxShape("title", "Jane Doe")),
+		"ppt/slides/slide2.xml":  pptxSlide(pptxShape("title", "Experience"), pptxShape("body", "ACME Corp", "Globex")),
+		"ppt/slides/slide10.xml": pptxSlide(pptxShape("ctrTitle", "Thank you")),
+	})
+
+	content, err := NewFileParser().ParseFromReader("https://example.com/talk.pptx", "", bytes.NewReader(deck))
+	if err != nil {
+		t.Fatalf("ParseFromReader: %v", err)
+	}
+
+	want := "=== SLIDE 1 ===\nJane Doe\nBuilt with Go\n\n" +
+		"=== SLIDE 2 ===\nExperience\nACME Corp\nGlobex\n\n" +
+		"=== SLIDE 3 ===\nThank you\n\n"
+	if content.Text != want {
+		t.Errorf("text =\n%q\nwant\n%q", content.Text, want)
+	}
+	if content.FileType != "pptx" {
+		t.Errorf("FileType = %q, want pptx", content.FileType)
+	}
+	if got := content.Metadata["slides_count"]; got != "3" {
+		t.Errorf("slides_count = %q, want 3", got)
+	}
+}
+
+func TestParsePPTXPresentationOrder(t *testing.T) {
+	// Slides were reordered after they were created, and a deleted slide's part was left behind
+	presentation := `<?xml version="1.0" encoding="UTF-8"?><p:presentation ` +
+		`xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><p:sldIdLst>` +
+		`<p:sldId id="258" r:id="rId4"/><p:sldId id="256" r:id="rId2"/><p:sldId id="257" r:id="rId3"/>` +
+		`</p:sldIdLst></p:presentation>`
+	rels := `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideMaster" Target="slideMasters/slideMaster1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide3.xml"/>` +
+		`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="/ppt/slides/slide1.xml"/>` +
+		`<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide10.xml"/>` +
+		`</Relationships>`
+
+	tests := []struct {
+		name  string
+		parts map[string]string
+		want  string
+	}{
+		{
+			name: "slide list",
+			parts: map[string]string{
+				"ppt/presentation.xml":            presentation,
+				"ppt/_rels/presentation.xml.rels": rels,
+			},
+			want: "=== SLIDE 1 ===\nIntroduction\n\n=== SLIDE 2 ===\nProjects\n\n=== SLIDE 3 ===\nThank you\n\n",
+		},
+		{
+			name: "slide list without relationships falls back to part numbers",
+			parts: map[string]string{
+				"ppt/presentation.xml": presentation,
+			},
+			want: "=== SLIDE 1 ===\nThank you\n\n=== SLIDE 2 ===\nDeleted\n\n=== SLIDE 3 ===\nProjects\n\n=== SLIDE 4 ===\nIntroduction\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := map[string]string{
+				"ppt/slides/slide1.xml":  pptxSlide(pptxShape("title", "Thank you")),
+				"ppt/slides/slide2.xml":  pptxSlide(pptxShape("title", "Deleted")),
+				"ppt/slides/slide3.xml":  pptxSlide(pptxShape("title", "Projects")),
+				"ppt/slides/slide10.xml": pptxSlide(pptxShape("title", "Introduction")),
+			}
+			for name, data := range tt.parts {
+				parts[name] = data
+			}
+
+			content, err := NewFileParser().ParseFromReader("https://example.com/talk.pptx", "", bytes.NewReader(buildZip(t, parts)))
+			if err != nil {
+				t.Fatalf("ParseFromReader: %v", err)
+			}
+			if content.Text != tt.want {
+				t.Errorf("text =\n%q\nwant\n%q", content.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePPTXSpeakerNotes(t *testing.T) {
+	notesRels := `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideLayout" Target="../slideLayouts/slideLayout1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide" Target="../notesSlides/notesSlide7.xml"/>` +
+		`</Relationships>`
+	notes := strings.Replace(pptxSlide(
+		pptxShape("sldImg"),
+		pptxShape("body", "Mention the Go meetup", "Keep it under a minute"),
+		pptxShape("sldNum", "1"),
+	), "p:sld ", "p:notes ", 1)
+	notes = strings.Replace(notes, "</p:sld>", "</p:notes>", 1)
+
+	deck := buildZip(t, map[string]string{
+		"ppt/slides/slide1.xml":             pptxSlide(pptxShape("title", "Jane Doe")),
+		"ppt/slides/_rels/slide1.xml.rels":  notesRels,
+		"ppt/notesSlides/notesSlide7.xml":   notes,
+		"ppt/slides/slide2.xml":             pptxSlide(pptxShape("title", "Experience")),
+		"ppt/notesSlides/notesSlide8.xml":   notes, // Not linked from any slide
+		"ppt/slideLayouts/slideLayout1.xml": pptxSlide(pptxShape("title", "Layout title")),
+	})
+
+	content, err := NewFileParser().ParseFromReader("https://example.com/talk.pptx", "", bytes.NewReader(deck))
+	if err != nil {
+		t.Fatalf("ParseFromReader: %v", err)
+	}
+
+	want := "=== SLIDE 1 ===\nJane Doe\n--- NOTES ---\nMention the Go meetup\nKeep it under a minute\n\n" +
+		"=== SLIDE 2 ===\nExperience\n\n"
+	if content.Text != want {
+		t.Errorf("text =\n%q\nwant\n%q", content.Text, want)
+	}
+	if got := content.Metadata["notes_count"]; got != "1" {
+		t.Errorf("notes_count = %q, want 1", got)
+	}
+}
+
+func TestParseHTMLFile(t *testing.T) {
+	report := `<html><head><title>Annual Report</title><style>p { color: red; }</style></head><body>
+		<h1>Annual Report</h1>
+		<p>Revenue grew<script>trackRevenue();</script> by 12%.</p>
+		<noscript>Enable JavaScript</noscript>
+		<dl><dt>Employees</dt><dd>42</dd></dl>
+		<script>var secret = "tracking";</script>
+	</body></html>`
+
+	t.Setenv("PARSE_HTML_FILES", "true")
+	content, err := NewFileParser().ParseFromReader("https://example.com/report.html", "", strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("ParseFromReader: %v", err)
+	}
+
+	if content.FileType != "html" {
+		t.Errorf("FileType = %q, want html", content.FileType)
+	}
+	if got := content.Metadata["title"]; got != "Annual Report" {
+		t.Errorf("title = %q, want Annual Report", got)
+	}
+	for _, want := range []string{"Revenue grew by 12%.", "Employees: 42"} {
+		if !strings.Contains(content.Text, want) {
+			t.Errorf("text = %q, want it to contain %q", content.Text, want)
+		}
+	}
+	for _, unwanted := range []string{"trackRevenue", "secret", "color: red", "Enable JavaScript"} {
+		if strings.Contains(content.Text, unwanted) {
+			t.Errorf("text = %q, want no %q", content.Text, unwanted)
+		}
+	}
+}
+
+func TestParseHTMLFileDisabled(t *testing.T) {
+	t.Setenv("PARSE_HTML_FILES", "false")
+	if _, err := NewFileParser().ParseFromReader("https://example.com/report.html", "", strings.NewReader("<p>Report</p>")); err == nil {
+		t.Error("parsed an HTML file with PARSE_HTML_FILES=false, want an unsupported file type error")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostLimiter is a token bucket rate limiter per host, so a crawl spreads its requests
+// to one server over time while requests to other hosts proceed independently
+type hostLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // Tokens added per second, 0 or less disables limiting
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newHostLimiter(requestsPerSecond float64) *hostLimiter {
+	burst := requestsPerSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &hostLimiter{
+		rate:      requestsPerSecond,
+		burst:     burst,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// wait blocks until a request to host is allowed, or returns ctx.Err() if ctx is cancelled first.
+// A token is reserved right away, so concurrent callers for a host are spaced out in call order.
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	if l.rate <= 0 || host == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.sweep(now)
+	bucket, exists := l.buckets[host]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[host] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+	bucket.tokens--
+	deficit := -bucket.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	if !sleepContext(ctx, time.Duration(deficit/l.rate*float64(time.Second))) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// sweep drops, at most once a minute, the buckets of hosts idle long enough to be full again, so
+// a long-running server crawling many hosts doesn't keep a bucket for each one forever
+func (l *hostLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for host, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, host)
+		}
+	}
+}
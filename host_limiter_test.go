@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterSweepsIdleHosts(t *testing.T) {
+	l := newHostLimiter(2)
+	for _, host := range []string{"idle.example.com", "busy.example.com"} {
+		if err := l.wait(context.Background(), host); err != nil {
+			t.Fatalf("wait(%s): %v", host, err)
+		}
+	}
+
+	// One host has been idle long enough for its bucket to refill, the other was just used
+	now := time.Now()
+	l.buckets["idle.example.com"].last = now.Add(-time.Hour)
+	l.lastSweep = now.Add(-2 * time.Minute)
+	if err := l.wait(context.Background(), "busy.example.com"); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	if _, exists := l.buckets["idle.example.com"]; exists {
+		t.Error("idle host bucket was kept")
+	}
+	if _, exists := l.buckets["busy.example.com"]; !exists {
+		t.Error("busy host bucket was dropped")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Prompt section categories, used to prioritize content for a question
+const (
+	SectionMain     = "main"
+	SectionMetadata = "metadata"
+	SectionLinks    = "links"
+	SectionLinked   = "linked"
+	SectionPDF      = "pdf"
+	SectionFile     = "file"
+	SectionReviews  = "reviews"
+	SectionContacts = "contacts"
+	SectionPassages = "passages" // Chunks found by semantic search
+)
+
+// Intent is a kind of question together with the content categories that answer it best
+type Intent struct {
+	Name     string
+	Keywords []string
+	Priority []string // Section categories in order of importance
+}
+
+// IntentClassifier matches questions to intents by keyword, a lightweight alternative to embeddings
+type IntentClassifier struct {
+	intents []Intent
+}
+
+func defaultIntents() []Intent {
+	return []Intent{
+		{
+			Name:     "contact",
+			Keywords: []string{"contact", "email", "e mail", "phone", "reach", "url", "link", "profile", "social", "github", "linkedin", "twitter"},
+			Priority: []string{SectionContacts, SectionLinks, SectionMetadata, SectionMain},
+		},
+		{
+			Name:     "cv",
+			Keywords: []string{"skill", "experience", "education", "degree", "university", "cv", "resume", "job", "career", "qualification", "certific"},
+			Priority: []string{SectionPDF, SectionFile, SectionMain},
+		},
+		{
+			Name:     "projects",
+			Keywords: []string{"project", "repo", "code", "open source", "blog", "article", "post", "wrote"},
+			Priority: []string{SectionLinked, SectionMain, SectionLinks},
+		},
+		{
+			Name:     "data",
+			Keywords: []string{"spreadsheet", "table", "sheet", "csv", "xlsx", "docx", "pptx", "slides", "file", "document", "data"},
+			Priority: []string{SectionFile, SectionPDF, SectionMain},
+		},
+		{
+			Name:     "reviews",
+			Keywords: []string{"review", "people say", "customers say", "opinion", "rating", "rated", "feedback", "testimonial", "recommend", "comment"},
+			Priority: []string{SectionReviews, SectionMain},
+		},
+	}
+}
+
+// NewIntentClassifier creates a classifier with the built-in intents. Keywords can be replaced per
+// intent with a spec like "contact:email,phone;cv:skills,degree"; unknown intent names are ignored.
+func NewIntentClassifier(keywordSpec string) *IntentClassifier {
+	intents := defaultIntents()
+
+	for _, entry := range strings.Split(keywordSpec, ";") {
+		name, keywords, found := strings.Cut(entry, ":")
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		var parsed []string
+		for _, keyword := range strings.Split(keywords, ",") {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				parsed = append(parsed, keyword)
+			}
+		}
+
+		for i := range intents {
+			if intents[i].Name == name && len(parsed) > 0 {
+				intents[i].Keywords = parsed
+			}
+		}
+	}
+
+	return &IntentClassifier{intents: intents}
+}
+
+var nonWordChars = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// Classify returns the intents whose keywords start a word of the message, best match first
+func (c *IntentClassifier) Classify(message string) []Intent {
+	// Pad words with spaces so " skill" matches "skills" but " file" doesn't match "profile"
+	lowerMsg := " " + nonWordChars.ReplaceAllString(strings.ToLower(message), " ")
+
+	var matched []Intent
+	scores := make(map[string]int)
+	for _, intent := range c.intents {
+		for _, keyword := range intent.Keywords {
+			if strings.Contains(lowerMsg, " "+keyword) {
+				scores[intent.Name]++
+			}
+		}
+		if scores[intent.Name] > 0 {
+			matched = append(matched, intent)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return scores[matched[i].Name] > scores[matched[j].Name]
+	})
+	return matched
+}
+
+// prioritizeSections moves the sections favored by the matched intents to the front, so they
+// survive truncation of the prompt; the remaining sections keep their default order
+func prioritizeSections(sections []promptSection, intents []Intent) []promptSection {
+	if len(intents) == 0 {
+		return sections
+	}
+
+	rank := make(map[string]int)
+	for _, intent := range intents {
+		for _, category := range intent.Priority {
+			if _, exists := rank[category]; !exists {
+				rank[category] = len(rank)
+			}
+		}
+	}
+
+	sectionRank := func(section promptSection) int {
+		if position, exists := rank[section.category]; exists {
+			return position
+		}
+		return len(rank)
+	}
+
+	ordered := append([]promptSection(nil), sections...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return sectionRank(ordered[i]) < sectionRank(ordered[j])
+	})
+	return ordered
+}
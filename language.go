@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// elementLang returns an element's lang (or xml:lang) attribute, lowercased, or the language it
+// inherits from its ancestors
+func elementLang(n *html.Node, inherited string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == "lang" || attr.Key == "xml:lang" {
+			if lang := strings.ToLower(strings.TrimSpace(attr.Val)); lang != "" {
+				return lang
+			}
+		}
+	}
+	return inherited
+}
+
+// documentLang returns the lang attribute of a document's <html> element
+func documentLang(doc *goquery.Document) string {
+	if root := doc.Find("html").First(); root.Length() > 0 {
+		return elementLang(root.Nodes[0], "")
+	}
+	return ""
+}
+
+// countLanguages adds the characters of visible text under n to counts, keyed by the nearest lang
+// attribute. Text without a known language isn't counted. Nodes are visited from an explicit stack,
+// so deeply nested pages can't grow the call stack.
+func countLanguages(n *html.Node, lang string, counts map[string]int) {
+	type visit struct {
+		node *html.Node
+		lang string
+	}
+	stack := []visit{{node: n, lang: lang}}
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		lang := next.lang
+		switch next.node.Type {
+		case html.TextNode:
+			if lang != "" {
+				counts[lang] += utf8.RuneCountInString(strings.TrimSpace(next.node.Data))
+			}
+			continue
+		case html.ElementNode:
+			if tag := next.node.Data; tag == "script" || tag == "style" || tag == "noscript" || tag == "template" {
+				continue
+			}
+			lang = elementLang(next.node, lang)
+		}
+		for c := next.node.FirstChild; c != nil; c = c.NextSibling {
+			stack = append(stack, visit{node: c, lang: lang})
+		}
+	}
+}
+
+// languageHints returns the language with the most text and all languages that have text, most
+// text first
+func languageHints(counts map[string]int) (dominant string, languages []string) {
+	for lang, chars := range counts {
+		if chars > 0 {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if counts[languages[i]] != counts[languages[j]] {
+			return counts[languages[i]] > counts[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+	if len(languages) == 0 {
+		return "", nil
+	}
+	return languages[0], languages
+}
+
+// setLanguageMetadata stores the main page's dominant language as "language" metadata and, for
+// pages mixing languages, all of them as "languages"
+func setLanguageMetadata(doc *goquery.Document, content *WebsiteContent) {
+	counts := make(map[string]int)
+	lang := documentLang(doc)
+	doc.Find("body").Each(func(i int, s *goquery.Selection) {
+		countLanguages(s.Nodes[0], lang, counts)
+	})
+
+	dominant, languages := languageHints(counts)
+	if dominant == "" {
+		return
+	}
+	content.Metadata["language"] = dominant
+	if len(languages) > 1 {
+		content.Metadata["languages"] = strings.Join(languages, ", ")
+	}
+}
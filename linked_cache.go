@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// linkedPageTTL is how long a linked page saved to disk is reused, matching the main page disk cache
+const linkedPageTTL = 24 * time.Hour
+
+// cachedLinkedPage is the on-disk form of a scraped linked page. NestedURLs lists the pages
+// that were scraped from it, so they can be followed again when the page itself is reused.
+type cachedLinkedPage struct {
+	URL        string             `json:"url"`
+	SavedAt    time.Time          `json:"saved_at"`
+	Content    *LinkedPageContent `json:"content"`
+	NestedURLs []string           `json:"nested_urls,omitempty"`
+}
+
+// getLinkedPageFilePath returns the file path for storing a linked page
+func (w *WebScraper) getLinkedPageFilePath(targetUrl string) string {
+	return filepath.Join(w.cacheDir, w.generateSafeDirectoryName(targetUrl), "linked.json")
+}
+
+// saveLinkedPageToDisk persists a linked page so later sessions don't fetch it again within linkedPageTTL
+func (w *WebScraper) saveLinkedPageToDisk(targetUrl string, content *LinkedPageContent, nestedURLs []string) error {
+	filePath := w.getLinkedPageFilePath(targetUrl)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(cachedLinkedPage{
+		URL:        targetUrl,
+		SavedAt:    time.Now(),
+		Content:    content,
+		NestedURLs: nestedURLs,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal linked page: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return nil
+}
+
+// loadLinkedPageFromDisk returns a linked page saved less than linkedPageTTL ago, or nil
+func (w *WebScraper) loadLinkedPageFromDisk(targetUrl string) *cachedLinkedPage {
+	data, err := ioutil.ReadFile(w.getLinkedPageFilePath(targetUrl))
+	if err != nil {
+		return nil
+	}
+
+	var cached cachedLinkedPage
+	if err := json.Unmarshal(data, &cached); err != nil {
+		w.warn("cache_read_failed", "Ignoring unreadable cached linked page", "url", targetUrl, "error", err)
+		return nil
+	}
+	if cached.Content == nil || time.Since(cached.SavedAt) >= linkedPageTTL {
+		return nil
+	}
+	return &cached
+}
@@ -4,11 +4,24 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	// Apply a named scrape preset before anything reads the environment
+	if profile := os.Getenv("SCRAPE_PROFILE"); profile != "" {
+		profilesPath := os.Getenv("SCRAPE_PROFILES_FILE")
+		if profilesPath == "" {
+			profilesPath = "scrape_profiles.json"
+		}
+		if err := applyScrapeProfile(profilesPath, profile); err != nil {
+			log.Fatalf("Failed to apply scrape profile: %v", err)
+		}
+		log.Printf("Using scrape profile: %s", profile)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -19,6 +32,10 @@ func main() {
 		log.Fatal("WEBSITE_URL environment variable is required")
 	}
 
+	if _, err := parseProxyURL(os.Getenv("HTTP_PROXY_URL")); err != nil {
+		log.Fatalf("HTTP_PROXY_URL: %v", err)
+	}
+
 	scraper := NewWebScraper()
 	ollamaService := NewOllamaService()
 	chatbot := NewChatbot(scraper, ollamaService)
@@ -28,6 +45,7 @@ func main() {
 	server.SetupRoutes(r)
 
 	log.Printf("Target website: %s", websiteURL)
+	log.Printf("Professional link domains: %s", strings.Join(scraper.ProfessionalDomains(), ", "))
 
 	if ollamaService.IsEnabled() {
 		log.Println("Ollama CodeLlama integration enabled")
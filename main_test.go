@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain runs the tests in a temporary directory, so the scraped_content cache directory that
+// NewWebScraper creates doesn't end up in the source tree
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "chatbot-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrOllamaCircuitOpen is returned instead of generating while Ollama is known to be down
+var ErrOllamaCircuitOpen = errors.New("Ollama is unavailable, waiting for the breaker cooldown")
+
+// ollamaBreaker keeps a down or cold-starting Ollama from adding latency to every request. Health
+// probe results are reused for probeInterval, and after failureThreshold consecutive failed probes
+// or generations the breaker opens: Ollama is reported unavailable without contacting it until
+// cooldown has passed, then the next probe decides whether it closes again.
+type ollamaBreaker struct {
+	probeInterval    time.Duration
+	failureThreshold int // 0 disables the breaker
+	cooldown         time.Duration
+
+	mu        sync.Mutex
+	lastProbe time.Time
+	healthy   bool
+	probing   bool // A probe is in flight; concurrent callers reuse the last result
+	failures  int  // Consecutive failed probes and generations
+	openUntil time.Time
+}
+
+func newOllamaBreaker() *ollamaBreaker {
+	// Parse how long a health probe result is reused (default: 5s, 0 = probe on every check)
+	probeInterval := 5 * time.Second
+	if intervalStr := os.Getenv("OLLAMA_PROBE_INTERVAL_SECONDS"); intervalStr != "" {
+		if parsed, err := strconv.Atoi(intervalStr); err == nil && parsed >= 0 {
+			probeInterval = time.Duration(parsed) * time.Second
+		}
+	}
+
+	// Parse consecutive failures that open the breaker (default: 3, 0 = never open)
+	failureThreshold := 3
+	if thresholdStr := os.Getenv("OLLAMA_BREAKER_FAILURES"); thresholdStr != "" {
+		if parsed, err := strconv.Atoi(thresholdStr); err == nil && parsed >= 0 {
+			failureThreshold = parsed
+		}
+	}
+
+	// Parse how long an open breaker answers with the fallback before probing again (default: 30s)
+	cooldown := 30 * time.Second
+	if cooldownStr := os.Getenv("OLLAMA_BREAKER_COOLDOWN_SECONDS"); cooldownStr != "" {
+		if parsed, err := strconv.Atoi(cooldownStr); err == nil && parsed > 0 {
+			cooldown = time.Duration(parsed) * time.Second
+		}
+	}
+
+	return &ollamaBreaker{
+		probeInterval:    probeInterval,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// isOpen reports whether calls to Ollama are currently short-circuited
+func (b *ollamaBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// check returns Ollama's health, running probe only when the last result is older than
+// probeInterval, no other probe is running and the breaker isn't open
+func (b *ollamaBreaker) check(probe func() bool) bool {
+	b.mu.Lock()
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		b.mu.Unlock()
+		return false
+	}
+	if b.probing || (!b.lastProbe.IsZero() && now.Sub(b.lastProbe) < b.probeInterval) {
+		healthy := b.healthy
+		b.mu.Unlock()
+		return healthy
+	}
+	b.probing = true
+	b.mu.Unlock()
+
+	healthy := probe()
+
+	b.mu.Lock()
+	b.probing = false
+	b.lastProbe = time.Now()
+	b.healthy = healthy
+	b.mu.Unlock()
+	b.record(healthy)
+	return healthy
+}
+
+// record counts a probe or generation outcome, opening the breaker after failureThreshold
+// consecutive failures
+func (b *ollamaBreaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failureThreshold > 0 && b.failures >= b.failureThreshold && !time.Now().Before(b.openUntil) {
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.failures = 0
+		b.healthy = false
+		logWarning("ollama_breaker_open", "Ollama keeps failing, answering with the fallback", "cooldown", b.cooldown)
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"os"
-	regexp "regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,14 +20,58 @@ import (
 type OllamaService struct {
 	baseURL               string
 	model                 string
+	largeModel            string // Model for prompts longer than largeModelThreshold ("" disables routing)
+	largeModelThreshold   int
+	largeModelNumCtx      int
 	maxTotalContentLength int // Max length of content to send to Ollama
+	budgetMode            string
+	whitespacePolicy      string
+	intentRouting         bool
+	intentClassifier      *IntentClassifier
+	slowOpThreshold       time.Duration
+	recencyWeight         float64 // 0-1 share of recency (vs. relevance) when ordering linked pages
+	includeDataTypes      bool
+	returnPartial         bool // Stream generations and keep the tokens received before a timeout
+	includeFailedSources  bool
 	client                *http.Client
+	inflight              chan struct{} // Semaphore bounding concurrent generations
+	queue                 chan struct{} // Requests waiting for a free generation slot
+	breaker               *ollamaBreaker
+	semanticSearch        bool
+	embedModel            string
+	semanticTopK          int
+	chunkSize             int
+	embedTimeout          time.Duration // Bound of embedding one site's content, which holds up its scrape
 }
 
+// ErrOllamaQueueFull is returned when all generation slots are busy and the wait queue is full
+var ErrOllamaQueueFull = errors.New("Ollama request queue is full")
+
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Stream  bool                   `json:"stream"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// OllamaOptions overrides the service's generation defaults for one request; unset fields keep them
+type OllamaOptions struct {
+	Model       string   // Replaces OLLAMA_MODEL and large model routing
+	Temperature *float64 // Sent as the "temperature" option
+}
+
+// RequestStats describes how a chat answer was produced, for tuning the content budget and model settings
+type RequestStats struct {
+	PromptBytes          int      `json:"prompt_bytes"`
+	PromptTokensEstimate int      `json:"prompt_tokens_estimate"` // About 4 bytes per token
+	SourcesIncluded      []string `json:"sources_included"`       // Content categories in the prompt, in prompt order
+	SourcesTruncated     []string `json:"sources_truncated,omitempty"`
+	SourceCategories     []string `json:"source_categories,omitempty"` // Sources with linked pages by ContentType, e.g. "project"
+	Model                string   `json:"model,omitempty"`
+	GenerationMillis     int64    `json:"generation_ms"`
+	Fallback             bool     `json:"fallback"`             // Answered without the model
+	NoContent            bool     `json:"no_content,omitempty"` // No site content could be loaded
+	Partial              bool     `json:"partial,omitempty"`    // Generation timed out, the answer is cut short
 }
 
 type OllamaResponse struct {
@@ -44,6 +92,29 @@ func NewOllamaService() *OllamaService {
 		model = "codellama:13b"
 	}
 
+	// Parse size-based routing to a model with a bigger context window
+	largeModel := os.Getenv("OLLAMA_LARGE_MODEL")
+	largeModelThreshold := 8000
+	if thresholdStr := os.Getenv("OLLAMA_LARGE_MODEL_THRESHOLD"); thresholdStr != "" {
+		if parsed, err := strconv.Atoi(thresholdStr); err == nil && parsed > 0 {
+			largeModelThreshold = parsed
+		}
+	}
+	largeModelNumCtx := 16384
+	if numCtxStr := os.Getenv("OLLAMA_LARGE_MODEL_NUM_CTX"); numCtxStr != "" {
+		if parsed, err := strconv.Atoi(numCtxStr); err == nil && parsed > 0 {
+			largeModelNumCtx = parsed
+		}
+	}
+
+	// Parse how much page recency counts against relevance when ordering linked pages (default: 0)
+	recencyWeight := 0.0
+	if weightStr := os.Getenv("RECENCY_WEIGHT"); weightStr != "" {
+		if parsed, err := strconv.ParseFloat(weightStr, 64); err == nil && parsed >= 0 && parsed <= 1 {
+			recencyWeight = parsed
+		}
+	}
+
 	// Parse maximum total text length (default: 20000)
 	maxTotalContentLength := 20000
 	if maxContentLengthStr := os.Getenv("MAX_TOTAL_CONTENT_LENGTH"); maxContentLengthStr != "" {
@@ -52,17 +123,156 @@ func NewOllamaService() *OllamaService {
 		}
 	}
 
+	// Parse how the content length limit is shared between sources (default: global)
+	budgetMode := BudgetGlobal
+	if strings.ToLower(os.Getenv("CONTENT_BUDGET_MODE")) == BudgetPerSource {
+		budgetMode = BudgetPerSource
+	}
+
+	// Parse maximum concurrent generations (default: 2)
+	maxInflight := 2
+	if maxInflightStr := os.Getenv("OLLAMA_MAX_INFLIGHT"); maxInflightStr != "" {
+		if parsed, err := strconv.Atoi(maxInflightStr); err == nil && parsed > 0 {
+			maxInflight = parsed
+		}
+	}
+
+	// Parse maximum number of queued generations (default: 10)
+	queueDepth := 10
+	if queueDepthStr := os.Getenv("OLLAMA_QUEUE_DEPTH"); queueDepthStr != "" {
+		if parsed, err := strconv.Atoi(queueDepthStr); err == nil && parsed >= 0 {
+			queueDepth = parsed
+		}
+	}
+
+	// Parse connection reuse settings for the Ollama client (defaults: one idle connection per
+	// generation slot plus one for health checks, kept for 90s, TCP keep-alive every 30s)
+	maxIdleConnsPerHost := maxInflight + 1
+	if maxIdleStr := os.Getenv("OLLAMA_MAX_IDLE_CONNS_PER_HOST"); maxIdleStr != "" {
+		if parsed, err := strconv.Atoi(maxIdleStr); err == nil && parsed > 0 {
+			maxIdleConnsPerHost = parsed
+		}
+	}
+	idleConnTimeout := 90 * time.Second
+	if idleTimeoutStr := os.Getenv("OLLAMA_IDLE_CONN_TIMEOUT_SECONDS"); idleTimeoutStr != "" {
+		if parsed, err := strconv.Atoi(idleTimeoutStr); err == nil && parsed >= 0 {
+			idleConnTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+	keepAlive := 30 * time.Second
+	if keepAliveStr := os.Getenv("OLLAMA_KEEP_ALIVE_SECONDS"); keepAliveStr != "" {
+		if parsed, err := strconv.Atoi(keepAliveStr); err == nil {
+			keepAlive = time.Duration(parsed) * time.Second // Negative disables TCP keep-alives
+		}
+	}
+
+	// Parse semantic search settings (defaults: nomic-embed-text, top 5 chunks of up to 800 characters,
+	// 120 seconds to embed a site)
+	embedModel := os.Getenv("OLLAMA_EMBED_MODEL")
+	if embedModel == "" {
+		embedModel = "nomic-embed-text"
+	}
+	semanticTopK := 5
+	if topKStr := os.Getenv("SEMANTIC_TOP_K"); topKStr != "" {
+		if parsed, err := strconv.Atoi(topKStr); err == nil && parsed > 0 {
+			semanticTopK = parsed
+		}
+	}
+	chunkSize := 800
+	if chunkSizeStr := os.Getenv("SEMANTIC_CHUNK_SIZE"); chunkSizeStr != "" {
+		if parsed, err := strconv.Atoi(chunkSizeStr); err == nil && parsed >= 100 {
+			chunkSize = parsed
+		}
+	}
+	embedTimeout := 120 * time.Second
+	if embedTimeoutStr := os.Getenv("SEMANTIC_EMBED_TIMEOUT_SECONDS"); embedTimeoutStr != "" {
+		if parsed, err := strconv.Atoi(embedTimeoutStr); err == nil && parsed > 0 {
+			embedTimeout = time.Duration(parsed) * time.Second
+		}
+	}
+
 	return &OllamaService{
 		baseURL:               baseURL,
 		model:                 model,
+		largeModel:            largeModel,
+		largeModelThreshold:   largeModelThreshold,
+		largeModelNumCtx:      largeModelNumCtx,
 		maxTotalContentLength: maxTotalContentLength,
+		budgetMode:            budgetMode,
+		whitespacePolicy:      parseWhitespacePolicy(),
+		intentRouting:         strings.ToLower(os.Getenv("ENABLE_INTENT_ROUTING")) != "false",
+		intentClassifier:      NewIntentClassifier(os.Getenv("INTENT_KEYWORDS")),
+		recencyWeight:         recencyWeight,
+		slowOpThreshold:       parseSlowOpThreshold(),
+		includeDataTypes:      strings.ToLower(os.Getenv("INCLUDE_FILE_DATA_TYPES")) != "false",
+		returnPartial:         strings.ToLower(os.Getenv("RETURN_PARTIAL_ON_TIMEOUT")) == "true",
+		includeFailedSources:  strings.ToLower(os.Getenv("INCLUDE_FAILED_SOURCES_IN_PROMPT")) == "true",
 		client: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout:   60 * time.Second,
+			Transport: newOllamaTransport(maxIdleConnsPerHost, idleConnTimeout, keepAlive),
 		},
+		inflight: make(chan struct{}, maxInflight),
+		queue:    make(chan struct{}, queueDepth),
+		breaker:  newOllamaBreaker(),
+
+		semanticSearch: strings.ToLower(os.Getenv("ENABLE_SEMANTIC_SEARCH")) == "true",
+		embedModel:     embedModel,
+		semanticTopK:   semanticTopK,
+		chunkSize:      chunkSize,
+		embedTimeout:   embedTimeout,
+	}
+}
+
+// newOllamaTransport builds a transport that keeps connections to Ollama open between requests,
+// so frequent health checks and generations don't open a new TCP connection each time
+func newOllamaTransport(maxIdleConnsPerHost int, idleConnTimeout, keepAlive time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: keepAlive,
+	}).DialContext
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	if transport.MaxIdleConns < maxIdleConnsPerHost {
+		transport.MaxIdleConns = maxIdleConnsPerHost
 	}
+	transport.IdleConnTimeout = idleConnTimeout
+	return transport
 }
 
+// acquireSlot waits for a free generation slot, queueing up to the configured depth
+func (s *OllamaService) acquireSlot(ctx context.Context) error {
+	select {
+	case s.inflight <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case s.queue <- struct{}{}:
+	default:
+		return ErrOllamaQueueFull
+	}
+	defer func() { <-s.queue }()
+
+	select {
+	case s.inflight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for Ollama: %w", ctx.Err())
+	}
+}
+
+func (s *OllamaService) releaseSlot() {
+	<-s.inflight
+}
+
+// IsEnabled reports whether Ollama is reachable, reusing a recent probe result and answering
+// false without a request while the breaker is open
 func (s *OllamaService) IsEnabled() bool {
+	return s.breaker.check(s.probe)
+}
+
+func (s *OllamaService) probe() bool {
 	// Test if Ollama is running by making a quick request to the API
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -81,11 +291,103 @@ func (s *OllamaService) IsEnabled() bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-func (s *OllamaService) generateResponse(prompt string) (string, error) {
+// newRequest picks the model for a prompt: prompts longer than largeModelThreshold
+// go to largeModel with a bigger context window, everything else to the default model.
+// A model or temperature set in overrides replaces the choice.
+func (s *OllamaService) newRequest(prompt string, overrides *OllamaOptions) OllamaRequest {
 	reqBody := OllamaRequest{
 		Model:  s.model,
 		Prompt: prompt,
-		Stream: false,
+		Stream: s.returnPartial,
+	}
+
+	if overrides != nil && overrides.Model != "" {
+		reqBody.Model = overrides.Model
+	} else if s.largeModel != "" {
+		if len(prompt) > s.largeModelThreshold {
+			reqBody.Model = s.largeModel
+			reqBody.Options = map[string]interface{}{"num_ctx": s.largeModelNumCtx}
+		}
+		fmt.Printf("Using model %s: prompt is %d characters (large model threshold %d)\n", reqBody.Model, len(prompt), s.largeModelThreshold)
+	}
+
+	if overrides != nil && overrides.Temperature != nil {
+		if reqBody.Options == nil {
+			reqBody.Options = make(map[string]interface{})
+		}
+		reqBody.Options["temperature"] = *overrides.Temperature
+	}
+	return reqBody
+}
+
+// AvailableModels lists the models pulled into Ollama, from /api/tags
+func (s *OllamaService) AvailableModels(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama API error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Ollama API returned status code: %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %v", err)
+	}
+	models := make([]string, 0, len(tags.Models))
+	for _, model := range tags.Models {
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
+
+// hasModel reports whether model is in models, where a name without a tag means ":latest"
+func hasModel(models []string, model string) bool {
+	if !strings.Contains(model, ":") {
+		model += ":latest"
+	}
+	for _, available := range models {
+		if available == model {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *OllamaService) generateResponse(prompt string) (string, error) {
+	return s.generateResponseWithStats(context.Background(), prompt, nil, nil, nil)
+}
+
+// generateResponseStream generates a response, sending its tokens to out as Ollama produces them.
+// The full response is returned as well; out isn't closed.
+func (s *OllamaService) generateResponseStream(prompt string, out chan<- string) (string, error) {
+	return s.generateResponseWithStats(context.Background(), prompt, nil, nil, out)
+}
+
+// generateResponseWithStats generates a response, recording the prompt size, model and duration in stats if not nil.
+// Overrides, if not nil, replace the default model and temperature. Cancelling ctx aborts the generation.
+// If out is not nil, the response is streamed and each token is sent to it as it arrives.
+func (s *OllamaService) generateResponseWithStats(ctx context.Context, prompt string, overrides *OllamaOptions, stats *RequestStats, out chan<- string) (string, error) {
+	reqBody := s.newRequest(prompt, overrides)
+	if out != nil {
+		reqBody.Stream = true
+	}
+	if stats != nil {
+		stats.PromptBytes = len(prompt)
+		stats.PromptTokensEstimate = len(prompt) / 4
+		stats.Model = reqBody.Model
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -93,9 +395,18 @@ func (s *OllamaService) generateResponse(prompt string) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	if s.breaker.isOpen() {
+		return "", ErrOllamaCircuitOpen
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
+	if err := s.acquireSlot(ctx); err != nil {
+		return "", err
+	}
+	defer s.releaseSlot()
+
 	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL+"/api/generate", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %v", err)
@@ -103,15 +414,33 @@ func (s *OllamaService) generateResponse(prompt string) (string, error) {
 
 	req.Header.Set("Content-Type", "application/json")
 
+	started := time.Now()
+	defer func() {
+		if stats != nil {
+			stats.GenerationMillis = time.Since(started).Milliseconds()
+		}
+		warnIfSlow(s.slowOpThreshold, "Ollama generation", fmt.Sprintf("model %s, prompt %d characters", reqBody.Model, len(prompt)), started)
+	}()
+
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("Ollama API error: %v", err)
+		// A client going away says nothing about Ollama
+		if !errors.Is(err, context.Canceled) {
+			s.breaker.record(false)
+		}
+		return "", fmt.Errorf("Ollama API error: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		s.breaker.record(false)
 		return "", fmt.Errorf("Ollama API returned status code: %d", resp.StatusCode)
 	}
+	s.breaker.record(true)
+
+	if reqBody.Stream {
+		return readStreamedResponse(ctx, resp.Body, stats, out, s.returnPartial)
+	}
 
 	var ollamaResp OllamaResponse
 	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
@@ -135,6 +464,9 @@ func (s *OllamaService) AnalyzePDFContent(pdfContent *PDFContent, question strin
 	}
 
 	content := pdfContent.Text
+	if relevant := relevantPDFSections(pdfContent.Sections, question); relevant != "" {
+		content = relevant
+	}
 
 	prompt := fmt.Sprintf(`You are an AI assistant analyzing a CV/Resume. 
 
@@ -148,6 +480,68 @@ Please analyze the CV content and provide a comprehensive answer. Focus on extra
 	return s.generateResponse(prompt)
 }
 
+// pdfSectionTopics maps words of common CV headings to question words that ask about them
+var pdfSectionTopics = map[string][]string{
+	"experience": {"work", "job", "career", "role", "company", "companies", "employ", "position", "achievement"},
+	"employment": {"work", "job", "career", "role", "company", "companies", "employ", "position"},
+	"education":  {"degree", "university", "study", "studied", "school", "college", "academic", "graduat"},
+	"skills":     {"technolog", "language", "framework", "tool", "stack", "programming", "skill"},
+	"projects":   {"project", "built", "portfolio"},
+	"contact":    {"email", "phone", "reach", "contact"},
+}
+
+// relevantPDFSections joins the sections whose heading matches a question word, directly or through
+// pdfSectionTopics, plus the preamble. It returns "" when no section matches, so the caller falls back
+// to the full text.
+func relevantPDFSections(sections map[string]string, question string) string {
+	if len(sections) == 0 {
+		return ""
+	}
+
+	// Pad words with spaces so "skill" matches "skills" but "file" doesn't match "profile"
+	paddedQuestion := " " + nonWordChars.ReplaceAllString(strings.ToLower(question), " ")
+	terms := questionTerms(question)
+
+	var builder strings.Builder
+	matched := 0
+	for _, heading := range sortedKeys(sections) {
+		if heading == pdfPreambleSection {
+			continue
+		}
+
+		lowerHeading := " " + nonWordChars.ReplaceAllString(strings.ToLower(heading), " ")
+		relevant := false
+		for _, term := range terms {
+			if strings.Contains(lowerHeading, " "+term) {
+				relevant = true
+			}
+		}
+		for topic, words := range pdfSectionTopics {
+			if !strings.Contains(lowerHeading, " "+topic) {
+				continue
+			}
+			for _, word := range words {
+				if strings.Contains(paddedQuestion, " "+word) {
+					relevant = true
+				}
+			}
+		}
+
+		if relevant {
+			matched++
+			builder.WriteString(fmt.Sprintf("=== %s ===\n%s\n\n", heading, sections[heading]))
+		}
+	}
+	if matched == 0 {
+		return ""
+	}
+
+	if preamble := sections[pdfPreambleSection]; preamble != "" {
+		return preamble + "\n\n" + builder.String()
+	}
+	return builder.String()
+}
+
 func (s *OllamaService) AnalyzeFileContent(fileContent *FileContent, question string) (string, error) {
 	if !s.IsEnabled() {
 		return "", fmt.Errorf("Ollama service is not available - ensure Ollama is running with %s model", s.model)
@@ -164,12 +558,21 @@ func (s *OllamaService) AnalyzeFileContent(fileContent *FileContent, question st
 	if len(fileContent.SheetNames) > 0 {
 		contentBuilder.WriteString(fmt.Sprintf("SHEETS: %s\n", strings.Join(fileContent.SheetNames, ", ")))
 	}
+	if skipped := fileContent.Metadata["sheets_skipped"]; skipped != "" {
+		contentBuilder.WriteString(fmt.Sprintf("SKIPPED SHEETS: %s\n", skipped))
+	}
 	if fileContent.RowCount > 0 {
 		contentBuilder.WriteString(fmt.Sprintf("ROWS: %d\n", fileContent.RowCount))
 	}
 	if fileContent.ColumnCount > 0 {
 		contentBuilder.WriteString(fmt.Sprintf("COLUMNS: %d\n", fileContent.ColumnCount))
 	}
+	if s.includeDataTypes {
+		// Prime the model with what kind of data the file holds
+		if dataTypes := detectDataTypes(strings.ToLower(fileContent.Text)); len(dataTypes) > 0 {
+			contentBuilder.WriteString(fmt.Sprintf("DETECTED DATA TYPES: %s\n", strings.Join(dataTypes, ", ")))
+		}
+	}
 
 	if len(fileContent.Metadata) > 0 {
 		contentBuilder.WriteString("\nMETADATA:\n")
@@ -193,178 +596,465 @@ INSTRUCTIONS:
 2. For XLSX files: Focus on data structure, patterns, and insights from spreadsheet data
 3. For DOCX files: Extract key information, document structure, and textual content
 4. For CSV files: Identify data patterns, column relationships, and statistical insights
-5. Provide relevant answers based on the file content and user's question
-6. If the file contains professional data (resume, portfolio, etc.), highlight relevant skills and experience
-7. For data files, provide summaries and key findings
+5. For PPTX files: Follow the slide order and use slide titles to structure the answer
+6. Provide relevant answers based on the file content and user's question
+7. If the file contains professional data (resume, portfolio, etc.), highlight relevant skills and experience
+8. For data files, provide summaries and key findings
 
 Please provide a comprehensive analysis based on the file content above.`, strings.ToUpper(fileContent.FileType), contentBuilder.String(), question, strings.ToUpper(fileContent.FileType))
 
 	return s.generateResponse(prompt)
 }
 
-func (s *OllamaService) GenerateIntelligentResponse(websiteContent *WebsiteContent, userMessage string) (string, error) {
+// GenerateIntelligentResponse answers a question from the website content and the earlier exchanges
+// of the conversation, oldest first; history, stats and out may be nil. Tokens are sent to out as
+// they are generated.
+func (s *OllamaService) GenerateIntelligentResponse(ctx context.Context, websiteContent *WebsiteContent, history []exchange, userMessage string, overrides *OllamaOptions, stats *RequestStats, out chan<- string) (string, error) {
 	if !s.IsEnabled() {
 		return "", fmt.Errorf("Ollama service is not available - ensure Ollama is running with %s model", s.model)
 	}
 
+	sections := buildPromptSections(websiteContent, s.recencyWeight)
+	if s.intentRouting {
+		sections = prioritizeSections(sections, s.intentClassifier.Classify(userMessage))
+	}
+	// Passages matching the question by meaning go first, so they survive truncation
+	if s.semanticSearch {
+		if passages := s.semanticPassages(ctx, websiteContent, userMessage); passages != "" {
+			sections = append([]promptSection{{category: SectionPassages, text: passages}}, sections...)
+		}
+	}
+
+	// Sizes are measured per section, after whitespace normalization
+	normalized := make([]promptSection, len(sections))
+	for i, section := range sections {
+		normalized[i] = promptSection{category: section.category, text: normalizeWhitespace(section.text, s.whitespacePolicy)}
+	}
+
+	var cb string
+	var budgets []int
+	if s.budgetMode == BudgetPerSource {
+		separator := "\n\n"
+		if s.whitespacePolicy == WhitespaceFlatten {
+			separator = " "
+		}
+		budgets = allocateBudget(normalized, s.maxTotalContentLength-len(separator)*max(len(normalized)-1, 0))
+		cb = truncateSections(normalized, budgets, separator)
+	} else {
+		var contentBuilder strings.Builder
+		for _, section := range sections {
+			contentBuilder.WriteString(section.text)
+		}
+		cb = normalizeWhitespace(contentBuilder.String(), s.whitespacePolicy)
+
+		// Limit content size to avoid overwhelming the AI
+		if len(cb) > s.maxTotalContentLength {
+			cb = cb[:s.maxTotalContentLength] + "..."
+		}
+		budgets = sequentialBudgets(normalized, s.maxTotalContentLength)
+	}
+	// The note of unavailable sources is short and goes after the budget, so truncation never drops it
+	if s.includeFailedSources && websiteContent != nil {
+		cb += formatUnavailableSources(websiteContent.UnavailableSources)
+	}
+	if stats != nil {
+		stats.SourcesIncluded, stats.SourcesTruncated = sectionCoverage(normalized, budgets)
+		stats.SourceCategories = sourceCategories(stats.SourcesIncluded, websiteContent, cb)
+	}
+
+	conversation := ""
+	if len(history) > 0 {
+		conversation = "\nCONVERSATION SO FAR (use it to understand follow-up questions):\n" + strings.TrimSpace(formatConversation(history)) + "\n"
+	}
+
+	prompt := fmt.Sprintf(`You are an intelligent assistant with comprehensive information about this website. You have access to:
+- His main website content and metadata
+- Full CV/resume documents with detailed professional information
+- Content from external professional profiles (GitHub, LinkedIn, etc.)
+- First-level linked pages from external profiles with relevance scoring
+- All professional links and social profiles
+- Complete biographical and career information with content type classification
+- Parsed file documents (PDF, XLSX, DOCX, PPTX, CSV) with structured data and metadata
+
+COMPREHENSIVE DATA AVAILABLE:
+%s
+%s
+USER QUESTION: %s
+
+INSTRUCTIONS:
+1. Answer using available information from providede COMPREHENSIVE DATA AVAILABLE
+2. Provide specific details from any relevant source, considering relevance scores (higher scores = more reliable)
+3. Cross-reference information across sources and first-level links for comprehensive answers
+4. For file content (XLSX/DOCX/PPTX/CSV/PDF), utilize structured data, metadata, and extracted information
+5. Be conversational, detailed, and cite sources with their relevance when helpful
+6. Use linked content to provide deeper insights into projects, articles, and professional work
+7. If information is limited, clearly state what's not available and suggest checking specific high-relevance sources
+
+Provide a thorough response using the comprehensive data available above.`, cb, conversation, userMessage)
+
+	return s.generateResponseWithStats(ctx, prompt, overrides, stats, out)
+}
+
+// sourceCategories lists the kinds of sources in the final prompt content: the included sections, with
+// linked pages broken down by their ContentType (e.g. "project" for GitHub) so the UI can label them
+func sourceCategories(included []string, websiteContent *WebsiteContent, content string) []string {
+	var categories []string
+	seen := make(map[string]bool)
+	add := func(category string) {
+		if category != "" && !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+
+	for _, category := range included {
+		if category != SectionLinked {
+			add(category)
+			continue
+		}
+		// Only pages whose block survived truncation count
+		for _, url := range orderLinkedPages(websiteContent.LinkedContent, 0) {
+			if strings.Contains(content, fmt.Sprintf("--- PROFILE: %s ---", url)) {
+				add(websiteContent.LinkedContent[url].ContentType)
+			}
+		}
+	}
+	return categories
+}
+
+// orderLinkedPages returns the linked page URLs best first, so the most useful pages survive truncation.
+// Pages are scored by relevance, blended with recency by recencyWeight (0 = relevance only, 1 = newest first);
+// pages without a known date get no recency credit.
+func orderLinkedPages(linked map[string]*LinkedPageContent, recencyWeight float64) []string {
+	score := func(page *LinkedPageContent) float64 {
+		relevance := float64(page.Relevance) / 10
+		recency := 0.0
+		if !page.PublishedAt.IsZero() {
+			ageDays := time.Since(page.PublishedAt).Hours() / 24
+			recency = 1 / (1 + math.Max(ageDays, 0)/30)
+		}
+		return (1-recencyWeight)*relevance + recencyWeight*recency
+	}
+
+	urls := sortedKeys(linked)
+	sort.SliceStable(urls, func(i, j int) bool {
+		return score(linked[urls[i]]) > score(linked[urls[j]])
+	})
+	return urls
+}
+
+// socialMetadataKeys are the Open Graph and Twitter card properties surfaced with the main content
+var socialMetadataKeys = []string{"og:title", "og:description", "og:image", "twitter:title", "twitter:description", "twitter:image"}
+
+func isSocialMetadataKey(key string) bool {
+	for _, socialKey := range socialMetadataKeys {
+		if key == socialKey {
+			return true
+		}
+	}
+	return false
+}
+
+// formatUnavailableSources lists sources that could not be loaded, so the model can mention the gaps
+func formatUnavailableSources(sources []UnavailableSource) string {
+	if len(sources) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\nUNAVAILABLE SOURCES (could not be loaded, content unknown):\n")
+	for _, source := range sources {
+		b.WriteString(fmt.Sprintf("- %s (%s): %s\n", source.URL, source.Type, source.Reason))
+	}
+	return b.String()
+}
+
+// promptSection is a block of the assembled prompt content with the category of its source
+type promptSection struct {
+	category string
+	text     string
+}
+
+// buildPromptSections renders website content into prompt sections in their default order. Each
+// linked page, PDF and file is a section of its own, so per-source budgets can cut them separately.
+func buildPromptSections(websiteContent *WebsiteContent, recencyWeight float64) []promptSection {
+	var sections []promptSection
+	if websiteContent == nil {
+		return sections
+	}
+
 	var contentBuilder strings.Builder
+	addSection := func(category string) {
+		if contentBuilder.Len() > 0 {
+			sections = append(sections, promptSection{category: category, text: contentBuilder.String()})
+			contentBuilder.Reset()
+		}
+	}
 
-	if websiteContent != nil {
-		//contentBuilder.WriteString("=== COMPREHENSIVE PROFILE ===\n\n")
+	// Include main website content
+	if websiteContent.Title != "" {
+		contentBuilder.WriteString(fmt.Sprintf("MAIN WEBSITE: %s\n", websiteContent.Title))
+	}
+	if len(websiteContent.Breadcrumbs) > 0 {
+		contentBuilder.WriteString(fmt.Sprintf("PAGE LOCATION: this page is under %s\n", formatBreadcrumbs(websiteContent.Breadcrumbs)))
+	}
+	if websiteContent.Description != "" {
+		contentBuilder.WriteString(fmt.Sprintf("DESCRIPTION: %s\n", websiteContent.Description))
+	}
+	// Social metadata is often the cleanest summary of a page, so it goes before the body text
+	for _, key := range socialMetadataKeys {
+		value := websiteContent.Metadata[key]
+		if value != "" && value != websiteContent.Title && value != websiteContent.Description {
+			contentBuilder.WriteString(fmt.Sprintf("%s: %s\n", strings.ToUpper(key), value))
+		}
+	}
+	if len(websiteContent.StructuredData) > 0 {
+		contentBuilder.WriteString("STRUCTURED DATA (JSON-LD published by the site, more reliable than the page text):\n")
+		contentBuilder.WriteString(formatStructuredData(websiteContent.StructuredData))
+		contentBuilder.WriteString("\n\n")
+	}
+	if len(websiteContent.Outline) > 0 {
+		contentBuilder.WriteString("MAIN WEBSITE TABLE OF CONTENTS:\n")
+		contentBuilder.WriteString(formatOutline(websiteContent.Outline))
+		contentBuilder.WriteString("\n")
+	}
+	if websiteContent.Text != "" {
+		contentBuilder.WriteString("MAIN WEBSITE CONTENT:\n")
+		contentBuilder.WriteString(websiteContent.Text)
+		contentBuilder.WriteString("\n\n")
+	}
+	addSection(SectionMain)
 
-		// Include main website content
-		if websiteContent.Title != "" {
-			contentBuilder.WriteString(fmt.Sprintf("MAIN WEBSITE: %s\n", websiteContent.Title))
+	// Include user reviews and comments
+	if len(websiteContent.Reviews) > 0 {
+		contentBuilder.WriteString("USER REVIEWS:\n")
+		if websiteContent.ReviewSummary != "" {
+			contentBuilder.WriteString(fmt.Sprintf("Overall sentiment: %s\n", websiteContent.ReviewSummary))
 		}
-		if websiteContent.Description != "" {
-			contentBuilder.WriteString(fmt.Sprintf("DESCRIPTION: %s\n", websiteContent.Description))
+		for _, review := range websiteContent.Reviews {
+			contentBuilder.WriteString(fmt.Sprintf("- %s\n", review))
+		}
+		contentBuilder.WriteString("\n")
+	}
+	addSection(SectionReviews)
+
+	// Include contact details marked up on the site's pages
+	contactLines := make([]string, 0, len(websiteContent.Contacts))
+	for _, card := range websiteContent.Contacts {
+		contactLines = append(contactLines, formatContactCard(card))
+	}
+	for _, url := range sortedKeys(websiteContent.LinkedContent) {
+		for _, card := range websiteContent.LinkedContent[url].Contacts {
+			contactLines = append(contactLines, fmt.Sprintf("%s (from %s)", formatContactCard(card), url))
 		}
-		if websiteContent.Text != "" {
-			contentBuilder.WriteString("MAIN WEBSITE CONTENT:\n")
-			contentBuilder.WriteString(websiteContent.Text)
-			contentBuilder.WriteString("\n\n")
+	}
+	if len(contactLines) > 0 {
+		contentBuilder.WriteString("CONTACT DETAILS:\n")
+		for _, line := range contactLines {
+			contentBuilder.WriteString(fmt.Sprintf("- %s\n", line))
 		}
+		contentBuilder.WriteString("\n")
+	}
+	addSection(SectionContacts)
 
-		// Include metadata
-		if len(websiteContent.Metadata) > 0 {
-			contentBuilder.WriteString("WEBSITE METADATA:\n")
-			for key, value := range websiteContent.Metadata {
-				contentBuilder.WriteString(fmt.Sprintf("- %s: %s\n", key, value))
+	// Include metadata
+	if len(websiteContent.Metadata) > 0 {
+		contentBuilder.WriteString("WEBSITE METADATA:\n")
+		for key, value := range websiteContent.Metadata {
+			if isSocialMetadataKey(key) {
+				continue // Already part of the main section
 			}
-			contentBuilder.WriteString("\n")
+			contentBuilder.WriteString(fmt.Sprintf("- %s: %s\n", key, value))
 		}
+		contentBuilder.WriteString("\n")
+	}
+	addSection(SectionMetadata)
 
-		// Include all website links with descriptions
-		if len(websiteContent.Links) > 0 {
-			contentBuilder.WriteString("PROFESSIONAL LINKS AND PROFILES:\n")
-			for _, link := range websiteContent.Links {
-				contentBuilder.WriteString(fmt.Sprintf("- %s: %s (Type: %s)\n", link.Title, link.URL, link.Type))
+	// Include all website links with descriptions
+	if len(websiteContent.Links) > 0 {
+		contentBuilder.WriteString("PROFESSIONAL LINKS AND PROFILES:\n")
+		for _, link := range websiteContent.Links {
+			contentBuilder.WriteString(fmt.Sprintf("- %s: %s (Type: %s)\n", link.Title, link.URL, link.Type))
+			if link.PageTitle != "" {
+				contentBuilder.WriteString(fmt.Sprintf("  Page Title: %s\n", link.PageTitle))
+			}
+			if link.PageDescription != "" {
+				contentBuilder.WriteString(fmt.Sprintf("  Page Description: %s\n", link.PageDescription))
 			}
-			contentBuilder.WriteString("\n")
 		}
+		contentBuilder.WriteString("\n")
+	}
+	addSection(SectionLinks)
 
-		// Include linked content from professional profiles
-		if len(websiteContent.LinkedContent) > 0 {
-			contentBuilder.WriteString("EXTERNAL PROFILE CONTENT:\n")
-			for url, linkedContent := range websiteContent.LinkedContent {
-				contentBuilder.WriteString(fmt.Sprintf("\n--- PROFILE: %s ---\n", url))
-				if linkedContent.Title != "" {
-					contentBuilder.WriteString(fmt.Sprintf("Title: %s\n", linkedContent.Title))
-				}
-				if linkedContent.Description != "" {
-					contentBuilder.WriteString(fmt.Sprintf("Description: %s\n", linkedContent.Description))
-				}
-				if linkedContent.ContentType != "" {
-					contentBuilder.WriteString(fmt.Sprintf("Content Type: %s\n", linkedContent.ContentType))
-				}
-				//if linkedContent.Relevance > 0 {
-				//	contentBuilder.WriteString(fmt.Sprintf("Relevance Score: %d/10\n", linkedContent.Relevance))
-				//}
-				if len(linkedContent.Keywords) > 0 {
-					contentBuilder.WriteString(fmt.Sprintf("Keywords: %s\n", strings.Join(linkedContent.Keywords, ", ")))
-				}
-				if linkedContent.Text != "" {
-					contentBuilder.WriteString("Content:\n")
-					contentBuilder.WriteString(linkedContent.Text)
-					contentBuilder.WriteString("\n")
-				}
+	// Include linked content from professional profiles
+	if len(websiteContent.LinkedContent) > 0 {
+		contentBuilder.WriteString("EXTERNAL PROFILE CONTENT:\n")
+		for _, url := range orderLinkedPages(websiteContent.LinkedContent, recencyWeight) {
+			linkedContent := websiteContent.LinkedContent[url]
+			contentBuilder.WriteString(fmt.Sprintf("\n--- PROFILE: %s ---\n", url))
+			if linkedContent.Title != "" {
+				contentBuilder.WriteString(fmt.Sprintf("Title: %s\n", linkedContent.Title))
+			}
+			if !linkedContent.PublishedAt.IsZero() {
+				contentBuilder.WriteString(fmt.Sprintf("Published: %s\n", linkedContent.PublishedAt.Format("2006-01-02")))
+			}
+			if linkedContent.Description != "" {
+				contentBuilder.WriteString(fmt.Sprintf("Description: %s\n", linkedContent.Description))
+			}
+			if linkedContent.ContentType != "" {
+				contentBuilder.WriteString(fmt.Sprintf("Content Type: %s\n", linkedContent.ContentType))
+			}
+			if linkedContent.Language != "" {
+				contentBuilder.WriteString(fmt.Sprintf("Language: %s\n", linkedContent.Language))
+			}
+			if len(linkedContent.Breadcrumbs) > 0 {
+				contentBuilder.WriteString(fmt.Sprintf("Location: %s\n", formatBreadcrumbs(linkedContent.Breadcrumbs)))
+			}
+			//if linkedContent.Relevance > 0 {
+			//	contentBuilder.WriteString(fmt.Sprintf("Relevance Score: %d/10\n", linkedContent.Relevance))
+			//}
+			if len(linkedContent.Keywords) > 0 {
+				contentBuilder.WriteString(fmt.Sprintf("Keywords: %s\n", strings.Join(linkedContent.Keywords, ", ")))
+			}
+			if linkedContent.Text != "" {
+				contentBuilder.WriteString("Content:\n")
+				contentBuilder.WriteString(linkedContent.Text)
+				contentBuilder.WriteString("\n")
+			}
 
-				// Include first-level linked content
-				if len(linkedContent.FirstLevelLinks) > 0 {
-					contentBuilder.WriteString("FIRST-LEVEL LINKED CONTENT:\n")
-					for _, firstLevel := range linkedContent.FirstLevelLinks {
-						contentBuilder.WriteString(fmt.Sprintf("\n  • %s (%s)\n", firstLevel.Title, firstLevel.URL))
-						if firstLevel.Description != "" {
-							contentBuilder.WriteString(fmt.Sprintf("    Description: %s\n", firstLevel.Description))
-						}
-						if firstLevel.Relevance > 0 {
-							contentBuilder.WriteString(fmt.Sprintf("    Relevance: %d/10\n", firstLevel.Relevance))
-						}
-						if firstLevel.Text != "" {
-							contentBuilder.WriteString(fmt.Sprintf("    Content Summary: %s\n", firstLevel.Text))
-						}
+			// Include first-level linked content
+			if len(linkedContent.FirstLevelLinks) > 0 {
+				contentBuilder.WriteString("FIRST-LEVEL LINKED CONTENT:\n")
+				for _, firstLevel := range linkedContent.FirstLevelLinks {
+					contentBuilder.WriteString(fmt.Sprintf("\n  • %s (%s)\n", firstLevel.Title, firstLevel.URL))
+					if firstLevel.Description != "" {
+						contentBuilder.WriteString(fmt.Sprintf("    Description: %s\n", firstLevel.Description))
+					}
+					if firstLevel.Relevance > 0 {
+						contentBuilder.WriteString(fmt.Sprintf("    Relevance: %d/10\n", firstLevel.Relevance))
+					}
+					if firstLevel.Text != "" {
+						contentBuilder.WriteString(fmt.Sprintf("    Content Summary: %s\n", firstLevel.Text))
 					}
-					contentBuilder.WriteString("\n")
 				}
-
-				contentBuilder.WriteString("--- END PROFILE ---\n\n")
+				contentBuilder.WriteString("\n")
 			}
+
+			contentBuilder.WriteString("--- END PROFILE ---\n\n")
+			addSection(SectionLinked)
 		}
+	}
 
-		// Include full PDF content (CV/Resume) for comprehensive analysis
-		if len(websiteContent.PDFContent) > 0 {
-			contentBuilder.WriteString("DETAILED CV/RESUME DOCUMENTS:\n")
-			for url, pdf := range websiteContent.PDFContent {
-				contentBuilder.WriteString(fmt.Sprintf("\n--- CV/RESUME FROM: %s ---\n", url))
-				contentBuilder.WriteString(pdf.Text)
-				contentBuilder.WriteString("\n--- END CV/RESUME ---\n\n")
+	// Include full PDF content (CV/Resume) for comprehensive analysis
+	if len(websiteContent.PDFContent) > 0 {
+		contentBuilder.WriteString("DETAILED CV/RESUME DOCUMENTS:\n")
+		for url, pdf := range websiteContent.PDFContent {
+			contentBuilder.WriteString(fmt.Sprintf("\n--- CV/RESUME FROM: %s ---\n", url))
+			if pdf.OCRUsed {
+				contentBuilder.WriteString("(Text recognized by OCR from a scanned document, may contain recognition errors)\n")
 			}
+			contentBuilder.WriteString(pdf.Text)
+			contentBuilder.WriteString("\n--- END CV/RESUME ---\n\n")
+			addSection(SectionPDF)
 		}
+	}
 
-		// Include parsed file content (XLSX, DOCX, CSV)
-		if len(websiteContent.FileContent) > 0 {
-			contentBuilder.WriteString("PARSED FILE DOCUMENTS:\n")
-			for url, file := range websiteContent.FileContent {
-				contentBuilder.WriteString(fmt.Sprintf("\n--- %s FILE FROM: %s ---\n", strings.ToUpper(file.FileType), url))
-				contentBuilder.WriteString(fmt.Sprintf("File Name: %s\n", file.FileName))
-				if len(file.SheetNames) > 0 {
-					contentBuilder.WriteString(fmt.Sprintf("Sheets: %s\n", strings.Join(file.SheetNames, ", ")))
-				}
-				if file.RowCount > 0 {
-					contentBuilder.WriteString(fmt.Sprintf("Rows: %d\n", file.RowCount))
-				}
-				if file.ColumnCount > 0 {
-					contentBuilder.WriteString(fmt.Sprintf("Columns: %d\n", file.ColumnCount))
-				}
-				if len(file.Metadata) > 0 {
-					contentBuilder.WriteString("Metadata:\n")
-					for key, value := range file.Metadata {
-						contentBuilder.WriteString(fmt.Sprintf("- %s: %s\n", key, value))
-					}
+	// Include parsed file content (XLSX, DOCX, PPTX, CSV)
+	if len(websiteContent.FileContent) > 0 {
+		contentBuilder.WriteString("PARSED FILE DOCUMENTS:\n")
+		for url, file := range websiteContent.FileContent {
+			contentBuilder.WriteString(fmt.Sprintf("\n--- %s FILE FROM: %s ---\n", strings.ToUpper(file.FileType), url))
+			contentBuilder.WriteString(fmt.Sprintf("File Name: %s\n", file.FileName))
+			if len(file.SheetNames) > 0 {
+				contentBuilder.WriteString(fmt.Sprintf("Sheets: %s\n", strings.Join(file.SheetNames, ", ")))
+			}
+			if skipped := file.Metadata["sheets_skipped"]; skipped != "" {
+				contentBuilder.WriteString(fmt.Sprintf("Skipped sheets: %s\n", skipped))
+			}
+			if file.RowCount > 0 {
+				contentBuilder.WriteString(fmt.Sprintf("Rows: %d\n", file.RowCount))
+			}
+			if file.ColumnCount > 0 {
+				contentBuilder.WriteString(fmt.Sprintf("Columns: %d\n", file.ColumnCount))
+			}
+			if len(file.Metadata) > 0 {
+				contentBuilder.WriteString("Metadata:\n")
+				for key, value := range file.Metadata {
+					contentBuilder.WriteString(fmt.Sprintf("- %s: %s\n", key, value))
 				}
-				contentBuilder.WriteString("Content:\n")
-				contentBuilder.WriteString(file.Text)
-				contentBuilder.WriteString(fmt.Sprintf("\n--- END %s FILE ---\n\n", strings.ToUpper(file.FileType)))
 			}
+			contentBuilder.WriteString("Content:\n")
+			contentBuilder.WriteString(file.Text)
+			contentBuilder.WriteString(fmt.Sprintf("\n--- END %s FILE ---\n\n", strings.ToUpper(file.FileType)))
+			addSection(SectionFile)
 		}
 	}
 
-	cb := contentBuilder.String()
-	// Compile regex: one or more whitespace chars
-	re := regexp.MustCompile(`\s+`)
-
-	// Replace with single space
-	cb = re.ReplaceAllString(cb, " ")
+	return sections
+}
 
-	// Limit content size to avoid overwhelming the AI TODO: configure
-	if len(cb) > s.maxTotalContentLength {
-		cb = cb[:s.maxTotalContentLength] + "..."
+// formatOutline renders headings as an indented table of contents
+func formatOutline(outline []OutlineEntry) string {
+	var b strings.Builder
+	for _, entry := range outline {
+		indent := entry.Level - 1
+		if indent < 0 {
+			indent = 0
+		}
+		b.WriteString(fmt.Sprintf("%s- %s\n", strings.Repeat("  ", indent), entry.Text))
 	}
+	return b.String()
+}
 
-	prompt := fmt.Sprintf(`You are an intelligent assistant with comprehensive information about this website. You have access to:
-- His main website content and metadata
-- Full CV/resume documents with detailed professional information
-- Content from external professional profiles (GitHub, LinkedIn, etc.)
-- First-level linked pages from external profiles with relevance scoring
-- All professional links and social profiles
-- Complete biographical and career information with content type classification
-- Parsed file documents (PDF, XLSX, DOCX, CSV) with structured data and metadata
-
-COMPREHENSIVE DATA AVAILABLE:
-%s
+// partialAnswerNote is appended to answers cut short by the generation timeout
+const partialAnswerNote = "\n\n[This answer is incomplete: generating it took too long.]"
 
-USER QUESTION: %s
+// readStreamedResponse collects a streamed generation, passing each token on to out if it isn't nil.
+// When the timeout hits after some tokens arrived and returnPartial is set, they are returned marked
+// as partial instead of failing the whole request.
+func readStreamedResponse(ctx context.Context, body io.Reader, stats *RequestStats, out chan<- string, returnPartial bool) (string, error) {
+	var answer strings.Builder
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk OllamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if !returnPartial || answer.Len() == 0 || !isTimeoutError(ctx, err) {
+				return "", fmt.Errorf("failed to read response stream: %w", err)
+			}
+			logWarning("ollama_timeout", "Ollama generation timed out, returning a partial answer", "characters", answer.Len())
+			if stats != nil {
+				stats.Partial = true
+			}
+			return strings.TrimSpace(answer.String()) + partialAnswerNote, nil
+		}
 
-INSTRUCTIONS:
-1. Answer using available information from providede COMPREHENSIVE DATA AVAILABLE
-2. Provide specific details from any relevant source, considering relevance scores (higher scores = more reliable)
-3. Cross-reference information across sources and first-level links for comprehensive answers
-4. For file content (XLSX/DOCX/CSV/PDF), utilize structured data, metadata, and extracted information
-5. Be conversational, detailed, and cite sources with their relevance when helpful
-6. Use linked content to provide deeper insights into projects, articles, and professional work
-7. If information is limited, clearly state what's not available and suggest checking specific high-relevance sources
+		answer.WriteString(chunk.Response)
+		if out != nil && chunk.Response != "" {
+			select {
+			case out <- chunk.Response:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
 
-Provide a thorough response using the comprehensive data available above.`, cb, userMessage)
+	if answer.Len() == 0 {
+		return "", fmt.Errorf("no response from Ollama API")
+	}
+	return answer.String(), nil
+}
 
-	return s.generateResponse(prompt)
+// isTimeoutError reports whether a read failed because the request context or client timed out
+func isTimeoutError(ctx context.Context, err error) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOllama serves /api/tags and /api/generate like Ollama, recording the generation requests.
+// Streamed generations send the answer one word per chunk.
+type fakeOllama struct {
+	*httptest.Server
+	answer string
+	models []string
+
+	mu       sync.Mutex
+	requests []OllamaRequest
+}
+
+func newFakeOllama(t *testing.T, answer string, models ...string) *fakeOllama {
+	t.Helper()
+	f := &fakeOllama{answer: answer, models: models}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeOllama) serve(rw http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/tags":
+		type model struct {
+			Name string `json:"name"`
+		}
+		var tags struct {
+			Models []model `json:"models"`
+		}
+		for _, name := range f.models {
+			tags.Models = append(tags.Models, model{Name: name})
+		}
+		json.NewEncoder(rw).Encode(tags)
+	case "/api/generate":
+		var req OllamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+
+		if !req.Stream {
+			json.NewEncoder(rw).Encode(OllamaResponse{Model: req.Model, Response: f.answer, Done: true})
+			return
+		}
+		encoder := json.NewEncoder(rw)
+		for _, word := range strings.SplitAfter(f.answer, " ") {
+			encoder.Encode(OllamaResponse{Model: req.Model, Response: word})
+		}
+		encoder.Encode(OllamaResponse{Model: req.Model, Done: true})
+	default:
+		http.NotFound(rw, r)
+	}
+}
+
+// prompts returns the prompts of the generation requests received so far
+func (f *fakeOllama) prompts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var prompts []string
+	for _, req := range f.requests {
+		prompts = append(prompts, req.Prompt)
+	}
+	return prompts
+}
+
+func TestFormatOutlineNesting(t *testing.T) {
+	outline := []OutlineEntry{
+		{Level: 1, Text: "Jane Doe"},
+		{Level: 2, Text: "Experience"},
+		{Level: 3, Text: "ACME Corp"},
+		{Level: 2, Text: "Education"},
+	}
+	want := "- Jane Doe\n  - Experience\n    - ACME Corp\n  - Education\n"
+	if got := formatOutline(outline); got != want {
+		t.Errorf("formatOutline() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// failingReader fails every read with err, like a response body cut off by a timeout
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestReadStreamedResponseTimeout(t *testing.T) {
+	chunks := `{"response":"Jane builds "}` + "\n" + `{"response":"distributed"}` + "\n"
+
+	tests := []struct {
+		name          string
+		body          string
+		returnPartial bool
+		want          string
+		wantErr       bool
+	}{
+		{name: "partial answer", body: chunks, returnPartial: true, want: "Jane builds distributed" + partialAnswerNote},
+		{name: "partial answers disabled", body: chunks, returnPartial: false, wantErr: true},
+		{name: "nothing generated", body: "", returnPartial: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+			defer cancel()
+			<-ctx.Done()
+			body := io.MultiReader(strings.NewReader(tt.body), failingReader{ctx.Err()})
+
+			stats := &RequestStats{}
+			got, err := readStreamedResponse(ctx, body, stats, nil, tt.returnPartial)
+			if tt.wantErr {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("error = %v, want the timeout", err)
+				}
+				if got != "" || stats.Partial {
+					t.Errorf("got %q with Partial %v, want no answer", got, stats.Partial)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readStreamedResponse: %v", err)
+			}
+			if got != tt.want || !stats.Partial {
+				t.Errorf("got %q with Partial %v, want %q marked partial", got, stats.Partial, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/ledongthuc/pdf"
 )
 
 type PDFExtractor struct {
-	client *http.Client
+	client           *http.Client
+	splitSections    bool
+	maxDownloadBytes int64
+	enableOCR        bool
+	ocrRenderer      string // pdftoppm binary rendering pages to images for OCR
+	ocrEngine        string // tesseract binary recognizing the text of page images
+	ocrMinText       int    // Extracted text length below which a PDF is OCRed
+	phones           phoneNormalizer
 }
 
 type PDFContent struct {
@@ -22,29 +33,76 @@ type PDFContent struct {
 	Author      string
 	Subject     string
 	Keywords    string
+	Sections    map[string]string `json:",omitempty"` // Text by detected heading, when PDF_SPLIT_SECTIONS is enabled
+	OCRUsed     bool              `json:",omitempty"` // Text was recognized from page images, the PDF had no usable text layer
+	Phones      []string          `json:",omitempty"` // Phone numbers found in the text, in E.164 form when NORMALIZE_PHONES is enabled
 	LastUpdated time.Time
 }
 
 func NewPDFExtractor() *PDFExtractor {
+	// Check if scanned PDFs without a text layer should be OCRed (default: false)
+	enableOCR := strings.ToLower(os.Getenv("ENABLE_PDF_OCR")) == "true"
+
+	// Parse OCR binaries (default: pdftoppm and tesseract from PATH)
+	ocrRenderer := os.Getenv("PDFTOPPM_PATH")
+	if ocrRenderer == "" {
+		ocrRenderer = "pdftoppm"
+	}
+	ocrEngine := os.Getenv("TESSERACT_PATH")
+	if ocrEngine == "" {
+		ocrEngine = "tesseract"
+	}
+
+	// Without the binaries OCR can't work, so don't try it on every PDF
+	if enableOCR {
+		if ok, missing := ocrAvailable(ocrRenderer, ocrEngine); !ok {
+			logWarning("pdf_ocr_unavailable", "PDF OCR disabled, binary not found", "binary", missing)
+			enableOCR = false
+		}
+	}
+
+	// Parse extracted text length below which a PDF is treated as scanned (default: 100)
+	ocrMinText := 100
+	if minTextStr := os.Getenv("PDF_OCR_MIN_TEXT"); minTextStr != "" {
+		if parsed, err := strconv.Atoi(minTextStr); err == nil && parsed > 0 {
+			ocrMinText = parsed
+		}
+	}
+
 	return &PDFExtractor{
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		splitSections:    strings.ToLower(os.Getenv("PDF_SPLIT_SECTIONS")) == "true",
+		maxDownloadBytes: parseMaxDownloadBytes(),
+		enableOCR:        enableOCR,
+		ocrRenderer:      ocrRenderer,
+		ocrEngine:        ocrEngine,
+		ocrMinText:       ocrMinText,
+		phones:           newPhoneNormalizer(),
 	}
 }
 
-func (p *PDFExtractor) ExtractFromURL(pdfURL string) (*PDFContent, error) {
-	resp, err := p.client.Get(pdfURL)
+func (p *PDFExtractor) ExtractFromURL(ctx context.Context, pdfURL string) (*PDFContent, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pdfURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch PDF from %s: %v", pdfURL, err)
+		return nil, fmt.Errorf("failed to create request for %s: %w", pdfURL, err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PDF from %s: %w", pdfURL, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download PDF: status code %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to download PDF: %w", &HTTPStatusError{StatusCode: resp.StatusCode})
 	}
 
-	return p.extractFromReader(resp.Body)
+	body, err := limitBody(resp, pdfURL, p.maxDownloadBytes)
+	if err != nil {
+		return nil, err
+	}
+	return p.extractFromReader(body)
 }
 
 func (p *PDFExtractor) extractFromReader(reader io.Reader) (*PDFContent, error) {
@@ -62,8 +120,10 @@ func (p *PDFExtractor) extractFromReader(reader io.Reader) (*PDFContent, error)
 		Pages:       pdfReader.NumPage(),
 		LastUpdated: time.Now(),
 	}
+	readPDFInfo(pdfReader, content)
 
 	var textContent strings.Builder
+	firstPageText := ""
 	for i := 1; i <= content.Pages; i++ {
 		page := pdfReader.Page(i)
 		if page.V.IsNull() {
@@ -74,15 +134,137 @@ func (p *PDFExtractor) extractFromReader(reader io.Reader) (*PDFContent, error)
 		if err != nil {
 			continue
 		}
+		if i == 1 {
+			firstPageText = text
+		}
 
 		textContent.WriteString(text)
 		textContent.WriteString("\n")
 	}
 
 	content.Text = strings.TrimSpace(textContent.String())
+	if p.enableOCR && needsOCR(content, p.ocrMinText) {
+		if text, err := p.ocrPDF(data); err != nil {
+			logWarning("pdf_ocr_failed", "PDF OCR failed, keeping the extracted text", "pages", content.Pages, "error", err)
+		} else if len(text) > len(content.Text) {
+			content.Text = text
+			content.OCRUsed = true
+		}
+	}
+	if p.splitSections {
+		content.Sections = splitPDFSections(content.Text)
+	}
+	content.Phones = p.phones.find(content.Text)
+
+	// Without an Info title, the first line of page 1 is usually the document's title
+	if content.Title == "" {
+		if content.OCRUsed {
+			firstPageText = content.Text
+		}
+		content.Title = firstTextLine(firstPageText)
+	}
 	return content, nil
 }
 
+// readPDFInfo copies Title, Author, Subject and Keywords from the document information dictionary
+// referenced by the trailer's /Info entry, if the PDF has one
+func readPDFInfo(reader *pdf.Reader, content *PDFContent) {
+	info := reader.Trailer().Key("Info")
+	if info.IsNull() {
+		return
+	}
+	content.Title = strings.TrimSpace(info.Key("Title").Text())
+	content.Author = strings.TrimSpace(info.Key("Author").Text())
+	content.Subject = strings.TrimSpace(info.Key("Subject").Text())
+	content.Keywords = strings.TrimSpace(info.Key("Keywords").Text())
+}
+
+// maxHeuristicTitleLength bounds a title taken from a PDF's text
+const maxHeuristicTitleLength = 120
+
+// firstTextLine returns the first non-empty line of text, cut to maxHeuristicTitleLength
+func firstTextLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if runes := []rune(line); len(runes) > maxHeuristicTitleLength {
+				line = strings.TrimSpace(string(runes[:maxHeuristicTitleLength])) + "..."
+			}
+			return line
+		}
+	}
+	return ""
+}
+
+// pdfSectionHeadings are common CV headings recognized regardless of capitalization
+var pdfSectionHeadings = map[string]bool{
+	"summary": true, "profile": true, "about me": true, "objective": true,
+	"experience": true, "work experience": true, "professional experience": true, "employment history": true,
+	"education": true, "skills": true, "technical skills": true, "projects": true, "certifications": true,
+	"languages": true, "publications": true, "awards": true, "interests": true, "contact": true, "references": true,
+}
+
+// pdfPreambleSection holds the text before the first heading, usually the name and contact details
+const pdfPreambleSection = "preamble"
+
+// splitPDFSections segments text at heading lines: known CV headings or short all-caps lines.
+// It returns nil when fewer than two headings are found, since the text then has no usable structure.
+func splitPDFSections(text string) map[string]string {
+	sections := make(map[string]string)
+	current := pdfPreambleSection
+	var body strings.Builder
+	headings := 0
+
+	flush := func() {
+		if sectionText := strings.TrimSpace(body.String()); sectionText != "" {
+			if existing := sections[current]; existing != "" {
+				sectionText = existing + "\n" + sectionText
+			}
+			sections[current] = sectionText
+		}
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if heading, ok := pdfSectionHeading(line); ok {
+			flush()
+			current = heading
+			headings++
+			continue
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+
+	if headings < 2 {
+		return nil
+	}
+	return sections
+}
+
+// pdfSectionHeading reports whether a line is a section heading, returning it without a trailing colon
+func pdfSectionHeading(line string) (string, bool) {
+	heading := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ":"))
+	if len(heading) < 3 || len(heading) > 50 {
+		return "", false
+	}
+	if pdfSectionHeadings[strings.ToLower(heading)] {
+		return heading, true
+	}
+
+	// All-caps lines of a few words, like "PROFESSIONAL EXPERIENCE"
+	letters := 0
+	for _, r := range heading {
+		if unicode.IsLower(r) {
+			return "", false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return heading, letters >= 4 && len(strings.Fields(heading)) <= 5
+}
+
 func (p *PDFExtractor) ExtractKeyInformation(content *PDFContent) map[string]string {
 	info := make(map[string]string)
 	text := strings.ToLower(content.Text)
@@ -194,15 +376,13 @@ func (p *PDFExtractor) extractContactInfo(text string) []string {
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		lower := strings.ToLower(line)
-
 		if strings.Contains(line, "@") && strings.Contains(line, ".") {
 			contact = append(contact, "Email: "+line)
 		}
+	}
 
-		if strings.Contains(lower, "phone") || strings.Contains(lower, "tel") {
-			contact = append(contact, line)
-		}
+	for _, phone := range p.phones.find(text) {
+		contact = append(contact, "Phone: "+phone)
 	}
 
 	return contact
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildTestPDF writes a PDF with one page per entry of pages, each showing its lines in Helvetica.
+// info is the body of the document information dictionary, e.g. "/Title (CV)", or "" for none.
+func buildTestPDF(t *testing.T, info string, pages ...[]string) []byte {
+	t.Helper()
+
+	// Objects 1-3 are the catalog, the page tree and the font; each page adds a page and its content stream
+	objects := []string{"", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"}
+	var kids []string
+	for _, lines := range pages {
+		var stream strings.Builder
+		stream.WriteString("BT /F1 12 Tf 14 TL 72 720 Td")
+		for i, line := range lines {
+			if i > 0 {
+				stream.WriteString(" T*")
+			}
+			fmt.Fprintf(&stream, " (%s) Tj", line)
+		}
+		stream.WriteString(" ET")
+
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", stream.Len(), stream.String()))
+		contentRef := len(objects)
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", contentRef))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+	trailer := fmt.Sprintf("/Size %d /Root 1 0 R", len(objects)+1)
+	if info != "" {
+		objects = append(objects, "<< "+info+" >>")
+		trailer = fmt.Sprintf("/Size %d /Root 1 0 R /Info %d 0 R", len(objects)+1, len(objects))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< %s >>\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+	return buf.Bytes()
+}
+
+func TestPDFMetadata(t *testing.T) {
+	longLine := strings.Repeat("Distributed systems ", 10)
+
+	type metadata struct{ Title, Author, Subject, Keywords string }
+	tests := []struct {
+		name  string
+		info  string
+		pages [][]string
+		want  metadata
+	}{
+		{
+			name:  "Info dictionary",
+			info:  "/Title (Curriculum Vitae) /Author (Jane Doe) /Subject (Software engineering) /Keywords (Go, Kubernetes)",
+			pages: [][]string{{"Jane Doe", "Software engineer"}},
+			want:  metadata{Title: "Curriculum Vitae", Author: "Jane Doe", Subject: "Software engineering", Keywords: "Go, Kubernetes"},
+		},
+		{
+			name:  "title from the first line",
+			pages: [][]string{{"Jane Doe", "Software engineer"}, {"References"}},
+			want:  metadata{Title: "Jane Doe"},
+		},
+		{
+			name:  "empty Info title falls back to the first line",
+			info:  "/Title () /Author (Jane Doe)",
+			pages: [][]string{{"Jane Doe", "Software engineer"}},
+			want:  metadata{Title: "Jane Doe", Author: "Jane Doe"},
+		},
+		{
+			name:  "long first line is cut",
+			pages: [][]string{{longLine}},
+			want:  metadata{Title: strings.TrimSpace(longLine[:maxHeuristicTitleLength]) + "..."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := NewPDFExtractor().extractFromReader(bytes.NewReader(buildTestPDF(t, tt.info, tt.pages...)))
+			if err != nil {
+				t.Fatalf("extractFromReader: %v", err)
+			}
+			got := metadata{Title: content.Title, Author: content.Author, Subject: content.Subject, Keywords: content.Keywords}
+			if got != tt.want {
+				t.Errorf("metadata = %+v, want %+v", got, tt.want)
+			}
+			if content.Pages != len(tt.pages) {
+				t.Errorf("pages = %d, want %d", content.Pages, len(tt.pages))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pdfOCRTimeout bounds rendering and recognizing all pages of one PDF
+const pdfOCRTimeout = 2 * time.Minute
+
+// needsOCR reports whether a PDF's text layer, across all pages, is shorter than minText characters
+// and so is missing or too thin to be the real content
+func needsOCR(content *PDFContent, minText int) bool {
+	return len(strings.TrimSpace(content.Text)) < minText
+}
+
+// ocrAvailable reports whether the OCR binaries can be run, naming the first one that can't
+func ocrAvailable(binaries ...string) (bool, string) {
+	for _, binary := range binaries {
+		if _, err := exec.LookPath(binary); err != nil {
+			return false, binary
+		}
+	}
+	return true, ""
+}
+
+// ocrPDF renders every page of a PDF to an image with pdftoppm and recognizes its text with
+// tesseract, returning the pages' text in order
+func (p *PDFExtractor) ocrPDF(data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pdfOCRTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "pdf_ocr_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pdfPath := filepath.Join(dir, "input.pdf")
+	if err := os.WriteFile(pdfPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write PDF for OCR: %w", err)
+	}
+
+	// pdftoppm writes page-1.png, page-2.png, ... zero-padded to the same width, so they sort in page order
+	if output, err := exec.CommandContext(ctx, p.ocrRenderer, "-r", "300", "-png", pdfPath, filepath.Join(dir, "page")).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to render PDF pages with %s: %w", p.ocrRenderer, commandError(err, output))
+	}
+	images, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil || len(images) == 0 {
+		return "", fmt.Errorf("%s rendered no pages", p.ocrRenderer)
+	}
+	sort.Strings(images)
+
+	var text strings.Builder
+	for _, image := range images {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, p.ocrEngine, image, "stdout")
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to OCR %s with %s: %w", filepath.Base(image), p.ocrEngine, commandError(err, stderr.Bytes()))
+		}
+		text.Write(output)
+		text.WriteString("\n")
+	}
+
+	return strings.TrimSpace(text.String()), nil
+}
+
+// commandError adds what a failed command printed to its error
+func commandError(err error, output []byte) error {
+	if message := strings.TrimSpace(string(output)); message != "" {
+		return fmt.Errorf("%w (%s)", err, message)
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNeedsOCR(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"", true},
+		{" \n\t ", true},
+		{"Jane Doe", true},
+		{"  Jane Doe, software engineer  ", false},
+		{strings.Repeat("x", 20), false},
+	}
+	for _, tt := range tests {
+		if got := needsOCR(&PDFContent{Text: tt.text}, 20); got != tt.want {
+			t.Errorf("needsOCR(%q, 20) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// installOCRStubs puts pdftoppm and tesseract scripts on PATH. The pdftoppm stub renders two pages;
+// the tesseract stub prints the name of the page image it was given, or fails if failOCR is set.
+func installOCRStubs(t *testing.T, failOCR bool) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("OCR stubs are shell scripts")
+	}
+
+	dir := t.TempDir()
+	tesseract := `echo "Recognized text of $(basename "$1")"`
+	if failOCR {
+		tesseract = `echo "image not readable" >&2; exit 1`
+	}
+	stubs := map[string]string{
+		// Called as: pdftoppm -r 300 -png <pdf> <page prefix>
+		"pdftoppm":  `touch "$5-1.png" "$5-2.png"`,
+		"tesseract": tesseract,
+	}
+	for name, script := range stubs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPDFOCR(t *testing.T) {
+	scanned := [][]string{{}, {}}
+	cv := [][]string{{"Jane Doe", "Software engineer building distributed systems in Go at ACME Corp since 2015, previously at Globex"}}
+
+	tests := []struct {
+		name      string
+		pages     [][]string
+		failOCR   bool
+		wantOCR   bool
+		wantText  string
+		wantTitle string
+	}{
+		{
+			name:      "scanned PDF is recognized",
+			pages:     scanned,
+			wantOCR:   true,
+			wantText:  "Recognized text of page-1.png\n\nRecognized text of page-2.png",
+			wantTitle: "Recognized text of page-1.png",
+		},
+		{
+			name:     "failed OCR keeps the extracted text",
+			pages:    scanned,
+			failOCR:  true,
+			wantText: "",
+		},
+		{
+			name:      "text layer skips OCR",
+			pages:     cv,
+			wantText:  strings.Join(cv[0], "\n"),
+			wantTitle: "Jane Doe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installOCRStubs(t, tt.failOCR)
+			t.Setenv("ENABLE_PDF_OCR", "true")
+			p := NewPDFExtractor()
+			if !p.enableOCR {
+				t.Fatal("OCR disabled, the stub binaries were not found")
+			}
+
+			content, err := p.extractFromReader(bytes.NewReader(buildTestPDF(t, "", tt.pages...)))
+			if err != nil {
+				t.Fatalf("extractFromReader: %v", err)
+			}
+			if content.OCRUsed != tt.wantOCR {
+				t.Errorf("OCRUsed = %v, want %v", content.OCRUsed, tt.wantOCR)
+			}
+			if content.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", content.Text, tt.wantText)
+			}
+			if content.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", content.Title, tt.wantTitle)
+			}
+		})
+	}
+}
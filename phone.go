@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+var (
+	// phoneCandidate matches a run of digits with the separators phone numbers are written with
+	phoneCandidate = regexp.MustCompile(`\+?\(?\d[\d \t().\-/]{4,22}\d`)
+	// phoneListSeparators split lines listing several numbers, e.g. "030 123456 / 0171 987654"
+	phoneListSeparators = regexp.MustCompile(`\s+/\s+|[,;|]|\s+or\s+|\s{3,}`)
+	phoneChars          = regexp.MustCompile(`^[+\d \t().\-/]+$`)
+	phoneExtension      = regexp.MustCompile(`(?i)\s*(?:ext\.?|x|#)\s*\d+$`)
+	phoneYearRange      = regexp.MustCompile(`^(?:19|20)\d{2}\s*[-/]\s*(?:19|20)\d{2}$`)
+	nonDigits           = regexp.MustCompile(`\D`)
+)
+
+// phoneKeywords mark lines whose numbers are phone numbers even without a country code
+var phoneKeywords = []string{"phone", "tel", "mobile", "mobil", "cell", "handy"}
+
+// phoneNormalizer finds phone numbers in text, validates them with libphonenumber's metadata and
+// normalizes them to E.164 ("+4930123456")
+type phoneNormalizer struct {
+	enabled bool   // When off, numbers are kept as written
+	region  string // Region numbers without a country code are parsed for, e.g. "DE"; "" to keep those as written
+}
+
+func newPhoneNormalizer() phoneNormalizer {
+	// Check if phone numbers should be normalized to E.164 (default: true)
+	enabled := strings.ToLower(os.Getenv("NORMALIZE_PHONES")) != "false"
+
+	// Parse country assumed for numbers without a calling code, e.g. 49 or DE (default: none)
+	region := ""
+	if country := strings.TrimSpace(os.Getenv("PHONE_DEFAULT_COUNTRY_CODE")); country != "" {
+		region = phoneRegion(country)
+		if region == "" {
+			logWarning("invalid_config", "Unknown country, ignoring it", "setting", "PHONE_DEFAULT_COUNTRY_CODE", "value", country)
+		}
+	}
+
+	return phoneNormalizer{enabled: enabled, region: region}
+}
+
+// phoneRegion returns the region of a calling code such as "49" or "+49", or a region code such as
+// "DE" itself, or "" if libphonenumber doesn't know it
+func phoneRegion(country string) string {
+	if code, err := strconv.Atoi(strings.TrimPrefix(country, "+")); err == nil {
+		if region := phonenumbers.GetRegionCodeForCountryCode(code); region != phonenumbers.UNKNOWN_REGION {
+			return region
+		}
+		return ""
+	}
+	if region := strings.ToUpper(country); phonenumbers.GetSupportedRegions()[region] {
+		return region
+	}
+	return ""
+}
+
+// normalize returns raw in E.164 form, reporting whether it is a valid phone number. Without a
+// default country, numbers lacking a country code can't be validated and are kept as written.
+func (p phoneNormalizer) normalize(raw string) (string, bool) {
+	raw, _, _ = strings.Cut(raw, ";") // RFC 3966 parameters of tel: links
+	raw = phoneExtension.ReplaceAllString(strings.TrimSpace(raw), "")
+	written := allWhitespace.ReplaceAllString(raw, " ")
+	if !phoneChars.MatchString(raw) {
+		return written, false
+	}
+
+	// The default region knows its international prefix; without one, read 00 the European way
+	if p.region == "" && strings.HasPrefix(raw, "00") {
+		raw = "+" + raw[2:]
+	}
+	number, err := phonenumbers.Parse(raw, p.region)
+	if err != nil {
+		if p.region == "" && !strings.HasPrefix(raw, "+") {
+			digits := nonDigits.ReplaceAllString(raw, "")
+			return written, len(digits) >= 7 && len(digits) <= 15
+		}
+		return written, false
+	}
+	if !phonenumbers.IsValidNumber(number) {
+		return written, false
+	}
+	if !p.enabled {
+		return written, true
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), true
+}
+
+// clean normalizes a value marked up as a phone number, picking the number out of surrounding
+// text such as "Call +49 30 123456"; values without a valid number are kept as they are
+func (p phoneNormalizer) clean(value string) string {
+	if phone, ok := p.normalize(value); ok {
+		return phone
+	}
+	if phone, ok := p.normalize(phoneCandidate.FindString(value)); ok {
+		return phone
+	}
+	return value
+}
+
+// find returns the distinct phone numbers in text, normalized. Numbers with a + or 00 prefix are
+// found anywhere; others only on lines mentioning a phone, so dates and IDs aren't mistaken for one.
+func (p phoneNormalizer) find(text string) []string {
+	var phones []string
+	for _, line := range strings.Split(text, "\n") {
+		lower := strings.ToLower(line)
+		phoneLine := false
+		for _, keyword := range phoneKeywords {
+			if strings.Contains(lower, keyword) {
+				phoneLine = true
+				break
+			}
+		}
+
+		for _, part := range phoneListSeparators.Split(line, -1) {
+			for _, candidate := range phoneCandidate.FindAllString(part, -1) {
+				candidate = strings.TrimSpace(candidate)
+				prefixed := strings.HasPrefix(candidate, "+") || strings.HasPrefix(candidate, "00")
+				// Year ranges such as "2015-2019" are valid numbers in some regions
+				if (!phoneLine && !prefixed) || phoneYearRange.MatchString(candidate) {
+					continue
+				}
+				if phone, ok := p.normalize(candidate); ok {
+					phones = appendUnique(phones, phone)
+				}
+			}
+		}
+	}
+	return phones
+}
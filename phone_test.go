@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhoneNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   string
+		disabled bool
+		raw      string
+		want     string
+		wantOK   bool
+	}{
+		{name: "international", raw: "+49 30 12345678", want: "+493012345678", wantOK: true},
+		{name: "international with trunk 0", raw: "+49 (0)30 1234-5678", want: "+493012345678", wantOK: true},
+		{name: "international with extension", raw: "+1 650-253-0000 ext. 12", want: "+16502530000", wantOK: true},
+		{name: "00 prefix", raw: "0044 20 7946 0958", want: "+442079460958", wantOK: true},
+		{name: "00 prefix with default country", region: "DE", raw: "0044 20 7946 0958", want: "+442079460958", wantOK: true},
+		{name: "national with default country", region: "DE", raw: "030 12345678", want: "+493012345678", wantOK: true},
+		{name: "national mobile with default country", region: "DE", raw: "0171/1234567", want: "+491711234567", wantOK: true},
+		{name: "North American national", region: "US", raw: "(650) 253-0000", want: "+16502530000", wantOK: true},
+		{name: "national without default country is kept", raw: "030  12345678", want: "030 12345678", wantOK: true},
+		{name: "tel: link parameters", raw: "+49-30-12345678;phone-context=example.com", want: "+493012345678", wantOK: true},
+		{name: "invalid number", raw: "+1 555 123 4567", want: "+1 555 123 4567"},
+		{name: "too short", region: "DE", raw: "12345", want: "12345"},
+		{name: "not a number", raw: "call me", want: "call me"},
+		{name: "normalization disabled", disabled: true, raw: "+49 (0)30 12345678", want: "+49 (0)30 12345678", wantOK: true},
+		{name: "normalization disabled still validates", disabled: true, raw: "+1 555 123 4567", want: "+1 555 123 4567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := phoneNormalizer{enabled: !tt.disabled, region: tt.region}
+			got, ok := p.normalize(tt.raw)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("normalize(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPhoneFind(t *testing.T) {
+	tests := []struct {
+		name   string
+		region string
+		text   string
+		want   []string
+	}{
+		{
+			name: "international anywhere",
+			text: "Jane Doe, Berlin\nReach me at +49 30 12345678 or 0044 20 7946 0958",
+			want: []string{"+493012345678", "+442079460958"},
+		},
+		{
+			name:   "several numbers on a phone line",
+			region: "DE",
+			text:   "Phone: 030 12345678 / 0171 1234567, +1 650 253 0000",
+			want:   []string{"+493012345678", "+491711234567", "+16502530000"},
+		},
+		{
+			name:   "same number written twice",
+			region: "DE",
+			text:   "Tel.: 030 12345678\nMobile: +49 (0)30 1234 5678",
+			want:   []string{"+493012345678"},
+		},
+		{
+			name:   "national numbers need a phone line",
+			region: "DE",
+			text:   "Employee ID 030 12345678",
+		},
+		{
+			name:   "year ranges are not numbers",
+			region: "DE",
+			text:   "Mobile developer 2015-2019\nPhone support 2019 / 2021",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := phoneNormalizer{enabled: true, region: tt.region}
+			if got := p.find(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("find() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhoneDefaultCountry(t *testing.T) {
+	tests := map[string]string{
+		"":    "",
+		"49":  "DE",
+		"+1":  "US",
+		"44":  "GB",
+		"de":  "DE",
+		"999": "",
+		"XX":  "",
+	}
+	for value, want := range tests {
+		t.Setenv("PHONE_DEFAULT_COUNTRY_CODE", value)
+		if got := newPhoneNormalizer().region; got != want {
+			t.Errorf("PHONE_DEFAULT_COUNTRY_CODE=%q: region %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// prefetchedDocument is the outcome of downloading a document ahead of parsing it
+type prefetchedDocument struct {
+	data               []byte
+	contentDisposition string
+	err                error
+}
+
+// startDocumentPrefetch downloads documents in the background, at most documentPrefetch at a time,
+// so the next one is usually on hand by the time the current one is parsed. Documents that are
+// cached or failed recently are skipped. The returned function stops downloads still running.
+func (w *WebScraper) startDocumentPrefetch(ctx context.Context, client *http.Client, docURLs []string) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if w.documentPrefetch <= 0 {
+		return cancel
+	}
+
+	w.prefetched = make(map[string]chan prefetchedDocument)
+	slots := make(chan struct{}, w.documentPrefetch)
+	for _, docURL := range docURLs {
+		if _, cached := w.pdfCache.get(docURL); cached {
+			continue
+		}
+		if _, cached := w.fileCache.get(docURL); cached {
+			continue
+		}
+		if failure, exists := w.documentFailures[docURL]; exists && time.Since(failure.failedAt) < w.failureCacheTTL {
+			continue
+		}
+		if _, started := w.prefetched[docURL]; started {
+			continue
+		}
+
+		result := make(chan prefetchedDocument, 1)
+		w.prefetched[docURL] = result
+		go func(docURL string) {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				result <- prefetchedDocument{err: ctx.Err()}
+				return
+			}
+			defer func() { <-slots }()
+
+			data, contentDisposition, err := w.downloadDocument(ctx, client, docURL)
+			result <- prefetchedDocument{data: data, contentDisposition: contentDisposition, err: err}
+		}(docURL)
+	}
+
+	return func() {
+		cancel()
+		w.prefetched = nil
+	}
+}
+
+// takePrefetched waits for the prefetched download of a document, or returns nil if it wasn't
+// prefetched. Each download is handed out once, so retries download the document again.
+func (w *WebScraper) takePrefetched(docURL string) *prefetchedDocument {
+	result, exists := w.prefetched[docURL]
+	if !exists {
+		return nil
+	}
+	delete(w.prefetched, docURL)
+
+	prefetched := <-result
+	return &prefetched
+}
+
+// downloadDocument reads a whole document into memory, within the host's rate limit, and returns
+// it with its Content-Disposition header
+func (w *WebScraper) downloadDocument(ctx context.Context, client *http.Client, docURL string) ([]byte, string, error) {
+	if err := w.hostLimiter.wait(ctx, urlHost(docURL)); err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", docURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request for %s: %w", docURL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch document from %s: %w", docURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to download document: %w", &HTTPStatusError{StatusCode: resp.StatusCode})
+	}
+
+	body, err := limitBody(resp, docURL, w.maxDownloadBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read document from %s: %w", docURL, err)
+	}
+	return data, resp.Header.Get("Content-Disposition"), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// applyScrapeProfile sets the environment variables of a named preset from the profiles file.
+// Variables that are already set in the environment take precedence over the preset.
+func applyScrapeProfile(profilesPath, name string) error {
+	data, err := ioutil.ReadFile(profilesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read scrape profiles: %w", err)
+	}
+
+	var profiles map[string]map[string]string
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse scrape profiles %s: %w", profilesPath, err)
+	}
+
+	settings, exists := profiles[name]
+	if !exists {
+		return fmt.Errorf("scrape profile %q not found in %s (available: %v)", name, profilesPath, sortedKeys(profiles))
+	}
+
+	for _, key := range sortedKeys(settings) {
+		if _, isSet := os.LookupEnv(key); isSet {
+			continue
+		}
+		if err := os.Setenv(key, settings[key]); err != nil {
+			return fmt.Errorf("failed to apply %s from scrape profile: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		forwarded  string
+		trustProxy bool
+		want       string
+	}{
+		{"direct", "", false, "192.0.2.1"},
+		{"forwarded header ignored without TRUST_PROXY", "203.0.113.9", false, "192.0.2.1"},
+		{"proxy without forwarded header", "", true, "192.0.2.1"},
+		{"forwarded by proxy", "203.0.113.9", true, "203.0.113.9"},
+		{"last entry is the one the proxy appended", "10.0.0.1, 203.0.113.9", true, "203.0.113.9"},
+		{"spoofed first entry is ignored", "198.51.100.1,203.0.113.9 ", true, "203.0.113.9"},
+		{"empty last entry", "203.0.113.9, ", true, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/chat/stream", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(req, tt.trustProxy); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientLimiterPerClient(t *testing.T) {
+	l := newClientLimiter(2)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := l.allow("192.0.2.1"); !allowed {
+			t.Fatalf("request %d rejected, want the burst of 2 allowed", i+1)
+		}
+	}
+	allowed, retryAfter := l.allow("192.0.2.1")
+	if allowed {
+		t.Fatal("third request allowed, want it rejected")
+	}
+	if retryAfter <= 0 || retryAfter > 30*time.Second {
+		t.Errorf("retry after %s, want up to the 30s a token takes", retryAfter)
+	}
+	if allowed, _ := l.allow("198.51.100.7"); !allowed {
+		t.Error("another client rejected, want its own bucket")
+	}
+}
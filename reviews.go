@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultReviewSelectors match common review, comment and testimonial markup
+const defaultReviewSelectors = "[itemprop='review'], .review, .review-text, .comment-body, .comment-content, .testimonial, blockquote.quote"
+
+// maxReviews bounds how many review blocks are kept per page
+const maxReviews = 50
+
+var (
+	positiveReviewWords = []string{"great", "excellent", "amazing", "love", "recommend", "helpful", "fantastic", "perfect", "happy", "best", "professional", "reliable", "friendly", "awesome", "satisfied"}
+	negativeReviewWords = []string{"bad", "poor", "terrible", "awful", "disappoint", "worst", "slow", "rude", "broken", "refund", "waste", "unhelpful", "horrible", "never again", "avoid"}
+)
+
+// extractReviews collects the text of review/comment blocks, skipping duplicates and blocks
+// nested in an already captured one
+func (w *WebScraper) extractReviews(doc *goquery.Document) []string {
+	var reviews []string
+	seen := make(map[string]bool)
+	doc.Find(w.reviewSelectors).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if len(reviews) >= maxReviews {
+			return false
+		}
+		if s.ParentsFiltered(w.reviewSelectors).Length() > 0 {
+			return true
+		}
+
+		text := allWhitespace.ReplaceAllString(strings.TrimSpace(s.Text()), " ")
+		if len(text) <= w.minTextLength || seen[text] {
+			return true
+		}
+		seen[text] = true
+		reviews = append(reviews, text)
+		return true
+	})
+	return reviews
+}
+
+// summarizeReviewSentiment classifies each review by counting positive and negative keywords
+// and returns an aggregate like "4 positive, 1 negative, 2 neutral"
+func summarizeReviewSentiment(reviews []string) string {
+	positive, negative, neutral := 0, 0, 0
+	for _, review := range reviews {
+		lower := strings.ToLower(review)
+		score := 0
+		for _, word := range positiveReviewWords {
+			score += strings.Count(lower, word)
+		}
+		for _, word := range negativeReviewWords {
+			score -= strings.Count(lower, word)
+		}
+
+		switch {
+		case score > 0:
+			positive++
+		case score < 0:
+			negative++
+		default:
+			neutral++
+		}
+	}
+	return fmt.Sprintf("%d positive, %d negative, %d neutral", positive, negative, neutral)
+}
@@ -1,34 +1,59 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/html"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
 type WebScraper struct {
 	client              *http.Client
-	cache               map[string]WebsiteContent
+	cookieJar           http.CookieJar    // Shared by every fetch of a session; nil when disabled
+	transport           http.RoundTripper // Routes every scraper request through HTTP_PROXY_URL; nil for Go defaults
+	clearCookies        bool
+	cache               *lruCache[string, WebsiteContent]
 	pdfExtractor        *PDFExtractor
-	pdfCache            map[string]*PDFContent
+	pdfCache            *lruCache[string, *PDFContent]
 	fileParser          *FileParser
-	fileCache           map[string]*FileContent
+	fileCache           *lruCache[string, *FileContent]
 	allowedUrlPatterns  []string
+	allowedUrlRegexes   []*regexp.Regexp
+	urlPatternsSet      bool
+	mu                  sync.Mutex // Guards the scrape log, visited URLs and page count during concurrent scraping
 	scrapedUrls         []ScrapedUrl
+	maxScrapeLogEntries int
+	collectWarnings     bool            // Keep the session's warnings for GET /scrape/log
+	warnings            []ScrapeWarning // Guarded by mu
+	scrapeLogStats      ScrapeLogStats
 	enableInternalLinks bool
 	refreshContent      bool
 	cacheDir            string
@@ -38,17 +63,108 @@ type WebScraper struct {
 	visitedUrls         map[string]bool
 	maxPagesPerSession  int
 	scrapedPagesCount   int
+	whitespacePolicy    string
+	pdfPartPattern      *regexp.Regexp
+	maxLinkCandidates   int
+	maxFanoutPerPage    int // Nested links followed from one linked page, 0 for unlimited
+	scrapeWorkers       int
+	groupLogByHost      bool
+	maxLinkTitleLength  int
+	enrichLinksLight    bool
+	paginationTemplate  string
+	maxPaginationPages  int
+	minCacheTextLength  int
+	failureCacheTTL     time.Duration
+	documentRetries     int
+	maxDownloadBytes    int64                              // Largest page or document body read, 0 for unlimited
+	documentPrefetch    int                                // Concurrent document downloads ahead of parsing, 0 disables
+	prefetched          map[string]chan prefetchedDocument // Downloads started by startDocumentPrefetch
+	pageRetries         int
+	maxTotalRetries     int // Retries allowed across a whole scraping session, negative for unlimited
+	hostLimiter         *hostLimiter
+	professionalDomains []string
+	upgradeMixedContent bool
+	blockMixedContent   bool
+	pagesScraped        atomic.Int64 // Lifetime counters for GET /stats
+	cacheHits           atomic.Int64
+	cacheMisses         atomic.Int64
+	documentFailures    map[string]documentFailure
+	pageFailures        map[string]pageFailure // Guarded by mu
+	negativeCacheTTL    time.Duration
+	extractOutline      bool
+	extractContacts     bool
+	phones              phoneNormalizer
+	parseJSONLD         bool
+	structuredHTML      bool // Emit <dl> as "key: value" pairs and <table> as pipe-delimited rows
+	maxHTMLDepth        int  // Element nesting below which walk stops descending, 0 for unlimited
+	walkStructure       string
+	extractLanguage     bool
+	extractBreadcrumbs  bool
+	// Embeds content for semantic search; nil when disabled
+	embedder            contentEmbedder
+	keepSnapshots       bool // Keep the replaced content.json as previous.json for GET /diff
+	cacheVersions       int  // Timestamped copies of changed content kept per URL, 0 for none
+	walkIndentDepth     int
+	maxDocumentDepth    int
+	followDocumentLinks bool
+	enableSitemap       bool
+	sameDomainOnly      bool
+	seedDomain          string // Registrable domain of the site being scraped, for sameDomainOnly
+	persistLinkedPages  bool   // Reuse linked pages saved to disk by earlier sessions
+	skipLinkedCache     bool   // Set while refreshing, so linked pages are fetched again too
+	keyIgnoreCase       bool
+	keyStripSlash       bool
+	slowOpThreshold     time.Duration
+	includeReviews      bool
+	reviewSelectors     string
+	reviewSentiment     bool
+}
+
+// documentFailure remembers a failed PDF/file extraction so it isn't retried on every crawl
+type documentFailure struct {
+	err      string
+	failedAt time.Time
+}
+
+// pageFailure remembers a linked page that failed for a non-transient reason (e.g. a 404),
+// so crawls within NEGATIVE_CACHE_TTL don't request it again
+type pageFailure struct {
+	kind     string // "http_404", "not_allowed", "parse_error", ...
+	err      string
+	failedAt time.Time
+}
+
+// HTTPStatusError reports an unexpected HTTP status code from a download
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("status code %d", e.StatusCode)
 }
 
 type ScrapedUrl struct {
-	URL         string
-	Type        string // "main", "linked", "first_level", "pdf", "file"
-	Title       string
-	Success     bool
-	Error       string
-	ScrapedAt   time.Time
-	Relevance   int
-	ContentType string
+	URL         string    `json:"url"`
+	Type        string    `json:"type"` // "main", "linked", "first_level", "pdf", "pdf_merge", "file", "link_preview", "pagination", "mixed_content", "doc_link"
+	Title       string    `json:"title"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	ScrapedAt   time.Time `json:"scraped_at"`
+	Relevance   int       `json:"relevance"`
+	ContentType string    `json:"content_type"`
+	LinkedFrom  string    `json:"linked_from,omitempty"` // Document that linked this one, for documents found inside other documents
+}
+
+// ScrapeLogStats aggregates the scraping log; the counters cover every recorded entry,
+// including those rotated out of the retained log
+type ScrapeLogStats struct {
+	Total       int            `json:"total"`
+	Retained    int            `json:"retained"`
+	Success     int            `json:"success"`
+	Failed      int            `json:"failed"`
+	Pages       int            `json:"pages"` // Successful entries of pages downloaded in this crawl, see fetchedPage
+	ByType      map[string]int `json:"by_type"`
+	RetriesUsed int            `json:"retries_used"`
 }
 
 type WebsiteContent struct {
@@ -60,7 +176,38 @@ type WebsiteContent struct {
 	FileContent   map[string]*FileContent
 	LinkedContent map[string]*LinkedPageContent
 	Metadata      map[string]string
-	LastUpdated   time.Time
+	Outline       []OutlineEntry `json:",omitempty"`
+	Breadcrumbs   []string       `json:",omitempty"` // Position in the site hierarchy, root first, when EXTRACT_BREADCRUMBS is enabled
+	Reviews       []string       `json:",omitempty"` // User review/comment blocks, when INCLUDE_REVIEWS is enabled
+	ReviewSummary string         `json:",omitempty"` // Aggregate review sentiment, when REVIEW_SENTIMENT is enabled
+	Contacts      []ContactCard  `json:",omitempty"` // <address> and h-card contact details, when EXTRACT_CONTACTS is enabled
+	// JSON-LD entities of the main page merged by @type, when EXTRACT_STRUCTURED_DATA is enabled
+	StructuredData map[string]interface{} `json:",omitempty"`
+	// Linked pages and documents that could not be loaded while scraping this site
+	UnavailableSources []UnavailableSource `json:",omitempty"`
+	// Validators of the main page response, sent back as If-None-Match/If-Modified-Since on the next scrape
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	// SHA-256 of the scraped text, so clients can tell when the content behind an answer changed
+	ContentHash string `json:",omitempty"`
+	LastUpdated time.Time
+
+	// Embedded chunks of the text for semantic search, when ENABLE_SEMANTIC_SEARCH is enabled
+	Chunks         []ContentChunk `json:",omitempty"`
+	EmbeddingModel string         `json:",omitempty"`
+}
+
+// UnavailableSource is a linked page or document that failed to load, with a short reason
+type UnavailableSource struct {
+	URL    string
+	Type   string
+	Reason string // e.g. "not found", "access denied", "timed out"
+}
+
+// OutlineEntry is one h1-h3 heading of a page, in document order
+type OutlineEntry struct {
+	Level int
+	Text  string
 }
 
 type LinkedPageContent struct {
@@ -72,6 +219,10 @@ type LinkedPageContent struct {
 	Relevance       int    // 1-10 relevance score
 	ContentType     string // "professional", "blog", "project", "general"
 	FirstLevelLinks []FirstLevelLink
+	PublishedAt     time.Time     // Most recent publish/modified date found in the page, zero if unknown
+	Contacts        []ContactCard `json:",omitempty"`
+	Language        string        `json:",omitempty"` // Dominant lang attribute of the text, when EXTRACT_LANGUAGE is enabled
+	Breadcrumbs     []string      `json:",omitempty"`
 	LastUpdated     time.Time
 }
 
@@ -88,21 +239,38 @@ type Link struct {
 	URL   string
 	Title string
 	Type  string
+	// Lightweight metadata of the target page, filled when ENRICH_LINKS_LIGHT is enabled
+	PageTitle       string `json:",omitempty"`
+	PageDescription string `json:",omitempty"`
 }
 
 func NewWebScraper() *WebScraper {
 	// Parse allowed URL patterns from environment variable
 	allowedPatternsStr := os.Getenv("ALLOWED_SCRAPING_URL_PATTERNS")
 	var allowedUrlPatterns []string
+	var allowedUrlRegexes []*regexp.Regexp
 
 	if allowedPatternsStr != "" {
 		// Split by comma and trim whitespace
 		patterns := strings.Split(allowedPatternsStr, ",")
 		for _, pattern := range patterns {
 			trimmed := strings.TrimSpace(pattern)
-			if trimmed != "" {
-				allowedUrlPatterns = append(allowedUrlPatterns, strings.ToLower(trimmed))
+			if trimmed == "" {
+				continue
+			}
+
+			// Patterns prefixed with "re:" are case-sensitive regular expressions
+			if expr, isRegex := strings.CutPrefix(trimmed, "re:"); isRegex {
+				compiled, err := regexp.Compile(expr)
+				if err != nil {
+					logWarning("invalid_config", "Ignoring invalid URL pattern regex", "setting", "ALLOWED_SCRAPING_URL_PATTERNS", "value", expr, "error", err)
+					continue
+				}
+				allowedUrlRegexes = append(allowedUrlRegexes, compiled)
+				continue
 			}
+
+			allowedUrlPatterns = append(allowedUrlPatterns, strings.ToLower(trimmed))
 		}
 	}
 
@@ -144,23 +312,296 @@ func NewWebScraper() *WebScraper {
 		}
 	}
 
+	// Parse whitespace normalization policy (default: preserve)
+	whitespacePolicy := parseWhitespacePolicy()
+
+	// Parse maximum number of links considered for scraping per page (default: 50)
+	maxLinkCandidates := 50
+	if maxCandidatesStr := os.Getenv("MAX_LINK_CANDIDATES"); maxCandidatesStr != "" {
+		if parsed, err := strconv.Atoi(maxCandidatesStr); err == nil && parsed > 0 {
+			maxLinkCandidates = parsed
+		}
+	}
+
+	// Parse maximum nested links followed from a single linked page (default: 0, unlimited)
+	maxFanoutPerPage := 0
+	if maxFanoutStr := os.Getenv("MAX_FANOUT_PER_PAGE"); maxFanoutStr != "" {
+		if parsed, err := strconv.Atoi(maxFanoutStr); err == nil && parsed >= 0 {
+			maxFanoutPerPage = parsed
+		}
+	}
+
+	// Parse how many linked pages are scraped in parallel (default: 4, SCRAPER_CONCURRENCY is an alias)
+	maxConcurrentScrapes := 4
+	maxConcurrentStr := os.Getenv("MAX_CONCURRENT_SCRAPES")
+	if maxConcurrentStr == "" {
+		maxConcurrentStr = os.Getenv("SCRAPER_CONCURRENCY")
+	}
+	if maxConcurrentStr != "" {
+		if parsed, err := strconv.Atoi(maxConcurrentStr); err == nil && parsed > 0 {
+			maxConcurrentScrapes = parsed
+		}
+	}
+
+	// Parse maximum link anchor text length (default: 100)
+	maxLinkTitleLength := 100
+	if maxTitleStr := os.Getenv("MAX_LINK_TITLE_LENGTH"); maxTitleStr != "" {
+		if parsed, err := strconv.Atoi(maxTitleStr); err == nil && parsed > 0 {
+			maxLinkTitleLength = parsed
+		}
+	}
+
+	// Check if lightweight title/description enrichment of outbound links is enabled
+	enrichLinksLight := strings.ToLower(os.Getenv("ENRICH_LINKS_LIGHT")) == "true"
+
+	// Parse pagination probing template, e.g. "?page={n}" (optional)
+	paginationTemplate := strings.TrimSpace(os.Getenv("PAGINATION_TEMPLATE"))
+	if paginationTemplate != "" && !strings.Contains(paginationTemplate, "{n}") {
+		logWarning("invalid_config", "PAGINATION_TEMPLATE has no {n} placeholder, pagination probing disabled", "setting", "PAGINATION_TEMPLATE", "value", paginationTemplate)
+		paginationTemplate = ""
+	}
+
+	// Parse maximum page number probed via the pagination template (default: 10)
+	maxPaginationPages := 10
+	if maxPaginationStr := os.Getenv("MAX_PAGINATION_PAGES"); maxPaginationStr != "" {
+		if parsed, err := strconv.Atoi(maxPaginationStr); err == nil && parsed > 1 {
+			maxPaginationPages = parsed
+		}
+	}
+
+	// Group the scraping log by host and disambiguate colliding titles (default: true)
+	groupLogByHost := strings.ToLower(os.Getenv("SCRAPE_LOG_GROUP_BY_HOST")) != "false"
+
+	// Parse multi-part PDF file name pattern (optional, groups: base name and part number)
+	var pdfPartPattern *regexp.Regexp
+	if pattern := os.Getenv("PDF_PART_PATTERN"); pattern != "" {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			logWarning("invalid_config", "Invalid PDF_PART_PATTERN, multi-part PDF merging disabled", "setting", "PDF_PART_PATTERN", "value", pattern, "error", err)
+		} else if compiled.NumSubexp() < 2 {
+			logWarning("invalid_config", "PDF_PART_PATTERN needs two groups (base name, part number), multi-part PDF merging disabled", "setting", "PDF_PART_PATTERN", "value", pattern)
+		} else {
+			pdfPartPattern = compiled
+		}
+	}
+
+	// Parse minimum extracted text length required to cache content on disk (default: 50)
+	minCacheTextLength := 50
+	if minCacheStr := os.Getenv("MIN_CACHE_CONTENT_LENGTH"); minCacheStr != "" {
+		if parsed, err := strconv.Atoi(minCacheStr); err == nil && parsed >= 0 {
+			minCacheTextLength = parsed
+		}
+	}
+
+	// Parse how long failed PDF/file extractions are remembered (default: 30 minutes, 0 disables)
+	failureCacheMinutes := 30
+	if failureCacheStr := os.Getenv("FAILURE_CACHE_MINUTES"); failureCacheStr != "" {
+		if parsed, err := strconv.Atoi(failureCacheStr); err == nil && parsed >= 0 {
+			failureCacheMinutes = parsed
+		}
+	}
+
+	// Parse how long linked pages that failed permanently are skipped (default: 0, disabled),
+	// as a duration like "6h" or a number of minutes
+	var negativeCacheTTL time.Duration
+	if ttlStr := os.Getenv("NEGATIVE_CACHE_TTL"); ttlStr != "" {
+		if parsed, err := time.ParseDuration(ttlStr); err == nil && parsed >= 0 {
+			negativeCacheTTL = parsed
+		} else if minutes, err := strconv.Atoi(ttlStr); err == nil && minutes >= 0 {
+			negativeCacheTTL = time.Duration(minutes) * time.Minute
+		} else {
+			logWarning("invalid_config", "Invalid NEGATIVE_CACHE_TTL, negative caching disabled", "setting", "NEGATIVE_CACHE_TTL", "value", ttlStr)
+		}
+	}
+
+	// Parse retries for transient PDF/file download errors (default: 2)
+	documentRetries := 2
+	if retriesStr := os.Getenv("DOCUMENT_DOWNLOAD_RETRIES"); retriesStr != "" {
+		if parsed, err := strconv.Atoi(retriesStr); err == nil && parsed >= 0 {
+			documentRetries = parsed
+		}
+	}
+
+	// Parse concurrent document downloads started ahead of parsing (default: 0, disabled)
+	documentPrefetch := 0
+	if prefetchStr := os.Getenv("DOCUMENT_PREFETCH_CONCURRENCY"); prefetchStr != "" {
+		if parsed, err := strconv.Atoi(prefetchStr); err == nil && parsed >= 0 {
+			documentPrefetch = parsed
+		}
+	}
+
+	// Parse retries for page fetches failing with 408/429/5xx or a timeout (default: 3)
+	pageRetries := 3
+	if retriesStr := os.Getenv("SCRAPE_MAX_RETRIES"); retriesStr != "" {
+		if parsed, err := strconv.Atoi(retriesStr); err == nil && parsed >= 0 {
+			pageRetries = parsed
+		}
+	}
+
+	// Parse requests per second allowed to each host (default: 2, 0 = unlimited)
+	requestsPerSecond := 2.0
+	if rateStr := os.Getenv("SCRAPER_REQUESTS_PER_SECOND"); rateStr != "" {
+		if parsed, err := strconv.ParseFloat(rateStr, 64); err == nil && parsed >= 0 {
+			requestsPerSecond = parsed
+		}
+	}
+
+	// Parse the retry budget shared by all downloads of a scraping session (default: 20, negative = unlimited)
+	maxTotalRetries := 20
+	if totalRetriesStr := os.Getenv("MAX_TOTAL_RETRIES"); totalRetriesStr != "" {
+		if parsed, err := strconv.Atoi(totalRetriesStr); err == nil {
+			maxTotalRetries = parsed
+		}
+	}
+
+	// Mixed-content policy for http documents linked from https pages: try https first (default: true),
+	// and optionally never fetch the insecure URL (default: false)
+	upgradeMixedContent := strings.ToLower(os.Getenv("UPGRADE_MIXED_CONTENT")) != "false"
+	blockMixedContent := strings.ToLower(os.Getenv("BLOCK_MIXED_CONTENT")) == "true"
+
+	// Check if the h1-h3 heading outline should be extracted (default: true)
+	extractOutline := strings.ToLower(os.Getenv("EXTRACT_OUTLINE")) != "false"
+
+	// Check if <address> elements and h-card microformats should be parsed into contact cards (default: true)
+	extractContacts := strings.ToLower(os.Getenv("EXTRACT_CONTACTS")) != "false"
+
+	// Check if the previous version of changed cached content should be kept for GET /diff (default: false)
+	keepSnapshots := strings.ToLower(os.Getenv("ENABLE_CONTENT_DIFF")) == "true"
+
+	// Check if breadcrumb trails should be extracted from JSON-LD and breadcrumb navs (default: true)
+	extractBreadcrumbs := strings.ToLower(os.Getenv("EXTRACT_BREADCRUMBS")) != "false"
+
+	// Parse how many timestamped versions of changed content are kept per URL (default: 0, disabled)
+	cacheVersions := 0
+	if versionsStr := os.Getenv("CACHE_VERSIONS"); versionsStr != "" {
+		if parsed, err := strconv.Atoi(versionsStr); err == nil && parsed >= 0 {
+			cacheVersions = parsed
+		}
+	}
+
+	// Check if the language of page text should be taken from lang attributes (default: true)
+	extractLanguage := strings.ToLower(os.Getenv("EXTRACT_LANGUAGE")) != "false"
+
+	// Check if JSON-LD structured data should be extracted from the main page (default: true)
+	parseJSONLD := strings.ToLower(os.Getenv("EXTRACT_STRUCTURED_DATA")) != "false"
+
+	// Check if definition lists and tables should keep their structure (default: true)
+	structuredHTML := strings.ToLower(os.Getenv("EXTRACT_STRUCTURED_HTML")) != "false"
+
+	// Parse how linked page text shows the nesting of its elements: flat, indent or tags (default: flat)
+	walkStructure := WalkStructureFlat
+	switch strings.ToLower(os.Getenv("WALK_STRUCTURE")) {
+	case WalkStructureIndent:
+		walkStructure = WalkStructureIndent
+	case WalkStructureTags:
+		walkStructure = WalkStructureTags
+	}
+
+	// Parse maximum indentation levels written by WALK_STRUCTURE, deeper elements stay at the last level (default: 4)
+	walkIndentDepth := 4
+	if indentDepthStr := os.Getenv("WALK_INDENT_DEPTH"); indentDepthStr != "" {
+		if parsed, err := strconv.Atoi(indentDepthStr); err == nil && parsed >= 0 {
+			walkIndentDepth = parsed
+		}
+	}
+
+	maxHTMLDepth := parseMaxHTMLDepth()
+
+	// Parse how many levels of links inside PDFs/files to follow (default: 0, disabled)
+	maxDocumentDepth := 0
+	if depthStr := os.Getenv("MAX_DOCUMENT_DEPTH"); depthStr != "" {
+		if parsed, err := strconv.Atoi(depthStr); err == nil && parsed >= 0 {
+			maxDocumentDepth = parsed
+		}
+	}
+
+	// Check if web pages linked from inside PDFs/files should be scraped (default: false)
+	followDocumentLinks := strings.ToLower(os.Getenv("FOLLOW_DOCUMENT_LINKS")) == "true"
+
+	// Check if pages listed in the site's sitemap.xml should be scraped too (default: false)
+	enableSitemap := strings.ToLower(os.Getenv("ENABLE_SITEMAP")) == "true"
+
+	// Check if crawling should stay within the registrable domain of the scraped site (default: false)
+	sameDomainOnly := strings.ToLower(os.Getenv("SAME_DOMAIN_ONLY")) == "true"
+
+	// Check if linked pages should be saved to disk and reused across sessions (default: true)
+	persistLinkedPages := strings.ToLower(os.Getenv("PERSIST_LINKED_PAGES")) != "false"
+
+	// Parse maximum entries of each in-memory content cache (default: 500, 0 = unlimited)
+	memoryCacheMaxEntries := 500
+	if maxEntriesStr := os.Getenv("MEMORY_CACHE_MAX_ENTRIES"); maxEntriesStr != "" {
+		if parsed, err := strconv.Atoi(maxEntriesStr); err == nil && parsed >= 0 {
+			memoryCacheMaxEntries = parsed
+		}
+	}
+
+	// Parse maximum retained scraping log entries (default: 1000, 0 = unlimited)
+	maxScrapeLogEntries := 1000
+	if maxEntriesStr := os.Getenv("MAX_SCRAPE_LOG_ENTRIES"); maxEntriesStr != "" {
+		if parsed, err := strconv.Atoi(maxEntriesStr); err == nil && parsed >= 0 {
+			maxScrapeLogEntries = parsed
+		}
+	}
+
+	// Check if the warnings of each scraping session should be kept for GET /scrape/log (default: false)
+	collectWarnings := strings.ToLower(os.Getenv("COLLECT_SCRAPE_WARNINGS")) == "true"
+
+	// Cache/visited key normalization; fetches always use the URL as given (both default: true)
+	keyIgnoreCase := strings.ToLower(os.Getenv("URL_KEY_IGNORE_CASE")) != "false"
+	keyStripSlash := strings.ToLower(os.Getenv("URL_KEY_STRIP_TRAILING_SLASH")) != "false"
+
+	// Parse review/comment extraction settings (default: disabled)
+	includeReviews := strings.ToLower(os.Getenv("INCLUDE_REVIEWS")) == "true"
+	// Parse extra professional link domains; PROFESSIONAL_LINK_DOMAINS_MODE=replace drops the built-in list
+	professionalDomains := parseProfessionalDomains(os.Getenv("PROFESSIONAL_LINK_DOMAINS"),
+		strings.ToLower(os.Getenv("PROFESSIONAL_LINK_DOMAINS_MODE")) == "replace")
+
+	reviewSelectors := os.Getenv("REVIEW_SELECTORS")
+	if reviewSelectors == "" {
+		reviewSelectors = defaultReviewSelectors
+	}
+	reviewSentiment := strings.ToLower(os.Getenv("REVIEW_SENTIMENT")) == "true"
+
+	// Parse cookie jar settings, so session cookies set by one fetch carry to the next (default: disabled)
+	enableCookieJar := strings.ToLower(os.Getenv("ENABLE_COOKIE_JAR")) == "true"
+	clearCookies := strings.ToLower(os.Getenv("CLEAR_COOKIES_BETWEEN_SESSIONS")) != "false"
+
 	// Create cache directory
 	cacheDir := "scraped_content"
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		fmt.Printf("Warning: Could not create cache directory: %v\n", err)
+		logWarning("cache_dir_failed", "Could not create cache directory", "path", cacheDir, "error", err)
 	}
 
-	return &WebScraper{
+	// Parse explicit egress proxy for all scraper requests (default: Go's environment proxy settings);
+	// main() validates it at startup, so an invalid value only warns here
+	var transport http.RoundTripper
+	if proxyURL, err := parseProxyURL(os.Getenv("HTTP_PROXY_URL")); err != nil {
+		logWarning("invalid_config", "Ignoring HTTP_PROXY_URL", "setting", "HTTP_PROXY_URL", "error", err)
+	} else if proxyURL != nil {
+		proxyTransport := http.DefaultTransport.(*http.Transport).Clone()
+		proxyTransport.Proxy = http.ProxyURL(proxyURL)
+		transport = proxyTransport
+	}
+
+	scraper := &WebScraper{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport,
 		},
-		cache:               make(map[string]WebsiteContent),
+		transport:           transport,
+		clearCookies:        clearCookies,
+		cache:               newLRUCache[string, WebsiteContent](memoryCacheMaxEntries),
 		pdfExtractor:        NewPDFExtractor(),
-		pdfCache:            make(map[string]*PDFContent),
+		pdfCache:            newLRUCache[string, *PDFContent](memoryCacheMaxEntries),
 		fileParser:          NewFileParser(),
-		fileCache:           make(map[string]*FileContent),
+		fileCache:           newLRUCache[string, *FileContent](memoryCacheMaxEntries),
 		allowedUrlPatterns:  allowedUrlPatterns,
+		allowedUrlRegexes:   allowedUrlRegexes,
+		urlPatternsSet:      strings.TrimSpace(allowedPatternsStr) != "",
 		scrapedUrls:         make([]ScrapedUrl, 0),
+		maxScrapeLogEntries: maxScrapeLogEntries,
+		collectWarnings:     collectWarnings,
+		scrapeLogStats:      ScrapeLogStats{ByType: make(map[string]int)},
 		enableInternalLinks: enableInternal,
 		refreshContent:      refreshContent,
 		cacheDir:            cacheDir,
@@ -170,7 +611,61 @@ func NewWebScraper() *WebScraper {
 		visitedUrls:         make(map[string]bool),
 		maxPagesPerSession:  maxPagesPerSession,
 		scrapedPagesCount:   0,
-	}
+		whitespacePolicy:    whitespacePolicy,
+		pdfPartPattern:      pdfPartPattern,
+		maxLinkCandidates:   maxLinkCandidates,
+		maxFanoutPerPage:    maxFanoutPerPage,
+		scrapeWorkers:       maxConcurrentScrapes,
+		groupLogByHost:      groupLogByHost,
+		maxLinkTitleLength:  maxLinkTitleLength,
+		enrichLinksLight:    enrichLinksLight,
+		paginationTemplate:  paginationTemplate,
+		maxPaginationPages:  maxPaginationPages,
+		minCacheTextLength:  minCacheTextLength,
+		failureCacheTTL:     time.Duration(failureCacheMinutes) * time.Minute,
+		pageFailures:        make(map[string]pageFailure),
+		negativeCacheTTL:    negativeCacheTTL,
+		documentRetries:     documentRetries,
+		maxDownloadBytes:    parseMaxDownloadBytes(),
+		documentPrefetch:    documentPrefetch,
+		pageRetries:         pageRetries,
+		maxTotalRetries:     maxTotalRetries,
+		hostLimiter:         newHostLimiter(requestsPerSecond),
+		professionalDomains: professionalDomains,
+		upgradeMixedContent: upgradeMixedContent,
+		blockMixedContent:   blockMixedContent,
+		documentFailures:    make(map[string]documentFailure),
+		extractOutline:      extractOutline,
+		extractContacts:     extractContacts,
+		phones:              newPhoneNormalizer(),
+		parseJSONLD:         parseJSONLD,
+		structuredHTML:      structuredHTML,
+		maxHTMLDepth:        maxHTMLDepth,
+		walkStructure:       walkStructure,
+		extractLanguage:     extractLanguage,
+		extractBreadcrumbs:  extractBreadcrumbs,
+		keepSnapshots:       keepSnapshots,
+		cacheVersions:       cacheVersions,
+		walkIndentDepth:     walkIndentDepth,
+		maxDocumentDepth:    maxDocumentDepth,
+		followDocumentLinks: followDocumentLinks,
+		enableSitemap:       enableSitemap,
+		sameDomainOnly:      sameDomainOnly,
+		persistLinkedPages:  persistLinkedPages,
+		keyIgnoreCase:       keyIgnoreCase,
+		keyStripSlash:       keyStripSlash,
+		slowOpThreshold:     parseSlowOpThreshold(),
+		includeReviews:      includeReviews,
+		reviewSelectors:     reviewSelectors,
+		reviewSentiment:     reviewSentiment,
+	}
+
+	scraper.pdfExtractor.client.Transport = transport
+	scraper.fileParser.client.Transport = transport
+	if enableCookieJar {
+		scraper.resetCookieJar()
+	}
+	return scraper
 }
 
 // generateSafeDirectoryName creates a safe directory name from a URL
@@ -185,7 +680,7 @@ func (w *WebScraper) generateSafeDirectoryName(targetUrl string) string {
 	}
 
 	// Create a safe directory name: domain + path hash
-	domain := parsedURL.Host
+	domain := canonicalHost(parsedURL.Host)
 	path := parsedURL.Path
 	query := parsedURL.RawQuery
 
@@ -214,22 +709,39 @@ func (w *WebScraper) generateSafeDirectoryName(targetUrl string) string {
 	return domainSafe + "_" + pathHash
 }
 
-// getContentFilePath returns the file path for storing content
+// getContentFilePath returns the file path for storing content. It creates nothing, since lookups
+// pass URLs from requests; saveContentToDisk creates the directory.
 func (w *WebScraper) getContentFilePath(targetUrl string) string {
-	dirName := w.generateSafeDirectoryName(targetUrl)
-	dirPath := filepath.Join(w.cacheDir, dirName)
+	return filepath.Join(w.cacheDir, w.generateSafeDirectoryName(targetUrl), "content.json")
+}
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		fmt.Printf("Warning: Could not create directory %s: %v\n", dirPath, err)
+// extractedTextLength returns the total length of text extracted from the page and everything it links to
+func extractedTextLength(content *WebsiteContent) int {
+	total := len(strings.TrimSpace(content.Text))
+	for _, linked := range content.LinkedContent {
+		total += len(strings.TrimSpace(linked.Text))
 	}
-
-	return filepath.Join(dirPath, "content.json")
+	for _, pdf := range content.PDFContent {
+		total += len(strings.TrimSpace(pdf.Text))
+	}
+	for _, file := range content.FileContent {
+		total += len(strings.TrimSpace(file.Text))
+	}
+	return total
 }
 
 // saveContentToDisk saves website content to disk
 func (w *WebScraper) saveContentToDisk(targetUrl string, content *WebsiteContent) error {
+	// Don't persist essentially empty scrapes, so the next request scrapes again instead of serving them
+	if textLength := extractedTextLength(content); textLength < w.minCacheTextLength {
+		fmt.Printf("Skipping disk cache for %s: only %d characters extracted (minimum %d)\n", targetUrl, textLength, w.minCacheTextLength)
+		return nil
+	}
+
 	filePath := w.getContentFilePath(targetUrl)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
 
 	// Create a wrapper structure to include the URL
 	wrapper := struct {
@@ -247,9 +759,15 @@ func (w *WebScraper) saveContentToDisk(targetUrl string, content *WebsiteContent
 		return fmt.Errorf("failed to marshal content: %v", err)
 	}
 
+	if w.keepSnapshots {
+		w.keepPreviousSnapshot(filePath, content)
+	}
 	if err := ioutil.WriteFile(filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %v", err)
 	}
+	if w.cacheVersions > 0 {
+		w.saveContentVersion(filepath.Dir(filePath), data, content, wrapper.SavedAt)
+	}
 
 	fmt.Printf("Content saved to: %s\n", filePath)
 	return nil
@@ -279,18 +797,49 @@ func (w *WebScraper) loadContentFromDisk(targetUrl string) (*WebsiteContent, err
 		return nil, fmt.Errorf("failed to unmarshal content: %v", err)
 	}
 
+	// Content saved before hashes were stored
+	if wrapper.Content != nil && wrapper.Content.ContentHash == "" {
+		wrapper.Content.ContentHash = computeContentHash(wrapper.Content)
+	}
+
 	fmt.Printf("Content loaded from: %s (saved at %s)\n", filePath, wrapper.SavedAt.Format("2006-01-02 15:04:05"))
 	return wrapper.Content, nil
 }
 
-// normalizeURL normalizes a URL for consistent loop detection
+// computeContentHash hashes the text of the main page, linked pages and documents in a stable
+// order. Timestamps and validators are left out, so re-scraping unchanged content keeps the hash.
+func computeContentHash(content *WebsiteContent) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", content.Title, content.Description, content.Text)
+	for _, pageURL := range sortedKeys(content.LinkedContent) {
+		fmt.Fprintf(h, "%s\x00%s\x00", pageURL, content.LinkedContent[pageURL].Text)
+	}
+	for _, pdfURL := range sortedKeys(content.PDFContent) {
+		fmt.Fprintf(h, "%s\x00%s\x00", pdfURL, content.PDFContent[pdfURL].Text)
+	}
+	for _, fileURL := range sortedKeys(content.FileContent) {
+		fmt.Fprintf(h, "%s\x00%s\x00", fileURL, content.FileContent[fileURL].Text)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeURL builds the key used for the memory cache and loop detection.
+// The key is never fetched, so it may lowercase the path; requests use the original URL.
 func (w *WebScraper) normalizeURL(targetUrl string) string {
 	// Parse URL to normalize it
-	parsedURL, err := url.Parse(strings.ToLower(targetUrl))
+	parsedURL, err := url.Parse(targetUrl)
 	if err != nil {
 		return strings.ToLower(targetUrl) // fallback
 	}
 
+	// Scheme and host are case-insensitive; the path only optionally
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+	parsedURL.Host = canonicalHost(parsedURL.Host)
+	if w.keyIgnoreCase {
+		parsedURL.Path = strings.ToLower(parsedURL.Path)
+		parsedURL.RawPath = ""
+	}
+
 	// Remove common query parameters that don't affect content
 	query := parsedURL.Query()
 	query.Del("utm_source")
@@ -306,8 +855,9 @@ func (w *WebScraper) normalizeURL(targetUrl string) string {
 	parsedURL.Fragment = ""
 
 	// Remove trailing slash from path
-	if len(parsedURL.Path) > 1 && strings.HasSuffix(parsedURL.Path, "/") {
+	if w.keyStripSlash && len(parsedURL.Path) > 1 && strings.HasSuffix(parsedURL.Path, "/") {
 		parsedURL.Path = strings.TrimSuffix(parsedURL.Path, "/")
+		parsedURL.RawPath = ""
 	}
 
 	return parsedURL.String()
@@ -316,23 +866,58 @@ func (w *WebScraper) normalizeURL(targetUrl string) string {
 // isURLVisited checks if a URL has been visited (with normalization)
 func (w *WebScraper) isURLVisited(targetUrl string) bool {
 	normalizedUrl := w.normalizeURL(targetUrl)
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.visitedUrls[normalizedUrl]
 }
 
 // markURLVisited marks a URL as visited (with normalization)
 func (w *WebScraper) markURLVisited(targetUrl string) {
 	normalizedUrl := w.normalizeURL(targetUrl)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.visitedUrls[normalizedUrl] = true
+}
+
+// claimURL marks a URL as visited unless it already was, optionally counting it against the page
+// budget. Checking and marking happen atomically, so concurrent workers never fetch a URL twice
+// or overshoot maxPagesPerSession.
+func (w *WebScraper) claimURL(targetUrl string, countPage bool) bool {
+	normalizedUrl := w.normalizeURL(targetUrl)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.visitedUrls[normalizedUrl] || (countPage && w.scrapedPagesCount >= w.maxPagesPerSession) {
+		return false
+	}
 	w.visitedUrls[normalizedUrl] = true
+	if countPage {
+		w.scrapedPagesCount++
+	}
+	return true
 }
 
 // canScrapeMore checks if we can scrape more pages
 func (w *WebScraper) canScrapeMore() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.scrapedPagesCount < w.maxPagesPerSession
 }
 
+// addLinkedContent stores a scraped page in the content's linked pages, which concurrent workers share
+func (w *WebScraper) addLinkedContent(content *WebsiteContent, pageURL string, linkedContent *LinkedPageContent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	content.LinkedContent[pageURL] = linkedContent
+}
+
 func (w *WebScraper) isUrlAllowed(targetUrl string) bool {
+	if !w.isInScope(targetUrl) {
+		return false
+	}
+
 	// If no allowed URL patterns are configured, allow all URLs
-	if len(w.allowedUrlPatterns) == 0 {
+	// (configured patterns that are all invalid regexes allow nothing)
+	if !w.urlPatternsSet {
 		return true
 	}
 
@@ -346,10 +931,22 @@ func (w *WebScraper) isUrlAllowed(targetUrl string) bool {
 		}
 	}
 
+	// Regex patterns match the URL as-is
+	for _, re := range w.allowedUrlRegexes {
+		if re.MatchString(targetUrl) {
+			return true
+		}
+	}
+
 	return false
 }
 
 func (w *WebScraper) recordScrapedUrl(url, urlType, title string, success bool, err error, relevance int, contentType string) {
+	w.recordScrapedUrlFrom("", url, urlType, title, success, err, relevance, contentType)
+}
+
+// recordScrapedUrlFrom records a URL that was found in the parent document, "" for one found on a page
+func (w *WebScraper) recordScrapedUrlFrom(parent, url, urlType, title string, success bool, err error, relevance int, contentType string) {
 	scrapedUrl := ScrapedUrl{
 		URL:         url,
 		Type:        urlType,
@@ -358,138 +955,493 @@ func (w *WebScraper) recordScrapedUrl(url, urlType, title string, success bool,
 		ScrapedAt:   time.Now(),
 		Relevance:   relevance,
 		ContentType: contentType,
+		LinkedFrom:  parent,
 	}
 
 	if err != nil {
 		scrapedUrl.Error = err.Error()
 	}
 
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.groupLogByHost {
+		w.disambiguateTitle(&scrapedUrl)
+	}
+
+	w.scrapeLogStats.Total++
+	w.scrapeLogStats.ByType[urlType]++
+	if success {
+		w.scrapeLogStats.Success++
+		if fetchedPage(urlType, contentType) {
+			w.scrapeLogStats.Pages++
+		}
+	} else {
+		w.scrapeLogStats.Failed++
+	}
+
 	w.scrapedUrls = append(w.scrapedUrls, scrapedUrl)
-}
 
-func (w *WebScraper) GetScrapedUrls() []ScrapedUrl {
-	return w.scrapedUrls
+	// Rotate out the oldest entries; the counters above keep the totals
+	if w.maxScrapeLogEntries > 0 && len(w.scrapedUrls) > w.maxScrapeLogEntries {
+		w.scrapedUrls = w.scrapedUrls[len(w.scrapedUrls)-w.maxScrapeLogEntries:]
+	}
 }
 
-func (w *WebScraper) ClearScrapedUrls() {
-	w.scrapedUrls = make([]ScrapedUrl, 0)
-	// Also reset visited URLs and page count for new session
-	w.visitedUrls = make(map[string]bool)
-	w.scrapedPagesCount = 0
+// fetchedPage reports whether a log entry is for a page downloaded in this crawl, rather than a
+// document, a link preview or content served from a cache
+func fetchedPage(urlType, contentType string) bool {
+	switch urlType {
+	case "pdf", "pdf_merge", "file", "link_preview", "mixed_content":
+		return false
+	}
+	switch contentType {
+	case "disk_cached", "memory_cached", "not_modified":
+		return false
+	}
+	return true
 }
 
-func (w *WebScraper) PrintScrapedUrls() {
-	fmt.Printf("\n=== SCRAPING SUMMARY ===\n")
-	fmt.Printf("Total URLs processed: %d\n", len(w.scrapedUrls))
+// maxUnavailableSources bounds how many failed sources are kept per site
+const maxUnavailableSources = 20
 
-	// Count by type and status
-	typeCount := make(map[string]int)
-	successCount := 0
-	failureCount := 0
+// unavailableSourcesSince returns the linked pages and documents that failed to load among the
+// log entries recorded after the first logStart ones. URLs skipped by configuration are left out.
+func (w *WebScraper) unavailableSourcesSince(logStart int) []UnavailableSource {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	recorded := w.scrapeLogStats.Total - logStart
+	if recorded > len(w.scrapedUrls) {
+		recorded = len(w.scrapedUrls)
+	}
 
-	for _, scraped := range w.scrapedUrls {
-		typeCount[scraped.Type]++
-		if scraped.Success {
-			successCount++
-		} else {
-			failureCount++
+	var sources []UnavailableSource
+	seen := make(map[string]bool)
+	for _, scraped := range w.scrapedUrls[len(w.scrapedUrls)-recorded:] {
+		if scraped.Success || scraped.Type == "main" || seen[scraped.URL] || strings.Contains(scraped.Error, "not allowed") {
+			continue
+		}
+		seen[scraped.URL] = true
+		sources = append(sources, UnavailableSource{URL: scraped.URL, Type: scraped.Type, Reason: failureReason(scraped)})
+		if len(sources) >= maxUnavailableSources {
+			break
 		}
 	}
+	return sources
+}
 
-	fmt.Printf("Successful: %d, Failed: %d\n", successCount, failureCount)
-	fmt.Printf("By type: ")
-	for urlType, count := range typeCount {
-		fmt.Printf("%s: %d, ", urlType, count)
+// failureReason condenses a scrape log error into a short category for the prompt
+func failureReason(scraped ScrapedUrl) string {
+	if scraped.Type == "mixed_content" {
+		return "insecure http link"
+	}
+
+	lower := strings.ToLower(scraped.Error)
+	switch {
+	case strings.Contains(lower, "404") || strings.Contains(lower, "410"):
+		return "not found"
+	case strings.Contains(lower, "401") || strings.Contains(lower, "403"):
+		return "access denied"
+	case strings.Contains(lower, "429"):
+		return "rate limited"
+	case strings.Contains(lower, "timeout") || strings.Contains(lower, "deadline exceeded"):
+		return "timed out"
+	case strings.Contains(lower, "status code 5") || strings.Contains(lower, "http 5"):
+		return "server error"
+	case strings.Contains(lower, "no such host") || strings.Contains(lower, "connection refused"):
+		return "unreachable"
+	default:
+		return "unavailable"
 	}
-	fmt.Printf("\n\n")
+}
 
-	// Print detailed list
-	fmt.Printf("Detailed scraping log:\n")
-	for i, scraped := range w.scrapedUrls {
-		status := "✓"
-		if !scraped.Success {
-			status = "✗"
-		}
+// isInScope reports whether a URL may be crawled under SAME_DOMAIN_ONLY, i.e. shares the
+// registrable domain (eTLD+1) of the site being scraped; everything is in scope otherwise
+func (w *WebScraper) isInScope(targetUrl string) bool {
+	if !w.sameDomainOnly || w.seedDomain == "" {
+		return true
+	}
+	return registrableDomain(targetUrl) == w.seedDomain
+}
 
-		title := scraped.Title
-		if title == "" {
-			title = "(no title)"
-		}
-		if len(title) > 50 {
-			title = title[:50] + "..."
-		}
+// registrableDomain returns the eTLD+1 of a URL's host (e.g. "example.co.uk" for
+// "https://blog.example.co.uk/post"), the bare host for IPs and local names, or "" if unparsable
+func registrableDomain(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Hostname() == "" {
+		return ""
+	}
 
-		fmt.Printf("%d. %s [%s] %s - %s", i+1, status, scraped.Type, scraped.URL, title)
-		if scraped.Relevance > 0 {
-			fmt.Printf(" (relevance: %d)", scraped.Relevance)
-		}
-		if scraped.ContentType != "" {
-			fmt.Printf(" [%s]", scraped.ContentType)
-		}
-		if !scraped.Success && scraped.Error != "" {
-			fmt.Printf(" - Error: %s", scraped.Error)
-		}
-		fmt.Printf("\n")
+	host := canonicalHost(parsedURL.Hostname())
+	if net.ParseIP(host) != nil {
+		return host
 	}
-	fmt.Printf("========================\n\n")
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
 }
 
-func (w *WebScraper) ScrapeWebsite(targetUrl string) (*WebsiteContent, error) {
-	return w.scrapeWebsiteWithDepth(targetUrl, 0)
+// canonicalHost lowercases a host (with optional port) and converts internationalized domain names
+// to punycode, so "bücher.de" and "xn--bcher-kva.de" share cache entries and compare equal
+func canonicalHost(host string) string {
+	name, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		name, port = h, p
+	}
+
+	name = strings.ToLower(name)
+	if ascii, err := idna.Lookup.ToASCII(name); err == nil {
+		name = ascii
+	}
+
+	if port != "" {
+		return net.JoinHostPort(name, port)
+	}
+	return name
 }
 
-func (w *WebScraper) scrapeWebsiteWithDepth(targetUrl string, depth int) (*WebsiteContent, error) {
-	// Check if the URL is allowed to be scraped
-	if !w.isUrlAllowed(targetUrl) {
-		err := fmt.Errorf("URL not allowed for scraping: %s", targetUrl)
-		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
-		return nil, err
+// urlHost returns the host of a URL without the "www." prefix, or "" if it can't be parsed
+func urlHost(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
+	return strings.TrimPrefix(strings.ToLower(parsedURL.Host), "www.")
+}
 
-	// Try to load from disk first if refresh is not enabled
-	if !w.refreshContent {
-		if diskContent, err := w.loadContentFromDisk(targetUrl); err == nil {
-			// Check if disk content is not too old (24 hours)
-			if time.Since(diskContent.LastUpdated) < 24*time.Hour {
-				w.recordScrapedUrl(targetUrl, "main", diskContent.Title, true, nil, 0, "disk_cached")
-				w.cache[targetUrl] = *diskContent
-				return diskContent, nil
-			}
-		}
+// disambiguateTitle appends the host to titles that are already used by entries from other hosts
+// (e.g. many sites titled "Home"), updating the earlier entries as well
+func (w *WebScraper) disambiguateTitle(scrapedUrl *ScrapedUrl) {
+	if scrapedUrl.Title == "" {
+		return
 	}
 
-	// Check memory cache
-	if cached, exists := w.cache[targetUrl]; exists {
-		if time.Since(cached.LastUpdated) < 1*time.Hour {
-			w.recordScrapedUrl(targetUrl, "main", cached.Title, true, nil, 0, "memory_cached")
-			return &cached, nil
+	host := urlHost(scrapedUrl.URL)
+	collision := false
+	for i := range w.scrapedUrls {
+		existing := &w.scrapedUrls[i]
+		existingHost := urlHost(existing.URL)
+		if existingHost == host {
+			continue
 		}
+		if existing.Title == scrapedUrl.Title {
+			existing.Title = fmt.Sprintf("%s (%s)", existing.Title, existingHost)
+			collision = true
+		} else if existing.Title == fmt.Sprintf("%s (%s)", scrapedUrl.Title, existingHost) {
+			collision = true
+		}
+	}
+
+	if collision {
+		scrapedUrl.Title = fmt.Sprintf("%s (%s)", scrapedUrl.Title, host)
+	}
+}
+
+// GetScrapedUrls returns a copy of the retained scraping log
+func (w *WebScraper) GetScrapedUrls() []ScrapedUrl {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]ScrapedUrl(nil), w.scrapedUrls...)
+}
+
+// GetScrapeLogStats returns the aggregate counters of the scraping log
+func (w *WebScraper) GetScrapeLogStats() ScrapeLogStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.scrapeLogStats
+	stats.Retained = len(w.scrapedUrls)
+	stats.ByType = make(map[string]int, len(w.scrapeLogStats.ByType))
+	for urlType, count := range w.scrapeLogStats.ByType {
+		stats.ByType[urlType] = count
+	}
+	return stats
+}
+
+// parseProxyURL validates a proxy URL such as http://proxy.corp:3128, returning nil for an empty value
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	if strings.TrimSpace(rawURL) == "" {
+		return nil, nil
 	}
 
-	resp, err := w.client.Get(targetUrl)
+	proxyURL, err := url.Parse(strings.TrimSpace(rawURL))
 	if err != nil {
-		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
-		return nil, fmt.Errorf("failed to fetch URL %s: %v", targetUrl, err)
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", rawURL, err)
 	}
-	defer resp.Body.Close()
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", rawURL)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", rawURL)
+	}
+	return proxyURL, nil
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+// resetCookieJar gives the scraper and its document downloaders a fresh, empty cookie jar
+func (w *WebScraper) resetCookieJar() {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
-		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
-		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+		w.warn("cookie_jar_failed", "Could not create cookie jar", "error", err)
+		return
 	}
+	w.cookieJar = jar
+	w.client.Jar = jar
+	w.pdfExtractor.client.Jar = jar
+	w.fileParser.client.Jar = jar
+}
 
-	content := WebsiteContent{
-		LastUpdated:   time.Now(),
-		PDFContent:    make(map[string]*PDFContent),
-		FileContent:   make(map[string]*FileContent),
-		LinkedContent: make(map[string]*LinkedPageContent),
-		Metadata:      make(map[string]string),
+func (w *WebScraper) ClearScrapedUrls() {
+	if w.cookieJar != nil && w.clearCookies {
+		w.resetCookieJar()
 	}
 
-	content.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.scrapedUrls = make([]ScrapedUrl, 0)
+	w.scrapeLogStats = ScrapeLogStats{ByType: make(map[string]int)}
+	w.warnings = nil
+	// Also reset visited URLs and page count for new session
+	w.visitedUrls = make(map[string]bool)
+	w.scrapedPagesCount = 0
+}
 
-	// Extract meta information
+func (w *WebScraper) PrintScrapedUrls() {
+	fmt.Printf("\n=== SCRAPING SUMMARY ===\n")
+	stats := w.GetScrapeLogStats()
+	entries := w.GetScrapedUrls()
+	fmt.Printf("Total URLs processed: %d (retained in log: %d)\n", stats.Total, stats.Retained)
+	fmt.Printf("Successful: %d, Failed: %d\n", stats.Success, stats.Failed)
+	if w.maxTotalRetries >= 0 {
+		fmt.Printf("Retries used: %d of %d\n", stats.RetriesUsed, w.maxTotalRetries)
+	} else {
+		fmt.Printf("Retries used: %d\n", stats.RetriesUsed)
+	}
+	fmt.Printf("By type: ")
+	for urlType, count := range stats.ByType {
+		fmt.Printf("%s: %d, ", urlType, count)
+	}
+	fmt.Printf("\n\n")
+
+	// Entries keep their position in the whole session even after rotation
+	offset := stats.Total - stats.Retained
+
+	// Print detailed list
+	fmt.Printf("Detailed scraping log:\n")
+	if !w.groupLogByHost {
+		for i, scraped := range entries {
+			printScrapedUrl(offset+i+1, scraped)
+		}
+	} else {
+		// Group entries by host, keeping hosts in the order they were first scraped
+		var hosts []string
+		byHost := make(map[string][]int)
+		for i, scraped := range entries {
+			host := urlHost(scraped.URL)
+			if _, exists := byHost[host]; !exists {
+				hosts = append(hosts, host)
+			}
+			byHost[host] = append(byHost[host], i)
+		}
+
+		for _, host := range hosts {
+			fmt.Printf("\n[%s]\n", host)
+			for _, i := range byHost[host] {
+				printScrapedUrl(offset+i+1, entries[i])
+			}
+		}
+	}
+	fmt.Printf("========================\n\n")
+}
+
+func printScrapedUrl(index int, scraped ScrapedUrl) {
+	status := "✓"
+	if !scraped.Success {
+		status = "✗"
+	}
+
+	title := scraped.Title
+	if title == "" {
+		title = "(no title)"
+	}
+	if len(title) > 50 {
+		title = title[:50] + "..."
+	}
+
+	fmt.Printf("%d. %s [%s] %s - %s", index, status, scraped.Type, scraped.URL, title)
+	if scraped.Relevance > 0 {
+		fmt.Printf(" (relevance: %d)", scraped.Relevance)
+	}
+	if scraped.ContentType != "" {
+		fmt.Printf(" [%s]", scraped.ContentType)
+	}
+	if scraped.LinkedFrom != "" {
+		fmt.Printf(" <- %s", scraped.LinkedFrom)
+	}
+	if !scraped.Success && scraped.Error != "" {
+		fmt.Printf(" - Error: %s", scraped.Error)
+	}
+	fmt.Printf("\n")
+}
+
+// GetCachedContent returns previously scraped content for a URL from memory or disk without scraping
+func (w *WebScraper) GetCachedContent(targetUrl string) (*WebsiteContent, error) {
+	if cached, exists := w.cache.get(w.normalizeURL(targetUrl)); exists {
+		return &cached, nil
+	}
+	return w.loadContentFromDisk(targetUrl)
+}
+
+func (w *WebScraper) ScrapeWebsite(targetUrl string) (*WebsiteContent, error) {
+	return w.ScrapeWebsiteContext(context.Background(), targetUrl)
+}
+
+// ScrapeWebsiteContext scrapes a website, aborting in-flight requests when ctx is cancelled.
+// A cancelled scrape returns the content gathered so far, uncached, with an error wrapping ctx.Err().
+func (w *WebScraper) ScrapeWebsiteContext(ctx context.Context, targetUrl string) (*WebsiteContent, error) {
+	return w.scrapeWebsiteWithDepth(ctx, targetUrl, 0, false)
+}
+
+// RefreshWebsite scrapes a website bypassing the memory and disk caches, then caches the fresh content
+func (w *WebScraper) RefreshWebsite(targetUrl string) (*WebsiteContent, error) {
+	return w.scrapeWebsiteWithDepth(context.Background(), targetUrl, 0, true)
+}
+
+// ForceRefreshWebsite scrapes a website as if REFRESH_CONTENT were set for this one call: the page,
+// its linked pages and documents are downloaded again without conditional requests, ignoring the
+// memory and disk caches. The fresh content is cached as usual.
+func (w *WebScraper) ForceRefreshWebsite(ctx context.Context, targetUrl string) (*WebsiteContent, error) {
+	refreshContent := w.refreshContent
+	w.refreshContent = true
+	defer func() { w.refreshContent = refreshContent }()
+
+	w.pdfCache.clear()
+	w.fileCache.clear()
+	return w.scrapeWebsiteWithDepth(ctx, targetUrl, 0, true)
+}
+
+// CheckReachable sends a HEAD request to targetUrl. Any response below 500 counts as reachable,
+// since some servers reject HEAD (405) while serving GET fine.
+func (w *WebScraper) CheckReachable(ctx context.Context, targetUrl string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", targetUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WebSiteAssistantBot/1.0)")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *WebScraper) scrapeWebsiteWithDepth(ctx context.Context, targetUrl string, depth int, skipCache bool) (*WebsiteContent, error) {
+	w.seedDomain = registrableDomain(targetUrl)
+
+	// Check if the URL is allowed to be scraped
+	if !w.isUrlAllowed(targetUrl) {
+		err := fmt.Errorf("URL not allowed for scraping: %s", targetUrl)
+		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
+		return nil, err
+	}
+
+	cacheKey := w.normalizeURL(targetUrl)
+
+	// Try to load from disk first if refresh is not enabled
+	if !w.refreshContent && !skipCache {
+		if diskContent, err := w.loadContentFromDisk(targetUrl); err == nil {
+			// Check if disk content is not too old (24 hours)
+			if time.Since(diskContent.LastUpdated) < 24*time.Hour {
+				w.recordScrapedUrl(targetUrl, "main", diskContent.Title, true, nil, 0, "disk_cached")
+				w.cacheHits.Add(1)
+				if w.embedIfStale(ctx, diskContent) {
+					if err := w.saveContentToDisk(targetUrl, diskContent); err != nil {
+						w.warn("disk_save_failed", "Failed to save content to disk", "url", targetUrl, "error", err)
+					}
+				}
+				w.cache.put(cacheKey, *diskContent)
+				return diskContent, nil
+			}
+		}
+	}
+
+	// Check memory cache
+	if cached, exists := w.cache.get(cacheKey); exists && !skipCache {
+		if time.Since(cached.LastUpdated) < 1*time.Hour {
+			w.recordScrapedUrl(targetUrl, "main", cached.Title, true, nil, 0, "memory_cached")
+			w.cacheHits.Add(1)
+			return &cached, nil
+		}
+	}
+
+	w.cacheMisses.Add(1)
+	w.skipLinkedCache = skipCache
+	logStart := w.GetScrapeLogStats().Total
+	fetchStarted := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "GET", targetUrl, nil)
+	if err != nil {
+		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
+		return nil, fmt.Errorf("failed to fetch URL %s: %v", targetUrl, err)
+	}
+
+	// Ask the server whether the previously scraped copy is still current
+	previous := w.previousContent(targetUrl)
+	if previous != nil {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+
+	resp, err := w.fetchPage(w.client, req)
+	if err != nil {
+		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
+		return nil, fmt.Errorf("failed to fetch URL %s: %w", targetUrl, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && previous != nil {
+		content := *previous
+		content.LastUpdated = time.Now()
+		w.recordScrapedUrl(targetUrl, "main", content.Title, true, nil, 0, "not_modified")
+		w.embedIfStale(ctx, &content)
+		if err := w.saveContentToDisk(targetUrl, &content); err != nil {
+			w.warn("disk_save_failed", "Failed to save content to disk", "url", targetUrl, "error", err)
+		}
+		w.cache.put(cacheKey, content)
+		return &content, nil
+	}
+
+	doc, err := w.readHTML(resp, targetUrl)
+	warnIfSlow(w.slowOpThreshold, "page fetch", targetUrl, fetchStarted)
+	if err != nil {
+		w.recordScrapedUrl(targetUrl, "main", "", false, err, 0, "")
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	content := WebsiteContent{
+		LastUpdated:   time.Now(),
+		PDFContent:    make(map[string]*PDFContent),
+		FileContent:   make(map[string]*FileContent),
+		LinkedContent: make(map[string]*LinkedPageContent),
+		Metadata:      make(map[string]string),
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+	}
+
+	content.Title = strings.TrimSpace(doc.Find("title").First().Text())
+
+	// Extract meta information
 	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
 		if name, exists := s.Attr("name"); exists {
 			if cont, exists := s.Attr("content"); exists {
@@ -512,7 +1464,109 @@ func (w *WebScraper) scrapeWebsiteWithDepth(targetUrl string, depth int) (*Websi
 		}
 	})
 
+	// Social metadata stands in for a missing HTML title/description
+	if content.Title == "" {
+		content.Title = firstNonEmpty(content.Metadata["og:title"], content.Metadata["twitter:title"])
+	}
+	if content.Description == "" {
+		content.Description = firstNonEmpty(content.Metadata["og:description"], content.Metadata["twitter:description"])
+	}
+
 	// Extract comprehensive text content
+	textParts := w.extractMainText(doc)
+	content.Links = w.extractLinks(doc)
+	if w.extractOutline {
+		content.Outline = extractOutline(doc)
+	}
+	if w.extractBreadcrumbs {
+		content.Breadcrumbs = extractBreadcrumbs(doc)
+	}
+	if w.extractContacts {
+		content.Contacts = extractContacts(doc, w.phones)
+	}
+	if w.parseJSONLD {
+		content.StructuredData = w.extractStructuredData(doc)
+	}
+	if w.extractLanguage {
+		setLanguageMetadata(doc, &content)
+	}
+	if w.includeReviews {
+		content.Reviews = w.extractReviews(doc)
+		if w.reviewSentiment && len(content.Reviews) > 0 {
+			content.ReviewSummary = summarizeReviewSentiment(content.Reviews)
+		}
+	}
+
+	if w.paginationTemplate != "" {
+		textParts = w.probePagination(ctx, &content, targetUrl, textParts)
+	}
+	content.Text = strings.Join(textParts, "\n\n")
+
+	w.processPDFs(ctx, &content, targetUrl)
+	w.processFiles(ctx, &content, targetUrl)
+	w.processDocumentLinks(ctx, &content)
+	w.processLinkedContentWithDepth(ctx, &content, targetUrl, depth)
+	if w.enableSitemap && depth+1 < w.maxScrapingDepth {
+		w.scrapeSitemapPages(ctx, &content, targetUrl)
+	}
+	w.followDocumentPageLinks(ctx, &content)
+	if w.enrichLinksLight {
+		w.enrichOutboundLinks(ctx, &content)
+	}
+	content.UnavailableSources = w.unavailableSourcesSince(logStart)
+
+	// Hand back what was gathered before cancellation, but don't cache an incomplete crawl
+	if err := ctx.Err(); err != nil {
+		w.recordScrapedUrl(targetUrl, "main", content.Title, false, err, 0, "cancelled")
+		return &content, fmt.Errorf("scraping %s was cancelled: %w", targetUrl, err)
+	}
+
+	// Record successful main page scraping
+	w.recordScrapedUrl(targetUrl, "main", content.Title, true, nil, 0, "website")
+	w.pagesScraped.Add(1)
+
+	content.ContentHash = computeContentHash(&content)
+	w.embedIfStale(ctx, &content)
+
+	// Save content to disk
+	if err := w.saveContentToDisk(targetUrl, &content); err != nil {
+		w.warn("disk_save_failed", "Failed to save content to disk", "url", targetUrl, "error", err)
+	}
+
+	w.cache.put(cacheKey, content)
+	return &content, nil
+}
+
+// previousContent returns the last scraped copy of a page with caching validators, from memory or
+// disk regardless of age, or nil. REFRESH_CONTENT disables conditional requests.
+func (w *WebScraper) previousContent(targetUrl string) *WebsiteContent {
+	if w.refreshContent {
+		return nil
+	}
+
+	if cached, exists := w.cache.get(w.normalizeURL(targetUrl)); exists && (cached.ETag != "" || cached.LastModified != "") {
+		return &cached
+	}
+	if diskContent, err := w.loadContentFromDisk(targetUrl); err == nil && (diskContent.ETag != "" || diskContent.LastModified != "") {
+		return diskContent
+	}
+	return nil
+}
+
+// normalizeLinkTitle collapses whitespace in anchor text and truncates it to maxLinkTitleLength,
+// since some sites wrap whole card components in a single link
+func (w *WebScraper) normalizeLinkTitle(text string) string {
+	title := allWhitespace.ReplaceAllString(strings.TrimSpace(text), " ")
+
+	runes := []rune(title)
+	if len(runes) > w.maxLinkTitleLength {
+		title = strings.TrimSpace(string(runes[:w.maxLinkTitleLength])) + "..."
+	}
+	return title
+}
+
+// extractMainText collects the text blocks of a page that are long enough to be meaningful
+func (w *WebScraper) extractMainText(doc *goquery.Document) []string {
 	var textParts []string
 	doc.Find("p, h1, h2, h3, h4, h5, h6, article, section, div.content, div.main").Each(func(i int, s *goquery.Selection) {
 		text := strings.TrimSpace(s.Text())
@@ -520,8 +1574,54 @@ func (w *WebScraper) scrapeWebsiteWithDepth(targetUrl string, depth int) (*Websi
 			textParts = append(textParts, text)
 		}
 	})
-	content.Text = strings.Join(textParts, "\n\n")
+	return textParts
+}
+
+// extractOutline returns the h1-h3 headings of a page in document order
+func extractOutline(doc *goquery.Document) []OutlineEntry {
+	var outline []OutlineEntry
+	doc.Find("h1, h2, h3").Each(func(i int, s *goquery.Selection) {
+		text := allWhitespace.ReplaceAllString(strings.TrimSpace(s.Text()), " ")
+		if text == "" {
+			return
+		}
+		level, _ := strconv.Atoi(strings.TrimPrefix(goquery.NodeName(s), "h"))
+		outline = append(outline, OutlineEntry{Level: level, Text: text})
+	})
+	return outline
+}
+
+// publishedDateSelectors locate publish/modified dates in page markup, read from the content or datetime attribute
+const publishedDateSelectors = "meta[property='article:published_time'], meta[property='article:modified_time'], " +
+	"meta[property='og:updated_time'], meta[name='date'], meta[itemprop='datePublished'], meta[itemprop='dateModified'], " +
+	"[itemprop='datePublished'][datetime], [itemprop='dateModified'][datetime], time[datetime]"
+
+var publishedDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// extractPublishedDate returns the most recent publish/modified date declared in a page, or zero if none parses
+func extractPublishedDate(doc *goquery.Document) time.Time {
+	var latest time.Time
+	doc.Find(publishedDateSelectors).Each(func(i int, s *goquery.Selection) {
+		value, exists := s.Attr("content")
+		if !exists {
+			value, _ = s.Attr("datetime")
+		}
+		value = strings.TrimSpace(value)
+		for _, layout := range publishedDateLayouts {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				// Ignore dates in the future, typically event dates rather than publish dates
+				if parsed.After(latest) && parsed.Before(time.Now().Add(24*time.Hour)) {
+					latest = parsed
+				}
+				break
+			}
+		}
+	})
+	return latest
+}
 
+func (w *WebScraper) extractLinks(doc *goquery.Document) []Link {
+	var links []Link
 	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
 		if href, exists := s.Attr("href"); exists {
 			linkType := "internal"
@@ -529,78 +1629,602 @@ func (w *WebScraper) scrapeWebsiteWithDepth(targetUrl string, depth int) (*Websi
 				linkType = "external"
 			}
 
-			content.Links = append(content.Links, Link{
+			links = append(links, Link{
 				URL:   href,
-				Title: strings.TrimSpace(s.Text()),
+				Title: w.normalizeLinkTitle(s.Text()),
 				Type:  linkType,
 			})
 		}
 	})
+	return links
+}
 
-	w.processPDFs(&content, targetUrl)
-	w.processFiles(&content, targetUrl)
-	w.processLinkedContentWithDepth(&content, targetUrl, depth)
+// probePagination fetches ?page={n} style pages of the main URL that link-following can't discover,
+// stopping at the first page without new content, after maxPaginationPages or when the page budget runs out
+func (w *WebScraper) probePagination(ctx context.Context, content *WebsiteContent, targetUrl string, textParts []string) []string {
+	seenText := make(map[string]bool)
+	for _, part := range textParts {
+		seenText[part] = true
+	}
+	seenLinks := make(map[string]bool)
+	for _, link := range content.Links {
+		seenLinks[link.URL] = true
+	}
 
-	// Record successful main page scraping
-	w.recordScrapedUrl(targetUrl, "main", content.Title, true, nil, 0, "website")
+	for pageNumber := 2; pageNumber <= w.maxPaginationPages && w.canScrapeMore() && ctx.Err() == nil; pageNumber++ {
+		pageURL := w.resolveURL(targetUrl, strings.ReplaceAll(w.paginationTemplate, "{n}", strconv.Itoa(pageNumber)))
+		if !w.isUrlAllowed(pageURL) || !w.claimURL(pageURL, true) {
+			break
+		}
 
-	// Save content to disk
-	if err := w.saveContentToDisk(targetUrl, &content); err != nil {
-		fmt.Printf("Warning: Failed to save content to disk: %v\n", err)
+		doc, err := w.parseHTMLFromURL(ctx, pageURL)
+		if err != nil {
+			w.recordScrapedUrl(pageURL, "pagination", "", false, err, 0, "")
+			break
+		}
+
+		newParts := 0
+		for _, part := range w.extractMainText(doc) {
+			if !seenText[part] {
+				seenText[part] = true
+				textParts = append(textParts, part)
+				newParts++
+			}
+		}
+		for _, link := range w.extractLinks(doc) {
+			if !seenLinks[link.URL] {
+				seenLinks[link.URL] = true
+				content.Links = append(content.Links, link)
+			}
+		}
+
+		w.recordScrapedUrl(pageURL, "pagination", strings.TrimSpace(doc.Find("title").First().Text()), true, nil, 0, "website")
+		w.pagesScraped.Add(1)
+		if newParts == 0 {
+			break
+		}
 	}
 
-	w.cache[targetUrl] = content
-	return &content, nil
+	return textParts
 }
 
-func (w *WebScraper) processPDFs(content *WebsiteContent, baseURL string) {
-	for _, link := range content.Links {
-		if w.isPDFLink(link.URL) {
-			fullURL := w.resolveURL(baseURL, link.URL)
+func (w *WebScraper) processPDFs(ctx context.Context, content *WebsiteContent, baseURL string) {
+	links := w.documentLinks(content.Links, baseURL, w.isPDFLink)
+	stopPrefetch := w.startDocumentPrefetch(ctx, w.pdfExtractor.client, prefetchableURLs(baseURL, links))
+	defer stopPrefetch()
 
-			if cached, exists := w.pdfCache[fullURL]; exists {
-				if time.Since(cached.LastUpdated) < 24*time.Hour {
-					content.PDFContent[link.URL] = cached
-					continue
+	for _, link := range links {
+		if ctx.Err() != nil {
+			break
+		}
+		if docURL, pdfContent := loadSecureDocument(ctx, w, baseURL, link.URL, link.Title, w.loadPDF); pdfContent != nil {
+			content.PDFContent[docURL] = pdfContent
+		}
+	}
+
+	w.mergePDFParts(content)
+}
+
+// documentLinks returns the page links accepted by isDocument, resolved to absolute URLs within the crawl scope
+func (w *WebScraper) documentLinks(links []Link, baseURL string, isDocument func(string) bool) []Link {
+	var documents []Link
+	for _, link := range links {
+		if !isDocument(link.URL) {
+			continue
+		}
+		fullURL := w.resolveDocumentURL(baseURL, link.URL)
+		if w.isInScope(fullURL) {
+			documents = append(documents, Link{URL: fullURL, Title: link.Title, Type: link.Type})
+		}
+	}
+	return documents
+}
+
+// prefetchableURLs lists the document URLs that are loaded as they are; mixed-content
+// documents may be upgraded or blocked, so they are left to the sequential load
+func prefetchableURLs(pageURL string, links []Link) []string {
+	var urls []string
+	for _, link := range links {
+		if !isMixedContent(pageURL, link.URL) {
+			urls = append(urls, link.URL)
+		}
+	}
+	return urls
+}
+
+// loadSecureDocument loads a document linked from pageURL, applying the mixed-content policy to http
+// documents on https pages: the https URL is tried first, and the http URL is skipped when blocking is on.
+// It returns the URL the document was loaded from.
+func loadSecureDocument[T any](ctx context.Context, w *WebScraper, pageURL, docURL, title string, load func(ctx context.Context, docURL, title, parent string) *T) (string, *T) {
+	if !isMixedContent(pageURL, docURL) {
+		return docURL, load(ctx, docURL, title, "")
+	}
+
+	if w.upgradeMixedContent {
+		secureURL := "https://" + docURL[len("http://"):]
+		if content := load(ctx, secureURL, title, ""); content != nil {
+			return secureURL, content
+		}
+	}
+
+	if w.blockMixedContent {
+		err := fmt.Errorf("insecure document linked from an https page")
+		w.recordScrapedUrl(docURL, "mixed_content", title, false, err, 0, "")
+		return docURL, nil
+	}
+	return docURL, load(ctx, docURL, title, "")
+}
+
+// isMixedContent reports whether an http resource is referenced from an https page
+func isMixedContent(pageURL, resourceURL string) bool {
+	return strings.HasPrefix(strings.ToLower(pageURL), "https://") && strings.HasPrefix(strings.ToLower(resourceURL), "http://")
+}
+
+// loadPDF returns the PDF at fullURL from the cache or by extracting it, or nil if extraction failed.
+// parent is the document that linked it for the scrape log, "" for a PDF linked from a page.
+func (w *WebScraper) loadPDF(ctx context.Context, fullURL, title, parent string) *PDFContent {
+	if cached, exists := w.pdfCache.get(fullURL); exists {
+		if time.Since(cached.LastUpdated) < 24*time.Hour {
+			w.cacheHits.Add(1)
+			return cached
+		}
+	}
+	w.cacheMisses.Add(1)
+
+	if w.isDocumentFailureCached(fullURL, "pdf", title, parent) {
+		return nil
+	}
+
+	var pdfContent *PDFContent
+	started := time.Now()
+	err := w.retryDocumentDownload(ctx, func() error {
+		var extractErr error
+		if prefetched := w.takePrefetched(fullURL); prefetched != nil {
+			if prefetched.err != nil {
+				return prefetched.err
+			}
+			pdfContent, extractErr = w.pdfExtractor.extractFromReader(bytes.NewReader(prefetched.data))
+			return extractErr
+		}
+
+		if err := w.hostLimiter.wait(ctx, urlHost(fullURL)); err != nil {
+			return err
+		}
+		pdfContent, extractErr = w.pdfExtractor.ExtractFromURL(ctx, fullURL)
+		return extractErr
+	})
+	warnIfSlow(w.slowOpThreshold, "PDF extraction", fullURL, started)
+	if err != nil {
+		w.rememberDocumentFailure(fullURL, err)
+		w.recordScrapedUrlFrom(parent, fullURL, "pdf", title, false, err, 0, "pdf")
+		return nil
+	}
+	delete(w.documentFailures, fullURL)
+
+	w.recordScrapedUrlFrom(parent, fullURL, "pdf", pdfContent.Title, true, nil, 0, "pdf")
+	w.pdfCache.put(fullURL, pdfContent)
+	return pdfContent
+}
+
+// pdfPart is one part of a multi-part PDF document
+type pdfPart struct {
+	url    string
+	number int
+}
+
+// mergePDFParts combines sequentially named PDF parts (report-part1.pdf, report-part2.pdf)
+// into a single document keyed by the base name, with part markers between them
+func (w *WebScraper) mergePDFParts(content *WebsiteContent) {
+	if w.pdfPartPattern == nil || len(content.PDFContent) < 2 {
+		return
+	}
+
+	groups := make(map[string][]pdfPart)
+	for fullURL := range content.PDFContent {
+		parsedURL, err := url.Parse(fullURL)
+		if err != nil {
+			continue
+		}
+
+		matches := w.pdfPartPattern.FindStringSubmatch(path.Base(parsedURL.Path))
+		if matches == nil {
+			continue
+		}
+		number, err := strconv.Atoi(matches[2])
+		if err != nil {
+			continue
+		}
+
+		// Merged documents are keyed by the base name in the same directory
+		parsedURL.Path = path.Join(path.Dir(parsedURL.Path), matches[1]+".pdf")
+		parsedURL.RawQuery = ""
+		parsedURL.Fragment = ""
+		mergedURL := parsedURL.String()
+		groups[mergedURL] = append(groups[mergedURL], pdfPart{url: fullURL, number: number})
+	}
+
+	for mergedURL, parts := range groups {
+		if len(parts) < 2 {
+			continue
+		}
+		sort.Slice(parts, func(i, j int) bool { return parts[i].number < parts[j].number })
+
+		merged := &PDFContent{LastUpdated: time.Now()}
+		var textBuilder strings.Builder
+		for _, part := range parts {
+			partContent := content.PDFContent[part.url]
+			textBuilder.WriteString(fmt.Sprintf("=== PART %d: %s ===\n", part.number, part.url))
+			textBuilder.WriteString(partContent.Text)
+			textBuilder.WriteString("\n\n")
+			merged.Pages += partContent.Pages
+			if merged.Title == "" {
+				merged.Title = partContent.Title
+			}
+			for _, phone := range partContent.Phones {
+				merged.Phones = appendUnique(merged.Phones, phone)
+			}
+			delete(content.PDFContent, part.url)
+		}
+		merged.Text = strings.TrimSpace(textBuilder.String())
+
+		content.PDFContent[mergedURL] = merged
+		title := fmt.Sprintf("%s (%d parts)", path.Base(mergedURL), len(parts))
+		w.recordScrapedUrl(mergedURL, "pdf_merge", title, true, nil, 0, "pdf")
+	}
+}
+
+func (w *WebScraper) processFiles(ctx context.Context, content *WebsiteContent, baseURL string) {
+	links := w.documentLinks(content.Links, baseURL, w.isFileLink)
+	stopPrefetch := w.startDocumentPrefetch(ctx, w.fileParser.client, prefetchableURLs(baseURL, links))
+	defer stopPrefetch()
+
+	for _, link := range links {
+		if ctx.Err() != nil {
+			break
+		}
+		if docURL, fileContent := loadSecureDocument(ctx, w, baseURL, link.URL, link.Title, w.loadFile); fileContent != nil {
+			content.FileContent[docURL] = fileContent
+		}
+	}
+}
+
+// loadFile returns the file at fullURL from the cache or by parsing it, or nil if parsing failed;
+// parent is as for loadPDF
+func (w *WebScraper) loadFile(ctx context.Context, fullURL, title, parent string) *FileContent {
+	if cached, exists := w.fileCache.get(fullURL); exists {
+		if time.Since(cached.LastUpdated) < 24*time.Hour {
+			w.cacheHits.Add(1)
+			return cached
+		}
+	}
+	w.cacheMisses.Add(1)
+
+	if w.isDocumentFailureCached(fullURL, "file", title, parent) {
+		return nil
+	}
+
+	var fileContent *FileContent
+	started := time.Now()
+	err := w.retryDocumentDownload(ctx, func() error {
+		var parseErr error
+		if prefetched := w.takePrefetched(fullURL); prefetched != nil {
+			if prefetched.err != nil {
+				return prefetched.err
+			}
+			fileContent, parseErr = w.fileParser.ParseFromReader(fullURL, prefetched.contentDisposition, bytes.NewReader(prefetched.data))
+			return parseErr
+		}
+
+		if err := w.hostLimiter.wait(ctx, urlHost(fullURL)); err != nil {
+			return err
+		}
+		fileContent, parseErr = w.fileParser.ParseFromURL(ctx, fullURL)
+		return parseErr
+	})
+	warnIfSlow(w.slowOpThreshold, "file parse", fullURL, started)
+	if err != nil {
+		w.rememberDocumentFailure(fullURL, err)
+		w.recordScrapedUrlFrom(parent, fullURL, "file", title, false, err, 0, "file")
+		return nil
+	}
+	delete(w.documentFailures, fullURL)
+
+	w.recordScrapedUrlFrom(parent, fullURL, "file", fileContent.FileName, true, nil, 0, fileContent.FileType)
+	w.fileCache.put(fullURL, fileContent)
+	return fileContent
+}
+
+// documentRef is a link to a PDF/file found inside another document
+type documentRef struct {
+	url    string
+	parent string
+	depth  int
+}
+
+// processDocumentLinks follows links to other PDFs/files found in the text of extracted documents
+// (e.g. a cover PDF linking to project PDFs), breadth-first up to maxDocumentDepth
+func (w *WebScraper) processDocumentLinks(ctx context.Context, content *WebsiteContent) {
+	if w.maxDocumentDepth <= 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var queue []documentRef
+	enqueue := func(parent, text string, depth int) {
+		for _, docURL := range w.findDocumentLinks(text) {
+			if seen[docURL] || content.PDFContent[docURL] != nil || content.FileContent[docURL] != nil {
+				continue
+			}
+			seen[docURL] = true
+			queue = append(queue, documentRef{url: docURL, parent: parent, depth: depth})
+		}
+	}
+
+	for _, pdfURL := range sortedKeys(content.PDFContent) {
+		enqueue(pdfURL, content.PDFContent[pdfURL].Text, 1)
+	}
+	for _, fileURL := range sortedKeys(content.FileContent) {
+		enqueue(fileURL, content.FileContent[fileURL].Text, 1)
+	}
+
+	for len(queue) > 0 && w.canScrapeMore() && ctx.Err() == nil {
+		ref := queue[0]
+		queue = queue[1:]
+
+		if !w.isUrlAllowed(ref.url) || !w.claimURL(ref.url, true) {
+			continue
+		}
+
+		// Log entries of nested documents point to the document that linked them
+		if w.isPDFLink(ref.url) {
+			if pdfContent := w.loadPDF(ctx, ref.url, "", ref.parent); pdfContent != nil {
+				content.PDFContent[ref.url] = pdfContent
+				if ref.depth < w.maxDocumentDepth {
+					enqueue(ref.url, pdfContent.Text, ref.depth+1)
 				}
 			}
+		} else if fileContent := w.loadFile(ctx, ref.url, "", ref.parent); fileContent != nil {
+			content.FileContent[ref.url] = fileContent
+			if ref.depth < w.maxDocumentDepth {
+				enqueue(ref.url, fileContent.Text, ref.depth+1)
+			}
+		}
+	}
+}
+
+// documentURLPattern matches absolute URLs in extracted document text
+var documentURLPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// findDocumentLinks returns the absolute PDF/file URLs mentioned in a document's text
+func (w *WebScraper) findDocumentLinks(text string) []string {
+	var links []string
+	for _, match := range documentURLPattern.FindAllString(text, -1) {
+		docURL := w.resolveDocumentURL("", strings.TrimRight(match, ".,;:!?"))
+		if w.isPDFLink(docURL) || w.isFileLink(docURL) {
+			links = append(links, docURL)
+		}
+	}
+	return links
+}
+
+// findDocumentPageLinks returns the absolute web page (non-document) URLs mentioned in a document's text
+func (w *WebScraper) findDocumentPageLinks(text string) []string {
+	var links []string
+	for _, match := range documentURLPattern.FindAllString(text, -1) {
+		pageURL := strings.TrimRight(match, ".,;:!?")
+		if !w.isPDFLink(pageURL) && !w.isFileLink(pageURL) {
+			links = append(links, pageURL)
+		}
+	}
+	return links
+}
+
+// followDocumentPageLinks scrapes web pages linked from extracted documents (e.g. a CV linking to a
+// live portfolio) as "doc_link" entries, within the URL allow-list, depth and page limits
+func (w *WebScraper) followDocumentPageLinks(ctx context.Context, content *WebsiteContent) {
+	if !w.followDocumentLinks {
+		return
+	}
+
+	follow := func(parent, text string) {
+		for _, pageURL := range w.findDocumentPageLinks(text) {
+			if !w.canScrapeMore() || ctx.Err() != nil {
+				return
+			}
+			if content.LinkedContent[pageURL] != nil || w.isURLVisited(pageURL) || !w.isUrlAllowed(pageURL) {
+				continue
+			}
+
+			// Log entries of document links point to the document that mentioned them
+			linkedContent, err := w.scrapeLinkedPageWithDepthAndContent(ctx, pageURL, 1, "doc_link", parent, content)
+			if err != nil {
+				log.Printf("Failed to scrape document link %s: %v", pageURL, err)
+				continue
+			}
+			linkedContent.Relevance = w.calculateRelevance(pageURL, linkedContent.Title)
+			content.LinkedContent[pageURL] = linkedContent
+		}
+	}
+
+	for _, pdfURL := range sortedKeys(content.PDFContent) {
+		follow(pdfURL, content.PDFContent[pdfURL].Text)
+	}
+	for _, fileURL := range sortedKeys(content.FileContent) {
+		follow(fileURL, content.FileContent[fileURL].Text)
+	}
+}
+
+// isDocumentFailureCached reports (and logs as "failure_cached") a document that failed recently
+func (w *WebScraper) isDocumentFailureCached(fullURL, urlType, title, parent string) bool {
+	failure, exists := w.documentFailures[fullURL]
+	if !exists || time.Since(failure.failedAt) >= w.failureCacheTTL {
+		return false
+	}
+
+	err := fmt.Errorf("skipped, failed %s ago: %s", time.Since(failure.failedAt).Round(time.Second), failure.err)
+	w.recordScrapedUrlFrom(parent, fullURL, urlType, title, false, err, 0, "failure_cached")
+	return true
+}
+
+func (w *WebScraper) rememberDocumentFailure(fullURL string, err error) {
+	// A download aborted by a cancelled scrape says nothing about the document
+	if w.failureCacheTTL <= 0 || errors.Is(err, context.Canceled) {
+		return
+	}
+	w.documentFailures[fullURL] = documentFailure{err: err.Error(), failedAt: time.Now()}
+}
+
+// cachedPageFailure returns an error for a linked page that failed permanently within
+// NEGATIVE_CACHE_TTL, or nil if it should be fetched
+func (w *WebScraper) cachedPageFailure(pageURL string) error {
+	if w.negativeCacheTTL <= 0 {
+		return nil
+	}
+
+	key := w.normalizeURL(pageURL)
+	w.mu.Lock()
+	failure, exists := w.pageFailures[key]
+	w.mu.Unlock()
+	if !exists || time.Since(failure.failedAt) >= w.negativeCacheTTL {
+		return nil
+	}
+	return fmt.Errorf("skipped, %s %s ago: %s", failure.kind, time.Since(failure.failedAt).Round(time.Second), failure.err)
+}
+
+// rememberPageFailure records a linked page failure in the negative cache
+func (w *WebScraper) rememberPageFailure(pageURL, kind string, err error) {
+	if w.negativeCacheTTL <= 0 {
+		return
+	}
+
+	key := w.normalizeURL(pageURL)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pageFailures[key] = pageFailure{kind: kind, err: err.Error(), failedAt: time.Now()}
+}
+
+// retryDocumentDownload runs a download, retrying transient failures with exponential backoff
+// until ctx is cancelled
+func (w *WebScraper) retryDocumentDownload(ctx context.Context, download func() error) error {
+	backoff := 500 * time.Millisecond
+	err := download()
+	for attempt := 0; attempt < w.documentRetries && err != nil && isTransientDownloadError(err); attempt++ {
+		if ctx.Err() != nil || !w.takeRetry() {
+			break
+		}
+		if !sleepContext(ctx, backoff) {
+			break
+		}
+		backoff *= 2
+		err = download()
+	}
+	return err
+}
+
+// maxRetryAfter caps how long a Retry-After header can make a page fetch wait
+const maxRetryAfter = 30 * time.Second
+
+// fetchPage sends a GET request once the host's rate limit allows it, retrying 408/429/5xx responses
+// and timeouts up to pageRetries times with exponential backoff (500ms, 1s, 2s, ...). A Retry-After
+// header on 429/503 responses replaces the backoff. Other failures, such as a 404, and cancellation
+// of the request context are returned right away.
+func (w *WebScraper) fetchPage(client *http.Client, req *http.Request) (*http.Response, error) {
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		if err := w.hostLimiter.wait(req.Context(), urlHost(req.URL.String())); err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		var netErr net.Error
+		retryable := (err != nil && errors.As(err, &netErr) && netErr.Timeout()) ||
+			(err == nil && isTransientStatus(resp.StatusCode))
+		if !retryable || req.Context().Err() != nil || attempt > w.pageRetries || !w.takeRetry() {
+			return resp, err
+		}
+
+		wait := backoff
+		reason := "timeout"
+		if err == nil {
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+					wait = retryAfter
+				}
+			}
+			resp.Body.Close()
+		}
+		log.Printf("Retrying %s after %s in %s (retry %d of %d)", req.URL, reason, wait, attempt, w.pageRetries)
+		if !sleepContext(req.Context(), wait) {
+			return nil, req.Context().Err()
+		}
+		backoff *= 2
+	}
+}
+
+// sleepContext waits for d, returning false early if ctx is cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-			pdfContent, err := w.pdfExtractor.ExtractFromURL(fullURL)
-			if err != nil {
-				w.recordScrapedUrl(fullURL, "pdf", link.Title, false, err, 0, "pdf")
-				continue
-			}
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date, capped at maxRetryAfter
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
 
-			w.recordScrapedUrl(fullURL, "pdf", pdfContent.Title, true, nil, 0, "pdf")
-			w.pdfCache[fullURL] = pdfContent
-			content.PDFContent[link.URL] = pdfContent
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = time.Until(date)
+		if wait < 0 {
+			wait = 0
 		}
+	} else {
+		return 0, false
 	}
-}
 
-func (w *WebScraper) processFiles(content *WebsiteContent, baseURL string) {
-	for _, link := range content.Links {
-		if w.isFileLink(link.URL) {
-			fullURL := w.resolveURL(baseURL, link.URL)
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
 
-			if cached, exists := w.fileCache[fullURL]; exists {
-				if time.Since(cached.LastUpdated) < 24*time.Hour {
-					content.FileContent[link.URL] = cached
-					continue
-				}
-			}
+// takeRetry consumes one retry from the session-wide budget, reporting false once it is exhausted
+// so a broadly failing site can't multiply the number of requests
+func (w *WebScraper) takeRetry() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.maxTotalRetries >= 0 && w.scrapeLogStats.RetriesUsed >= w.maxTotalRetries {
+		return false
+	}
+	w.scrapeLogStats.RetriesUsed++
+	return true
+}
 
-			fileContent, err := w.fileParser.ParseFromURL(fullURL)
-			if err != nil {
-				w.recordScrapedUrl(fullURL, "file", link.Title, false, err, 0, "file")
-				continue
-			}
+// isTransientStatus reports whether an HTTP status may succeed on a later attempt
+func isTransientStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
 
-			w.recordScrapedUrl(fullURL, "file", fileContent.FileName, true, nil, 0, fileContent.FileType)
-			w.fileCache[fullURL] = fileContent
-			content.FileContent[link.URL] = fileContent
-		}
+// isTransientDownloadError reports whether a download failure is worth retrying:
+// network errors, timeouts, rate limiting and server-side errors
+func isTransientDownloadError(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return isTransientStatus(statusErr.StatusCode)
 	}
+
+	// Transport failures from http.Client surface as *url.Error, which implements net.Error
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
 func (w *WebScraper) isPDFLink(url string) bool {
@@ -611,6 +2235,16 @@ func (w *WebScraper) isFileLink(url string) bool {
 	return w.fileParser.isValidFileURL(url)
 }
 
+// resolveDocumentURL returns the absolute URL of a PDF/file link without its fragment, so the same
+// document referenced through different relative paths shares one cache and content map key
+func (w *WebScraper) resolveDocumentURL(baseURL, linkURL string) string {
+	fullURL := w.resolveURL(baseURL, linkURL)
+	if before, _, found := strings.Cut(fullURL, "#"); found {
+		return before
+	}
+	return fullURL
+}
+
 func (w *WebScraper) resolveURL(baseURL, linkURL string) string {
 	// If linkURL is already absolute, return as-is
 	if strings.HasPrefix(linkURL, "http") {
@@ -651,7 +2285,13 @@ func (w *WebScraper) resolveURL(baseURL, linkURL string) string {
 //	w.processLinkedContentWithDepth(content, baseURL, 0)
 //}
 
-func (w *WebScraper) processLinkedContentWithDepth(content *WebsiteContent, baseURL string, depth int) {
+// linkCandidate is a link considered for recursive scraping
+type linkCandidate struct {
+	url       string
+	relevance int
+}
+
+func (w *WebScraper) processLinkedContentWithDepth(ctx context.Context, content *WebsiteContent, baseURL string, depth int) {
 	// Check if we can continue scraping
 	if depth >= w.maxScrapingDepth || !w.canScrapeMore() {
 		return
@@ -660,8 +2300,39 @@ func (w *WebScraper) processLinkedContentWithDepth(content *WebsiteContent, base
 	// Mark current URL as visited
 	w.markURLVisited(baseURL)
 
+	// Scrape candidates with up to scrapeWorkers workers
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, w.scrapeWorkers)
+	for _, candidate := range w.selectLinkCandidates(content.Links, baseURL) {
+		if !w.canScrapeMore() || ctx.Err() != nil {
+			break
+		}
+
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(candidate linkCandidate) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			linkedContent, err := w.scrapeLinkedPageWithDepthAndContent(ctx, candidate.url, depth+1, "linked", "", content)
+			if err == nil && linkedContent != nil {
+				linkedContent.Relevance = candidate.relevance
+				w.addLinkedContent(content, candidate.url, linkedContent)
+			}
+			// Note: scrapeLinkedPageWithDepth handles its own recording and recursion
+		}(candidate)
+	}
+	wg.Wait()
+}
+
+// selectLinkCandidates filters the page links down to those worth scraping, ordered by
+// estimated relevance and capped at maxLinkCandidates so huge pages stay cheap to process
+func (w *WebScraper) selectLinkCandidates(links []Link, baseURL string) []linkCandidate {
+	var candidates []linkCandidate
+	seen := make(map[string]bool)
+
 	// Process both professional links and internal navigation links
-	for _, link := range content.Links {
+	for _, link := range links {
 		shouldProcess := false
 		fullURL := link.URL
 
@@ -673,6 +2344,11 @@ func (w *WebScraper) processLinkedContentWithDepth(content *WebsiteContent, base
 			fullURL = w.resolveURL(baseURL, link.URL)
 		}
 
+		// Never leave the site's domain in same-domain mode, not even for professional links
+		if !w.isInScope(fullURL) {
+			continue
+		}
+
 		// Check if it's a professional link (external profiles)
 		if w.isProfessionalLink(fullURL) {
 			shouldProcess = true
@@ -683,30 +2359,77 @@ func (w *WebScraper) processLinkedContentWithDepth(content *WebsiteContent, base
 			shouldProcess = true
 		}
 
-		if shouldProcess {
-			linkedContent, err := w.scrapeLinkedPageWithDepthAndContent(fullURL, depth+1, content)
-			if err == nil && linkedContent != nil {
-				content.LinkedContent[fullURL] = linkedContent
-			}
-			// Note: scrapeLinkedPageWithDepth handles its own recording and recursion
+		// HTML documents parsed as files (PARSE_HTML_FILES) are loaded by processFiles
+		if shouldProcess && w.fileParser.parseHTMLFiles && w.isFileLink(fullURL) {
+			shouldProcess = false
+		}
+
+		normalizedURL := w.normalizeURL(fullURL)
+		if !shouldProcess || seen[normalizedURL] || w.isURLVisited(fullURL) {
+			continue
 		}
+		seen[normalizedURL] = true
+
+		candidates = append(candidates, linkCandidate{
+			url:       fullURL,
+			relevance: w.calculateRelevance(fullURL, link.Title),
+		})
+	}
+
+	// Best candidates first, keeping page order among equally relevant links
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].relevance > candidates[j].relevance
+	})
+
+	if len(candidates) > w.maxLinkCandidates {
+		candidates = candidates[:w.maxLinkCandidates]
 	}
+
+	return candidates
 }
 
-func (w *WebScraper) isProfessionalLink(url string) bool {
-	professionalDomains := []string{
-		"linkedin.com",
-		"github.com",
-		"gitlab.com",
-		"stackoverflow.com",
-		"medium.com",
-		"dev.to",
-		"twitter.com",
-		"x.com",
+// defaultProfessionalDomains are the profile and publishing sites followed as professional links
+var defaultProfessionalDomains = []string{
+	"linkedin.com",
+	"github.com",
+	"gitlab.com",
+	"stackoverflow.com",
+	"medium.com",
+	"dev.to",
+	"twitter.com",
+	"x.com",
+}
+
+// parseProfessionalDomains extends the built-in professional domains with a comma-separated list,
+// or replaces them when replace is set and the list isn't empty
+func parseProfessionalDomains(spec string, replace bool) []string {
+	var custom []string
+	for _, domain := range strings.Split(spec, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			custom = append(custom, domain)
+		}
 	}
 
+	if replace && len(custom) > 0 {
+		return custom
+	}
+	domains := append([]string(nil), defaultProfessionalDomains...)
+	for _, domain := range custom {
+		if !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// ProfessionalDomains returns the effective list of professional link domains
+func (w *WebScraper) ProfessionalDomains() []string {
+	return append([]string(nil), w.professionalDomains...)
+}
+
+func (w *WebScraper) isProfessionalLink(url string) bool {
 	lowerURL := strings.ToLower(url)
-	for _, domain := range professionalDomains {
+	for _, domain := range w.professionalDomains {
 		if strings.Contains(lowerURL, domain) {
 			return true
 		}
@@ -767,10 +2490,16 @@ func (w *WebScraper) isInternalNavigationLink(fullUrl, linkType string) bool {
 //	return w.scrapeLinkedPageWithDepthAndContent(targetUrl, depth, nil)
 //}
 
-func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(targetUrl string, depth int, mainContent *WebsiteContent) (*LinkedPageContent, error) {
+// scrapeLinkedPageWithDepthAndContent scrapes a linked page, recording it in the scrape log as urlType
+// found in the parent document ("" for a page linked from another page)
+func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(ctx context.Context, targetUrl string, depth int, urlType, parent string, mainContent *WebsiteContent) (*LinkedPageContent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Check depth limit and page limit
 	if depth >= w.maxScrapingDepth || !w.canScrapeMore() {
-		return nil, fmt.Errorf("scraping limits reached: depth=%d, pages=%d", depth, w.scrapedPagesCount)
+		return nil, fmt.Errorf("scraping limits reached: depth=%d, max pages=%d", depth, w.maxPagesPerSession)
 	}
 
 	// Check if URL already visited
@@ -778,47 +2507,87 @@ func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(targetUrl string, depth
 		return nil, fmt.Errorf("URL already visited: %s", targetUrl)
 	}
 
+	// Skip pages that failed permanently on an earlier crawl
+	if err := w.cachedPageFailure(targetUrl); err != nil {
+		w.markURLVisited(targetUrl)
+		w.recordScrapedUrlFrom(parent, targetUrl, urlType, "", false, err, 0, "negative_cached")
+		return nil, err
+	}
+
+	// Reuse the copy saved by an earlier session instead of fetching the page again
+	if w.persistLinkedPages && !w.refreshContent && !w.skipLinkedCache && w.isUrlAllowed(targetUrl) {
+		if cached := w.loadLinkedPageFromDisk(targetUrl); cached != nil {
+			if !w.claimURL(targetUrl, false) {
+				return nil, fmt.Errorf("URL already visited: %s", targetUrl)
+			}
+			w.cacheHits.Add(1)
+			w.recordScrapedUrlFrom(parent, targetUrl, urlType, cached.Content.Title, true, nil, cached.Content.Relevance, "disk_cached")
+			w.followCachedNestedPages(ctx, cached.NestedURLs, depth, mainContent)
+			return cached.Content, nil
+		}
+	}
+
 	log.Printf("Scraping linked page (depth %d): %s\n", depth, targetUrl)
 
-	// Mark URL as visited
-	w.markURLVisited(targetUrl)
-	w.scrapedPagesCount++
+	// Mark URL as visited and count it; another worker may have claimed it or the last page meanwhile
+	if !w.claimURL(targetUrl, true) {
+		return nil, fmt.Errorf("URL already visited or page limit reached: %s", targetUrl)
+	}
 	// Check if the URL is allowed to be scraped
 	if !w.isUrlAllowed(targetUrl) {
 		err := fmt.Errorf("URL not allowed for scraping: %s", targetUrl)
-		w.recordScrapedUrl(targetUrl, "linked", "", false, err, 0, "")
+		w.recordScrapedUrlFrom(parent, targetUrl, urlType, "", false, err, 0, "")
+		w.rememberPageFailure(targetUrl, "not_allowed", err)
 		return nil, err
 	}
 
 	client := &http.Client{
-		Timeout: 15 * time.Second,
+		Timeout:   15 * time.Second,
+		Jar:       w.cookieJar,
+		Transport: w.transport,
 	}
 
-	req, err := http.NewRequest("GET", targetUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetUrl, nil)
 	if err != nil {
-		w.recordScrapedUrl(targetUrl, "linked", "", false, err, 0, "")
+		w.recordScrapedUrlFrom(parent, targetUrl, urlType, "", false, err, 0, "")
 		return nil, err
 	}
 
 	// Add user agent to avoid being blocked
 	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WebSiteAssistantBot/1.0)")
 
-	resp, err := client.Do(req)
+	fetchStarted := time.Now()
+	resp, err := w.fetchPage(client, req)
 	if err != nil {
-		w.recordScrapedUrl(targetUrl, "linked", "", false, err, 0, "")
+		w.recordScrapedUrlFrom(parent, targetUrl, urlType, "", false, err, 0, "")
+		if ctx.Err() == nil {
+			w.warn("linked_scrape_failed", "Failed to scrape linked page", "url", targetUrl, "type", urlType, "error", err)
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		err := fmt.Errorf("HTTP %d", resp.StatusCode)
-		w.recordScrapedUrl(targetUrl, "linked", "", false, err, 0, "")
+		w.recordScrapedUrlFrom(parent, targetUrl, urlType, "", false, err, 0, "")
+		w.warn("linked_scrape_failed", "Failed to scrape linked page", "url", targetUrl, "type", urlType, "status", resp.StatusCode)
+		if !isTransientStatus(resp.StatusCode) {
+			w.rememberPageFailure(targetUrl, fmt.Sprintf("http_%d", resp.StatusCode), err)
+		}
 		return nil, err
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := w.readHTML(resp, targetUrl)
+	warnIfSlow(w.slowOpThreshold, "page fetch", targetUrl, fetchStarted)
 	if err != nil {
-		w.recordScrapedUrl(targetUrl, "linked", "", false, err, 0, "")
+		w.recordScrapedUrlFrom(parent, targetUrl, urlType, "", false, err, 0, "")
+		w.warn("linked_scrape_failed", "Failed to parse linked page", "url", targetUrl, "type", urlType, "error", err)
+		var tooLarge *DownloadTooLargeError
+		if errors.As(err, &tooLarge) {
+			w.rememberPageFailure(targetUrl, "too_large", err)
+		} else if !isTransientDownloadError(err) {
+			w.rememberPageFailure(targetUrl, "parse_error", err)
+		}
 		return nil, err
 	}
 
@@ -844,6 +2613,14 @@ func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(targetUrl string, depth
 		}
 	})
 
+	linkedContent.PublishedAt = extractPublishedDate(doc)
+	if w.extractContacts {
+		linkedContent.Contacts = extractContacts(doc, w.phones)
+	}
+	if w.extractBreadcrumbs {
+		linkedContent.Breadcrumbs = extractBreadcrumbs(doc)
+	}
+
 	// Extract keywords
 	doc.Find("meta[name='keywords']").Each(func(i int, s *goquery.Selection) {
 		if keywords, exists := s.Attr("content"); exists {
@@ -890,17 +2667,23 @@ func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(targetUrl string, depth
 
 	var b strings.Builder
 	b.Grow(10000) // Preallocate to avoid multiple allocations
+	var langCounts map[string]int
+	if w.extractLanguage {
+		langCounts = make(map[string]int)
+	}
 	doc.Find("body").Each(func(i int, s *goquery.Selection) {
-		walk(&b, s.Nodes[0], 0)
+		walk(&b, s.Nodes[0], 0, walkOptions{
+			structured:  w.structuredHTML,
+			maxDepth:    w.maxHTMLDepth,
+			structure:   w.walkStructure,
+			indentDepth: w.walkIndentDepth,
+			lang:        documentLang(doc),
+			langCounts:  langCounts,
+		})
 	})
+	linkedContent.Language, _ = languageHints(langCounts)
 
-	linkedContent.Text = b.String()
-
-	// Compile regex: one or more whitespace chars
-	re := regexp.MustCompile(`\s+`)
-
-	// Replace with single space
-	linkedContent.Text = re.ReplaceAllString(linkedContent.Text, " ")
+	linkedContent.Text = normalizeWhitespace(b.String(), w.whitespacePolicy)
 
 	// Limit content size to avoid overwhelming the AI TODO: configure
 	if len(linkedContent.Text) > w.maxContentLength {
@@ -908,12 +2691,19 @@ func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(targetUrl string, depth
 	}
 
 	// Process nested links recursively if we haven't reached max depth
+	var nestedURLs []string
 	if depth+1 < w.maxScrapingDepth && w.canScrapeMore() {
-		// Find and process external links from this page
-		doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		// Find and process external links from this page, at most maxFanoutPerPage of them
+		// so one densely linked page can't use up the page budget
+		followed := 0
+		doc.Find("a[href]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if w.maxFanoutPerPage > 0 && followed >= w.maxFanoutPerPage {
+				return false
+			}
+
 			href, exists := s.Attr("href")
 			if !exists {
-				return
+				return true
 			}
 
 			// Resolve relative URLs
@@ -924,66 +2714,392 @@ func (w *WebScraper) scrapeLinkedPageWithDepthAndContent(targetUrl string, depth
 
 			// Skip if not HTTP/HTTPS
 			if !strings.HasPrefix(fullURL, "http") {
-				return
+				return true
 			}
 
 			// Skip same domain links to avoid circular scraping
 			if w.isSameDomain(targetUrl, fullURL) {
-				return
+				return true
 			}
 
 			// Skip if already visited
 			if w.isURLVisited(fullURL) {
-				return
+				return true
 			}
 
 			// Skip if URL not allowed
 			if !w.isUrlAllowed(fullURL) {
-				return
+				return true
 			}
 
 			// Recursively scrape this URL and add to the main content if available
-			if nestedContent, err := w.scrapeLinkedPageWithDepthAndContent(fullURL, depth+1, mainContent); err == nil && nestedContent != nil {
+			followed++
+			if nestedContent, err := w.scrapeLinkedPageWithDepthAndContent(ctx, fullURL, depth+1, "linked", "", mainContent); err == nil && nestedContent != nil {
 				// If we have a main content structure, add this to it for access by the chatbot
 				if mainContent != nil {
-					mainContent.LinkedContent[fullURL] = nestedContent
+					w.addLinkedContent(mainContent, fullURL, nestedContent)
 				}
+				nestedURLs = append(nestedURLs, fullURL)
 			} else if err != nil {
 				// Log error but continue with other links
 				log.Printf("Failed to scrape nested link %s at depth %d: %v", fullURL, depth+1, err)
 			}
+			return true
 		})
 	}
 
 	// Record successful linked page scraping
-	w.recordScrapedUrl(targetUrl, "linked", linkedContent.Title, true, nil, linkedContent.Relevance, linkedContent.ContentType)
+	w.recordScrapedUrlFrom(parent, targetUrl, urlType, linkedContent.Title, true, nil, linkedContent.Relevance, linkedContent.ContentType)
+	w.pagesScraped.Add(1)
+
+	if w.persistLinkedPages {
+		if err := w.saveLinkedPageToDisk(targetUrl, linkedContent, nestedURLs); err != nil {
+			w.warn("disk_save_failed", "Failed to save linked page to disk", "url", targetUrl, "error", err)
+		}
+	}
 
 	return linkedContent, nil
 }
 
-func walk(b *strings.Builder, n *html.Node, indent int) {
+// followCachedNestedPages scrapes (or reuses) the pages that were found on a linked page
+// when it was saved, since a page reused from disk isn't parsed for links again
+func (w *WebScraper) followCachedNestedPages(ctx context.Context, nestedURLs []string, depth int, mainContent *WebsiteContent) {
+	for i, nestedURL := range nestedURLs {
+		if depth+1 >= w.maxScrapingDepth || !w.canScrapeMore() || ctx.Err() != nil || (w.maxFanoutPerPage > 0 && i >= w.maxFanoutPerPage) {
+			return
+		}
+		if w.isURLVisited(nestedURL) || !w.isUrlAllowed(nestedURL) {
+			continue
+		}
+		if nestedContent, err := w.scrapeLinkedPageWithDepthAndContent(ctx, nestedURL, depth+1, "linked", "", mainContent); err == nil && mainContent != nil {
+			w.addLinkedContent(mainContent, nestedURL, nestedContent)
+		}
+	}
+}
+
+const (
+	// WalkStructureFlat writes each element's text on its own line without nesting information
+	WalkStructureFlat = "flat"
+	// WalkStructureIndent indents each element's text by the number of enclosing elements that had text
+	WalkStructureIndent = "indent"
+	// WalkStructureTags indents like WalkStructureIndent and prefixes each line with its tag, e.g. "[h2] About"
+	WalkStructureTags = "tags"
+)
+
+// walkIndentUnit indents one level. Non-breaking spaces survive normalizeWhitespace, which drops
+// leading ASCII whitespace.
+const walkIndentUnit = "\u00a0\u00a0"
+
+// defaultMaxHTMLDepth is the element nesting walk descends to when MAX_HTML_DEPTH is unset
+const defaultMaxHTMLDepth = 128
+
+// parseMaxHTMLDepth reads the element nesting walked when extracting page and HTML file text
+// from MAX_HTML_DEPTH (default: 128, 0 = unlimited)
+func parseMaxHTMLDepth() int {
+	if maxDepthStr := os.Getenv("MAX_HTML_DEPTH"); maxDepthStr != "" {
+		if parsed, err := strconv.Atoi(maxDepthStr); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultMaxHTMLDepth
+}
+
+// walkOptions controls how walk turns an HTML tree into text
+type walkOptions struct {
+	structured  bool           // Emit <dl> and <table> with their structure, see EXTRACT_STRUCTURED_HTML
+	maxDepth    int            // Nesting level below which elements aren't visited, 0 for unlimited
+	structure   string         // WalkStructureFlat, WalkStructureIndent or WalkStructureTags
+	indentDepth int            // Indentation levels written at most
+	level       int            // Enclosing elements that wrote text, set by walk while descending
+	lang        string         // Nearest lang attribute, set by walk while descending
+	langCounts  map[string]int // Characters of text per language, when not nil
+}
+
+// nested reports whether element text is written with its nesting
+func (opts walkOptions) nested() bool {
+	return opts.structure == WalkStructureIndent || opts.structure == WalkStructureTags
+}
+
+// linePrefix returns the indentation and tag written before an element's text
+func (opts walkOptions) linePrefix(tag string) string {
+	prefix := strings.Repeat(walkIndentUnit, min(opts.level, opts.indentDepth))
+	if opts.structure == WalkStructureTags {
+		prefix += "[" + tag + "] "
+	}
+	return prefix
+}
+
+// walk writes the text of each element under n. An element's text includes its descendants', so
+// stopping at maxDepth loses no text; it only bounds the output on pathologically nested pages.
+// Elements are visited from an explicit stack, and the text of every subtree is gathered once up
+// front, so the time taken grows with the size of the page rather than with its depth.
+func walk(b *strings.Builder, n *html.Node, indent int, opts walkOptions) {
+	texts := collectSubtreeText(n)
+
+	type visit struct {
+		node   *html.Node
+		indent int
+		opts   walkOptions
+	}
+	stack := []visit{{node: n, indent: indent, opts: opts}}
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if opts.maxDepth > 0 && next.indent > opts.maxDepth {
+			continue
+		}
+		for _, child := range walkElement(b, next.node, next.opts, texts) {
+			stack = append(stack, visit{node: child.node, indent: next.indent + 1, opts: child.opts})
+		}
+	}
+}
+
+// walkChild is a child of an element walkElement wrote, with the options it is walked with
+type walkChild struct {
+	node *html.Node
+	opts walkOptions
+}
+
+// walkElement writes the text of a single node and returns the children to walk next, last
+// child first so that popping them off a stack visits them in document order
+func walkElement(b *strings.Builder, n *html.Node, opts walkOptions, texts subtreeText) []walkChild {
+	if n.Type == html.TextNode && opts.langCounts != nil && opts.lang != "" {
+		opts.langCounts[opts.lang] += utf8.RuneCountInString(strings.TrimSpace(n.Data))
+	}
 	if n.Type == html.ElementNode {
 		tag := n.Data
+		opts.lang = elementLang(n, opts.lang)
 
 		// Skip script/style
 		if tag == "script" || tag == "style" || tag == "noscript" || tag == "frame" || tag == "iframe" || tag == "a" {
-			return
+			return nil
+		}
+
+		// Elements written in one piece below aren't descended into, so count their languages here
+		if opts.langCounts != nil && ((opts.structured && (tag == "dl" || tag == "table")) || tag == "pre") {
+			countLanguages(n, opts.lang, opts.langCounts)
+		}
+
+		// Keep the key-value structure of definition lists and the rows of tables
+		if opts.structured && tag == "dl" {
+			writeDefinitionList(b, goquery.NewDocumentFromNode(n).Selection)
+			return nil
+		}
+		if opts.structured && tag == "table" {
+			writeTable(b, goquery.NewDocumentFromNode(n).Selection)
+			return nil
+		}
+
+		// Keep code examples intact in fenced blocks
+		if tag == "pre" {
+			writeCodeBlock(b, goquery.NewDocumentFromNode(n).Selection)
+			return nil
 		}
 
 		// If the element has text, print it
-		text := strings.TrimSpace(goquery.NewDocumentFromNode(n).Text())
-		if text != "" {
+		text := strings.TrimSpace(texts.of(n))
+		if text != "" && opts.nested() {
+			// One line per element, so the indentation applies to all of its text
+			b.WriteString(fmt.Sprintf("%s%s\n", opts.linePrefix(tag), allWhitespace.ReplaceAllString(text, " ")))
+		} else if text != "" {
 			b.WriteString(fmt.Sprintf("%s\n", text))
-			//b.WriteString(fmt.Sprintf("%s%s\n", strings.Repeat(" ", indent), text))
-			//b.WriteString(fmt.Sprintf("%s[%s] %s\n", strings.Repeat("  ", indent), tag, text))
 		}
 
-		// Recurse into children
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			walk(b, c, indent+1)
+		// Walk the children next, one level deeper below an element that wrote text
+		childOpts := opts
+		if text != "" {
+			childOpts.level++
+		}
+		var children []walkChild
+		for c := n.LastChild; c != nil; c = c.PrevSibling {
+			children = append(children, walkChild{node: c, opts: childOpts})
+		}
+		return children
+	}
+	return nil
+}
+
+// subtreeText holds the text of every element of a tree as a span of one buffer, which is what
+// goquery's Text returns for the element without copying it once per ancestor
+type subtreeText struct {
+	buf   string
+	spans map[*html.Node][2]int
+}
+
+// of returns the text of the text nodes under n, in document order
+func (t subtreeText) of(n *html.Node) string {
+	span := t.spans[n]
+	return t.buf[span[0]:span[1]]
+}
+
+// collectSubtreeText gathers the text under root in a single iterative pass, recording where each
+// element's text starts and ends
+func collectSubtreeText(root *html.Node) subtreeText {
+	var buf strings.Builder
+	spans := make(map[*html.Node][2]int)
+
+	type entry struct {
+		node    *html.Node
+		leaving bool
+	}
+	stack := []entry{{node: root}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.leaving {
+			span := spans[e.node]
+			span[1] = buf.Len()
+			spans[e.node] = span
+			continue
+		}
+		if e.node.Type == html.TextNode {
+			buf.WriteString(e.node.Data)
+			continue
+		}
+		spans[e.node] = [2]int{buf.Len(), 0}
+		stack = append(stack, entry{node: e.node, leaving: true})
+		for c := e.node.LastChild; c != nil; c = c.PrevSibling {
+			stack = append(stack, entry{node: c})
+		}
+	}
+	return subtreeText{buf: buf.String(), spans: spans}
+}
+
+// maxCodeBlockLength bounds a single code block, so one long listing can't take the whole page budget
+const maxCodeBlockLength = 4000
+
+// codeLanguagePattern finds the language in class names like "language-go" or "lang-python"
+var codeLanguagePattern = regexp.MustCompile(`\b(?:language|lang)-([\w+#-]+)`)
+
+// writeCodeBlock writes a <pre> element as a fenced code block, keeping its line breaks and indentation
+func writeCodeBlock(b *strings.Builder, pre *goquery.Selection) {
+	code := strings.Trim(pre.Text(), "\n")
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	if len(code) > maxCodeBlockLength {
+		code = code[:maxCodeBlockLength] + "\n..."
+	}
+
+	language := ""
+	classes := pre.AttrOr("class", "") + " " + pre.Find("code").First().AttrOr("class", "")
+	if match := codeLanguagePattern.FindStringSubmatch(classes); match != nil {
+		language = match[1]
+	}
+	b.WriteString(fmt.Sprintf("```%s\n%s\n```\n", language, code))
+}
+
+// writeDefinitionList writes each <dt> with its <dd> descriptions as a "term: description" line
+func writeDefinitionList(b *strings.Builder, dl *goquery.Selection) {
+	term := ""
+	var descriptions []string
+	flush := func() {
+		if term != "" || len(descriptions) > 0 {
+			b.WriteString(fmt.Sprintf("%s: %s\n", term, strings.Join(descriptions, "; ")))
+		}
+		term, descriptions = "", nil
+	}
+
+	// Groups may be wrapped in <div> elements inside the <dl>
+	dl.Find("dt, dd").Each(func(i int, s *goquery.Selection) {
+		if s.ParentsFiltered("dl").First().Get(0) != dl.Get(0) {
+			return // Belongs to a nested list
+		}
+		text := allWhitespace.ReplaceAllString(strings.TrimSpace(s.Text()), " ")
+		if goquery.NodeName(s) == "dt" {
+			if len(descriptions) > 0 {
+				flush()
+			}
+			term = strings.TrimSpace(term + " " + text)
+		} else if text != "" {
+			descriptions = append(descriptions, text)
+		}
+	})
+	flush()
+}
+
+// writeTable writes each table row as its cell texts separated by " | "
+func writeTable(b *strings.Builder, table *goquery.Selection) {
+	table.Find("tr").Each(func(i int, row *goquery.Selection) {
+		if row.ParentsFiltered("table").First().Get(0) != table.Get(0) {
+			return // Belongs to a nested table
+		}
+		var cells []string
+		row.ChildrenFiltered("th, td").Each(func(j int, cell *goquery.Selection) {
+			cells = append(cells, allWhitespace.ReplaceAllString(strings.TrimSpace(cell.Text()), " "))
+		})
+		if strings.TrimSpace(strings.Join(cells, "")) != "" {
+			b.WriteString(strings.Join(cells, " | ") + "\n")
+		}
+	})
+}
+
+const (
+	// WhitespacePreserve collapses whitespace within lines but keeps line and paragraph breaks
+	WhitespacePreserve = "preserve"
+	// WhitespaceFlatten collapses all whitespace, including line breaks, into single spaces
+	WhitespaceFlatten = "flatten"
+)
+
+var (
+	allWhitespace        = regexp.MustCompile(`\s+`)
+	intraLineWhitespace  = regexp.MustCompile(`[^\S\n]+`)
+	excessiveBlankLines  = regexp.MustCompile(`\n{3,}`)
+	whitespaceAroundLine = regexp.MustCompile(` ?\n ?`)
+	fencedCodeBlock      = regexp.MustCompile("(?sm)^```[^\n]*\n.*?\n```$")
+)
+
+// parseSlowOpThreshold reads SLOW_OP_THRESHOLD_MS; zero disables slow operation warnings
+func parseSlowOpThreshold() time.Duration {
+	if thresholdStr := os.Getenv("SLOW_OP_THRESHOLD_MS"); thresholdStr != "" {
+		if parsed, err := strconv.Atoi(thresholdStr); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Millisecond
 		}
 	}
+	return 0
+}
+
+// warnIfSlow logs a warning when an operation started at started took longer than threshold
+func warnIfSlow(threshold time.Duration, operation, target string, started time.Time) {
+	if elapsed := time.Since(started); threshold > 0 && elapsed > threshold {
+		logWarning("slow_operation", "Slow "+operation, "target", target, "elapsed", elapsed.Round(time.Millisecond), "threshold", threshold)
+	}
+}
+
+// parseWhitespacePolicy reads WHITESPACE_POLICY, falling back to WhitespacePreserve
+func parseWhitespacePolicy() string {
+	if strings.ToLower(os.Getenv("WHITESPACE_POLICY")) == WhitespaceFlatten {
+		return WhitespaceFlatten
+	}
+	return WhitespacePreserve
+}
+
+// normalizeWhitespace cleans up extracted text according to the given policy.
+// The preserve policy leaves fenced code blocks untouched, so code keeps its indentation.
+func normalizeWhitespace(text, policy string) string {
+	if policy == WhitespaceFlatten {
+		return allWhitespace.ReplaceAllString(text, " ")
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var b strings.Builder
+	last := 0
+	for _, block := range fencedCodeBlock.FindAllStringIndex(text, -1) {
+		b.WriteString(normalizeLines(text[last:block[0]]))
+		b.WriteString(text[block[0]:block[1]])
+		last = block[1]
+	}
+	b.WriteString(normalizeLines(text[last:]))
+	return strings.TrimSpace(b.String())
 }
+
+// normalizeLines collapses whitespace within lines and limits consecutive blank lines
+func normalizeLines(text string) string {
+	text = intraLineWhitespace.ReplaceAllString(text, " ")
+	text = whitespaceAroundLine.ReplaceAllString(text, "\n")
+	return excessiveBlankLines.ReplaceAllString(text, "\n\n")
+}
+
 func (w *WebScraper) determineContentType(url string) string {
 	lowerURL := strings.ToLower(url)
 
@@ -1000,48 +3116,56 @@ func (w *WebScraper) determineContentType(url string) string {
 	return "general"
 }
 
-//func (w *WebScraper) calculateRelevance(url, title string) int {
-//	relevance := 5 // Base relevance
-//
-//	lowerURL := strings.ToLower(url)
-//	lowerTitle := strings.ToLower(title)
-//
-//	// Professional platforms get higher relevance
-//	professionalKeywords := []string{"github", "linkedin", "gitlab", "portfolio", "resume", "cv"}
-//	for _, keyword := range professionalKeywords {
-//		if strings.Contains(lowerURL, keyword) || strings.Contains(lowerTitle, keyword) {
-//			relevance += 2
-//			break
-//		}
-//	}
-//
-//	// Technical content gets bonus
-//	techKeywords := []string{"developer", "engineer", "programming", "code", "software", "tech"}
-//	for _, keyword := range techKeywords {
-//		if strings.Contains(lowerTitle, keyword) {
-//			relevance += 1
-//			break
-//		}
-//	}
-//
-//	// Blog/article content
-//	blogKeywords := []string{"blog", "article", "tutorial", "guide"}
-//	for _, keyword := range blogKeywords {
-//		if strings.Contains(lowerURL, keyword) || strings.Contains(lowerTitle, keyword) {
-//			relevance += 1
-//			break
-//		}
-//	}
-//
-//	// Cap at 10
-//	if relevance > 10 {
-//		relevance = 10
-//	}
-//
-//	return relevance
-//}
+func (w *WebScraper) calculateRelevance(url, title string) int {
+	relevance := 5 // Base relevance
+
+	lowerURL := strings.ToLower(url)
+	lowerTitle := strings.ToLower(title)
+
+	// Professional platforms get higher relevance
+	professionalKeywords := []string{"github", "linkedin", "gitlab", "portfolio", "resume", "cv"}
+	for _, keyword := range professionalKeywords {
+		if strings.Contains(lowerURL, keyword) || strings.Contains(lowerTitle, keyword) {
+			relevance += 2
+			break
+		}
+	}
+
+	// Technical content gets bonus
+	techKeywords := []string{"developer", "engineer", "programming", "code", "software", "tech"}
+	for _, keyword := range techKeywords {
+		if strings.Contains(lowerTitle, keyword) {
+			relevance += 1
+			break
+		}
+	}
+
+	// Blog/article content
+	blogKeywords := []string{"blog", "article", "tutorial", "guide"}
+	for _, keyword := range blogKeywords {
+		if strings.Contains(lowerURL, keyword) || strings.Contains(lowerTitle, keyword) {
+			relevance += 1
+			break
+		}
+	}
+
+	// Cap at 10
+	if relevance > 10 {
+		relevance = 10
+	}
+
+	return relevance
+}
 
 func (w *WebScraper) isSameDomain(url1, url2 string) bool {
+	// Compare hosts in punycode form so unicode and ASCII spellings of an IDN match
+	parsed1, err1 := url.Parse(url1)
+	parsed2, err2 := url.Parse(url2)
+	if err1 == nil && err2 == nil && parsed1.Host != "" &&
+		strings.TrimPrefix(canonicalHost(parsed1.Host), "www.") == strings.TrimPrefix(canonicalHost(parsed2.Host), "www.") {
+		return true
+	}
+
 	// Simple domain comparison
 	if strings.Contains(url1, "github.com") && strings.Contains(url2, "github.com") {
 		return true
@@ -1053,20 +3177,112 @@ func (w *WebScraper) isSameDomain(url1, url2 string) bool {
 	return false
 }
 
+// enrichOutboundLinks fetches only the title and description of outbound links that were
+// not scraped in full, which is enough for link-listing questions at a fraction of the cost
+func (w *WebScraper) enrichOutboundLinks(ctx context.Context, content *WebsiteContent) {
+	enriched := 0
+	for i := range content.Links {
+		link := &content.Links[i]
+		if enriched >= w.maxLinkCandidates || ctx.Err() != nil {
+			break
+		}
+		if link.Type != "external" || w.isPDFLink(link.URL) || w.isFileLink(link.URL) || !w.isUrlAllowed(link.URL) {
+			continue
+		}
+		if _, scraped := content.LinkedContent[link.URL]; scraped {
+			continue
+		}
+
+		title, description, err := w.fetchLinkPreview(ctx, link.URL)
+		enriched++
+		if err != nil {
+			w.recordScrapedUrl(link.URL, "link_preview", "", false, err, 0, "")
+			continue
+		}
+
+		link.PageTitle = title
+		link.PageDescription = description
+		w.recordScrapedUrl(link.URL, "link_preview", title, true, nil, 0, "")
+	}
+}
+
+// fetchLinkPreview reads just the head of a page to extract its title and description
+func (w *WebScraper) fetchLinkPreview(ctx context.Context, targetUrl string) (string, string, error) {
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Jar:       w.cookieJar,
+		Transport: w.transport,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", targetUrl, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WebSiteAssistantBot/1.0)")
+
+	if err := w.hostLimiter.wait(ctx, urlHost(targetUrl)); err != nil {
+		return "", "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	// The title and meta tags live in the head, so the first 64KB is plenty
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", "", err
+	}
+	head, err := decodeBody(resp, raw)
+	if err != nil {
+		return "", "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(head))
+	if err != nil {
+		return "", "", err
+	}
+
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+	if ogTitle, exists := doc.Find("meta[property='og:title']").Attr("content"); exists && title == "" {
+		title = strings.TrimSpace(ogTitle)
+	}
+
+	description := ""
+	doc.Find("meta[property='og:description'], meta[name='description']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if desc, exists := s.Attr("content"); exists && strings.TrimSpace(desc) != "" {
+			description = strings.TrimSpace(desc)
+			return false
+		}
+		return true
+	})
+
+	return w.normalizeLinkTitle(title), description, nil
+}
+
 // parseHTMLFromURL fetches and parses HTML from a URL
-func (w *WebScraper) parseHTMLFromURL(targetUrl string) (*goquery.Document, error) {
+func (w *WebScraper) parseHTMLFromURL(ctx context.Context, targetUrl string) (*goquery.Document, error) {
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Jar:       w.cookieJar,
+		Transport: w.transport,
 	}
 
-	req, err := http.NewRequest("GET", targetUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetUrl, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; PersonalProfileBot/1.0)")
 
-	resp, err := client.Do(req)
+	started := time.Now()
+	defer func() { warnIfSlow(w.slowOpThreshold, "page fetch", targetUrl, started) }()
+
+	resp, err := w.fetchPage(client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -1076,5 +3292,5 @@ func (w *WebScraper) parseHTMLFromURL(targetUrl string) (*goquery.Document, erro
 		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
-	return goquery.NewDocumentFromReader(resp.Body)
+	return w.readHTML(resp, targetUrl)
 }
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// newTestScraper builds a scraper from the environment set by the test, without host rate limiting
+// and with its disk cache in a temporary directory
+func newTestScraper(t *testing.T) *WebScraper {
+	t.Helper()
+	t.Setenv("SCRAPER_REQUESTS_PER_SECOND", "0")
+	w := NewWebScraper()
+	w.cacheDir = t.TempDir()
+	return w
+}
+
+func parseTestHTML(t *testing.T, page string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("parsing HTML: %v", err)
+	}
+	return doc
+}
+
+func TestNormalizeWhitespacePolicies(t *testing.T) {
+	text := "Skills:\n  - Go  and\tRust\n  - Kubernetes\r\n\n\n\nExperience   at ACME\n\n```\nfunc main() {\n    run()\n}\n```"
+
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{
+			policy: WhitespacePreserve,
+			want:   "Skills:\n- Go and Rust\n- Kubernetes\n\nExperience at ACME\n\n```\nfunc main() {\n    run()\n}\n```",
+		},
+		{
+			policy: WhitespaceFlatten,
+			want:   "Skills: - Go and Rust - Kubernetes Experience at ACME ``` func main() { run() } ```",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			if got := normalizeWhitespace(text, tt.policy); got != tt.want {
+				t.Errorf("normalizeWhitespace(%q) =\n%q\nwant\n%q", tt.policy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWhitespacePolicy(t *testing.T) {
+	tests := map[string]string{
+		"":        WhitespacePreserve,
+		"FLATTEN": WhitespaceFlatten,
+		"flatten": WhitespaceFlatten,
+		"bogus":   WhitespacePreserve,
+	}
+	for value, want := range tests {
+		t.Setenv("WHITESPACE_POLICY", value)
+		if got := parseWhitespacePolicy(); got != want {
+			t.Errorf("WHITESPACE_POLICY=%q: got %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestExtractOutline(t *testing.T) {
+	doc := parseTestHTML(t, `<html><body>
+		<h1>Jane Doe</h1>
+		<h2>Experience</h2>
+		<h3>ACME   Corp</h3>
+		<h4>Too deep</h4>
+		<h3>Globex</h3>
+		<h2></h2>
+		<section><h2>Education</h2></section>
+	</body></html>`)
+
+	want := []OutlineEntry{
+		{Level: 1, Text: "Jane Doe"},
+		{Level: 2, Text: "Experience"},
+		{Level: 3, Text: "ACME Corp"},
+		{Level: 3, Text: "Globex"},
+		{Level: 2, Text: "Education"},
+	}
+	if got := extractOutline(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractOutline() = %+v, want %+v", got, want)
+	}
+}
+
+func TestIsUrlAllowedPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns string
+		url      string
+		want     bool
+	}{
+		{"no patterns", "", "https://example.com/anything", true},
+		{"substring", "github.com", "https://github.com/jane", true},
+		{"substring ignores case", "GitHub.com", "https://GITHUB.com/jane", true},
+		{"substring mismatch", "gitlab.com", "https://github.com/jane", false},
+		{"substring matches inside words", "blog", "https://weblogic.example.com/", true},
+		{"regex", `re:^https://example\.com/blog/`, "https://example.com/blog/post", true},
+		{"regex is anchored", `re:^https://example\.com/blog/`, "https://weblogic.example.com/blog/", false},
+		{"regex is case-sensitive", `re:^https://example\.com/blog/`, "https://example.com/BLOG/post", false},
+		{"mixed modes", `re:^https://example\.com/blog/,github.com`, "https://github.com/jane", true},
+		{"invalid regex is skipped", `re:(unclosed,github.com`, "https://github.com/jane", true},
+		{"invalid regex matches nothing", `re:(unclosed,github.com`, "https://example.com/(unclosed", false},
+		{"only invalid regexes allow nothing", `re:(unclosed`, "https://example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALLOWED_SCRAPING_URL_PATTERNS", tt.patterns)
+			w := NewWebScraper()
+			if got := w.isUrlAllowed(tt.url); got != tt.want {
+				t.Errorf("isUrlAllowed(%q) with %q = %v, want %v", tt.url, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkedPagesRespectPageLimit(t *testing.T) {
+	const links, maxPages = 20, 5
+
+	var mu sync.Mutex
+	var fetched, inFlight, peakInFlight int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(rw, "<html><head><title>Home</title></head><body><p>Welcome to the home page</p>")
+			for i := 0; i < links; i++ {
+				fmt.Fprintf(rw, `<a href="/page/%d">Page %d</a>`, i, i)
+			}
+			fmt.Fprint(rw, "</body></html>")
+			return
+		}
+
+		mu.Lock()
+		fetched++
+		inFlight++
+		peakInFlight = max(peakInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		fmt.Fprintf(rw, "<html><head><title>%s</title></head><body><p>Content of %s</p></body></html>", r.URL.Path, r.URL.Path)
+	}))
+	defer server.Close()
+
+	t.Setenv("ENABLE_INTERNAL_LINK_SCRAPING", "true")
+	t.Setenv("MAX_PAGES_PER_SESSION", fmt.Sprint(maxPages))
+	t.Setenv("MAX_CONCURRENT_SCRAPES", "8")
+	t.Setenv("PERSIST_LINKED_PAGES", "false")
+	w := newTestScraper(t)
+
+	content, err := w.ScrapeWebsite(server.URL + "/")
+	if err != nil {
+		t.Fatalf("ScrapeWebsite: %v", err)
+	}
+
+	if fetched > maxPages {
+		t.Errorf("fetched %d linked pages, want at most %d", fetched, maxPages)
+	}
+	if got := len(content.LinkedContent); got != maxPages {
+		t.Errorf("got %d linked pages, want %d", got, maxPages)
+	}
+	if w.scrapedPagesCount > maxPages {
+		t.Errorf("scrapedPagesCount = %d, want at most %d", w.scrapedPagesCount, maxPages)
+	}
+	if peakInFlight < 2 {
+		t.Errorf("linked pages were fetched one at a time, want concurrent fetches")
+	}
+}
+
+func TestScrapeRetriesTransientFailures(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			http.Error(rw, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(rw, "<html><head><title>Recovered</title></head><body><p>Content after two failures</p></body></html>")
+	}))
+	defer server.Close()
+
+	w := newTestScraper(t)
+	content, err := w.ScrapeWebsite(server.URL)
+	if err != nil {
+		t.Fatalf("ScrapeWebsite: %v", err)
+	}
+	if !strings.Contains(content.Text, "Content after two failures") {
+		t.Errorf("content text = %q, want the page served on the third attempt", content.Text)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("server got %d requests, want 3", got)
+	}
+	if got := w.GetScrapeLogStats().RetriesUsed; got != 2 {
+		t.Errorf("RetriesUsed = %d, want 2", got)
+	}
+}
+
+func TestFetchPageFailsFastOn404(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.NotFound(rw, r)
+	}))
+	defer server.Close()
+
+	w := newTestScraper(t)
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := w.fetchPage(server.Client(), req)
+	if err != nil {
+		t.Fatalf("fetchPage: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+}
+
+func TestFetchPageHonorsRetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		first := len(requests) == 1
+		mu.Unlock()
+		if first {
+			rw.Header().Set("Retry-After", "1")
+			http.Error(rw, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(rw, "ok")
+	}))
+	defer server.Close()
+
+	w := newTestScraper(t)
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := w.fetchPage(server.Client(), req)
+	if err != nil {
+		t.Fatalf("fetchPage: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(requests))
+	}
+	// Without Retry-After the first retry would come after the 500ms backoff
+	if gap := requests[1].Sub(requests[0]); gap < 900*time.Millisecond {
+		t.Errorf("retried after %s, want the 1s from Retry-After", gap)
+	}
+}
+
+func TestWalkDeeplyNestedDocument(t *testing.T) {
+	const depth = 10000
+	page := "<html><body>" + strings.Repeat("<div>", depth) + "Deepest text" + strings.Repeat("</div>", depth) + "</body></html>"
+	body := parseTestHTML(t, page).Find("body").Nodes[0]
+
+	tests := []struct {
+		maxDepth  int
+		wantLines int
+	}{
+		{maxDepth: defaultMaxHTMLDepth, wantLines: defaultMaxHTMLDepth + 1}, // <body> and the <div>s down to maxDepth
+		{maxDepth: 10, wantLines: 11},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.maxDepth), func(t *testing.T) {
+			var b strings.Builder
+			walk(&b, body, 0, walkOptions{maxDepth: tt.maxDepth, structure: WalkStructureFlat})
+
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if len(lines) != tt.wantLines {
+				t.Errorf("walk wrote %d lines, want %d", len(lines), tt.wantLines)
+			}
+			for _, line := range lines {
+				if line != "Deepest text" {
+					t.Fatalf("walk wrote %q, want only the text of the innermost element", line)
+				}
+			}
+		})
+	}
+}
+
+func TestScrapeLogRecordsDocumentParents(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(rw, `<html><head><title>Home</title></head><body><p>Welcome to the home page</p><a href="/projects.csv">Projects</a></body></html>`)
+		case "/projects.csv":
+			fmt.Fprintf(rw, "name,link\nReport,%[1]s/report.csv\nPortfolio,%[1]s/portfolio\n", server.URL)
+		case "/report.csv":
+			fmt.Fprint(rw, "quarter,revenue\nQ1,100\n")
+		case "/portfolio":
+			fmt.Fprint(rw, "<html><head><title>Portfolio</title></head><body><p>Projects built with Go</p></body></html>")
+		default:
+			http.NotFound(rw, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("MAX_DOCUMENT_DEPTH", "1")
+	t.Setenv("FOLLOW_DOCUMENT_LINKS", "true")
+	w := newTestScraper(t)
+	if _, err := w.ScrapeWebsite(server.URL + "/"); err != nil {
+		t.Fatalf("ScrapeWebsite: %v", err)
+	}
+
+	projects := server.URL + "/projects.csv"
+	want := map[string]string{
+		projects:                   "",
+		server.URL + "/report.csv": projects,
+		server.URL + "/portfolio":  projects,
+	}
+	for _, scraped := range w.GetScrapedUrls() {
+		if parent, ok := want[scraped.URL]; ok {
+			if scraped.LinkedFrom != parent {
+				t.Errorf("%s is linked from %q, want %q", scraped.URL, scraped.LinkedFrom, parent)
+			}
+			delete(want, scraped.URL)
+		}
+	}
+	for missing := range want {
+		t.Errorf("%s is not in the scrape log", missing)
+	}
+}
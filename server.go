@@ -1,34 +1,151 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type Server struct {
-	chatbot *Chatbot
+	chatbot             *Chatbot
+	maxRequestBodyBytes int64
+	includeDebug        bool
+	logRequestStats     bool
+	enableStats         bool
+	includeSources      bool
+	includeContentHash  bool
+	adminToken          string        // Required by POST /reload when set
+	reloadTimeout       time.Duration // Bound of a forced re-scrape, which outlives its request
+	chatSlots           chan struct{} // Semaphore bounding chats processed at once; nil for no limit
+	chatLimiter         *clientLimiter
+	trustProxy          bool // Take the client IP from X-Forwarded-For for rate limiting
+
+	// Lifetime counters for GET /stats
+	startedAt         time.Time
+	chatsHandled      atomic.Int64
+	chatLatencyMicros atomic.Int64
+	chatsRejected     atomic.Int64
 }
 
+// chatSlotWait is how long a chat waits for a free MAX_CONCURRENT_CHATS slot before getting a 503
+const chatSlotWait = 2 * time.Second
+
 type ChatRequest struct {
-	Message string `json:"message"`
+	Message   string `json:"message"`
+	SessionID string `json:"session_id,omitempty"` // Enables follow-up questions; falls back to the X-Session-ID header, then the session_id cookie
+
+	// Optional generation overrides; unset fields keep the service defaults
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 type ChatResponse struct {
-	Response  string `json:"response"`
-	Timestamp string `json:"timestamp"`
+	Response       string        `json:"response"`
+	Timestamp      string        `json:"timestamp"`
+	ContentUpdated string        `json:"content_updated,omitempty"` // When the answering content was scraped
+	ContentHash    string        `json:"content_hash,omitempty"`    // Changes when the answering content changes, when INCLUDE_CONTENT_HASH is set
+	Partial        bool          `json:"partial,omitempty"`         // The answer was cut short by the generation timeout
+	Sources        []string      `json:"sources,omitempty"`         // Categories of content the answer was based on
+	Snippets       []Snippet     `json:"snippets,omitempty"`        // Passages most relevant to the question, when SNIPPET_COUNT is set
+	Debug          *RequestStats `json:"debug,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// HealthResponse reports the service status; the dependency checks only run with ?deep=true
+type HealthResponse struct {
+	Status  string `json:"status"`            // "healthy", or "degraded" when a dependency is down
+	Ollama  string `json:"ollama,omitempty"`  // "up", "down" or "disabled"
+	Website string `json:"website,omitempty"` // "reachable" or "unreachable"
+}
+
+// ScrapeLogResponse is the scraping log of the last crawl together with its warnings
+type ScrapeLogResponse struct {
+	URLs     []ScrapedUrl    `json:"urls"`
+	Stats    ScrapeLogStats  `json:"stats"`
+	Warnings []ScrapeWarning `json:"warnings"` // Only collected with COLLECT_SCRAPE_WARNINGS
+}
+
+// ReloadResponse reports the outcome of a forced re-scrape
+type ReloadResponse struct {
+	PagesScraped int    `json:"pages_scraped"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ScrapeSummaryResponse counts the URLs a manual re-scrape processed
+type ScrapeSummaryResponse struct {
+	URLsProcessed int    `json:"urls_processed"`
+	Succeeded     int    `json:"succeeded"`
+	Failed        int    `json:"failed"`
+	Error         string `json:"error,omitempty"`
+}
+
 func NewServer(chatbot *Chatbot) *Server {
+	// Parse maximum chat request body size (default: 64KB)
+	maxRequestBodyBytes := int64(64 * 1024)
+	if maxBodyStr := os.Getenv("MAX_REQUEST_BODY_BYTES"); maxBodyStr != "" {
+		if parsed, err := strconv.ParseInt(maxBodyStr, 10, 64); err == nil && parsed > 0 {
+			maxRequestBodyBytes = parsed
+		}
+	}
+
+	// Parse maximum chats processed at once (default: 0, unlimited)
+	var chatSlots chan struct{}
+	if maxChatsStr := os.Getenv("MAX_CONCURRENT_CHATS"); maxChatsStr != "" {
+		if parsed, err := strconv.Atoi(maxChatsStr); err == nil && parsed > 0 {
+			chatSlots = make(chan struct{}, parsed)
+		}
+	}
+
+	// Parse how long a forced re-scrape may take (default: 10 minutes)
+	reloadTimeout := 10 * time.Minute
+	if timeoutStr := os.Getenv("RELOAD_TIMEOUT_MINUTES"); timeoutStr != "" {
+		if parsed, err := strconv.Atoi(timeoutStr); err == nil && parsed > 0 {
+			reloadTimeout = time.Duration(parsed) * time.Minute
+		}
+	}
+
+	// Parse chat requests allowed per client IP and minute (default: 20, 0 disables)
+	var chatLimiter *clientLimiter
+	ratePerMinute := 20
+	if rateStr := os.Getenv("RATE_LIMIT_PER_MINUTE"); rateStr != "" {
+		if parsed, err := strconv.Atoi(rateStr); err == nil && parsed >= 0 {
+			ratePerMinute = parsed
+		}
+	}
+	if ratePerMinute > 0 {
+		chatLimiter = newClientLimiter(ratePerMinute)
+	}
+
 	return &Server{
-		chatbot: chatbot,
+		chatbot:             chatbot,
+		maxRequestBodyBytes: maxRequestBodyBytes,
+		includeDebug:        strings.ToLower(os.Getenv("INCLUDE_DEBUG")) == "true",
+		logRequestStats:     strings.ToLower(os.Getenv("LOG_REQUEST_STATS")) == "true",
+		enableStats:         strings.ToLower(os.Getenv("ENABLE_STATS_ENDPOINT")) != "false",
+		includeSources:      strings.ToLower(os.Getenv("INCLUDE_SOURCE_CATEGORIES")) != "false",
+		includeContentHash:  strings.ToLower(os.Getenv("INCLUDE_CONTENT_HASH")) == "true",
+		adminToken:          os.Getenv("ADMIN_TOKEN"),
+		reloadTimeout:       reloadTimeout,
+		chatSlots:           chatSlots,
+		chatLimiter:         chatLimiter,
+		trustProxy:          strings.ToLower(os.Getenv("TRUST_PROXY")) == "true",
+		startedAt:           time.Now(),
 	}
 }
 
@@ -37,12 +154,40 @@ func (s *Server) SetupRoutes(r *mux.Router) {
 	r.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "static/favicon.ico")
 	})
-	r.HandleFunc("/chat", s.handleChat).Methods("POST")
+	r.HandleFunc("/chat", s.rateLimited(s.handleChat)).Methods("POST")
+	r.HandleFunc("/chat/stream", s.rateLimited(s.handleChatStream)).Methods("GET")
 	r.HandleFunc("/health", s.handleHealth).Methods("GET")
+	r.HandleFunc("/export", s.handleExport).Methods("GET")
+	r.HandleFunc("/diff", s.handleDiff).Methods("GET")
+	r.HandleFunc("/scraped", s.handleScraped).Methods("GET")
+	r.HandleFunc("/scraped-urls", s.handleScraped).Methods("GET")
+	r.HandleFunc("/scrape/log", s.handleScrapeLog).Methods("GET")
+	r.HandleFunc("/reload", s.handleReload).Methods("POST")
+	r.HandleFunc("/scrape", s.handleScrape).Methods("POST")
+	if s.enableStats {
+		r.HandleFunc("/stats", s.handleStats).Methods("GET")
+	}
 
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./static/"))))
 }
 
+// rateLimited rejects requests over RATE_LIMIT_PER_MINUTE from one client IP with 429
+func (s *Server) rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	if s.chatLimiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := clientIP(r, s.trustProxy)
+		if allowed, retryAfter := s.chatLimiter.allow(client); !allowed {
+			log.Printf("Rate limiting chat requests from %s", client)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "Too many requests, please slow down")
+			return
+		}
+		next(w, r)
+	}
+}
+
 func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, filepath.Join("static", "index.html"))
 }
@@ -51,15 +196,27 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Session-ID")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodyBytes)
+
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Printf("Chat request body exceeds %d bytes", maxBytesErr.Limit)
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			if encErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "Request body too large"}); encErr != nil {
+				log.Printf("Error encoding error response: %v", encErr)
+			}
+			return
+		}
+
 		log.Printf("Error decoding JSON request: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		if encErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON format"}); encErr != nil {
@@ -77,7 +234,41 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chatMessage, err := s.chatbot.ProcessMessage(req.Message)
+	overrides, status, err := s.chatOverrides(r.Context(), req)
+	if err != nil {
+		log.Printf("Rejecting chat overrides: %v", err)
+		w.WriteHeader(status)
+		if encErr := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); encErr != nil {
+			log.Printf("Error encoding error response: %v", encErr)
+		}
+		return
+	}
+
+	if !s.acquireChatSlot(r.Context()) {
+		s.rejectBusy(w)
+		return
+	}
+	defer s.releaseChatSlot()
+
+	sessionID := requestSessionID(r, req.SessionID)
+
+	started := time.Now()
+	chatMessage, err := s.chatbot.ProcessMessage(r.Context(), sessionID, req.Message, overrides, nil)
+	s.chatsHandled.Add(1)
+	s.chatLatencyMicros.Add(time.Since(started).Microseconds())
+	if errors.Is(err, ErrOllamaQueueFull) {
+		log.Printf("Rejecting chat message, Ollama queue is full")
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if encErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "Server is busy, please try again shortly"}); encErr != nil {
+			log.Printf("Error encoding error response: %v", encErr)
+		}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Client disconnected, abandoned chat message '%s'", req.Message)
+		return
+	}
 	if err != nil {
 		log.Printf("Error processing chat message '%s': %v", req.Message, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -87,21 +278,445 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(s.chatResponse(r, chatMessage)); err != nil {
+		log.Printf("Error encoding chat response: %v", err)
+	}
+}
+
+// handleChatStream answers like /chat, taking the request fields as query parameters, and streams the
+// answer as Server-Sent Events: a "token" event per generated chunk, then a "done" event with the
+// complete ChatResponse (whose response replaces the tokens, e.g. for fallback answers) or an "error" event
+func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := ChatRequest{
+		Message:   query.Get("message"),
+		SessionID: query.Get("session_id"),
+		Model:     query.Get("model"),
+	}
+	if temperatureStr := query.Get("temperature"); temperatureStr != "" {
+		temperature, err := strconv.ParseFloat(temperatureStr, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid temperature")
+			return
+		}
+		req.Temperature = &temperature
+	}
+
+	if req.Message == "" {
+		log.Printf("Received empty message request")
+		writeJSONError(w, http.StatusBadRequest, "Message cannot be empty")
+		return
+	}
+
+	overrides, status, err := s.chatOverrides(r.Context(), req)
+	if err != nil {
+		log.Printf("Rejecting chat overrides: %v", err)
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	if !s.acquireChatSlot(r.Context()) {
+		s.rejectBusy(w)
+		return
+	}
+	defer s.releaseChatSlot()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	type result struct {
+		chatMessage *ChatMessage
+		err         error
+	}
+	tokens := make(chan string, 16)
+	done := make(chan result, 1)
+	started := time.Now()
+	go func() {
+		chatMessage, err := s.chatbot.ProcessMessage(r.Context(), requestSessionID(r, req.SessionID), req.Message, overrides, tokens)
+		close(tokens)
+		done <- result{chatMessage, err}
+	}()
+
+	for token := range tokens {
+		writeEvent(w, "token", token)
+		flusher.Flush()
+	}
+	res := <-done
+	s.chatsHandled.Add(1)
+	s.chatLatencyMicros.Add(time.Since(started).Microseconds())
+
+	switch {
+	case errors.Is(res.err, context.Canceled):
+		log.Printf("Client disconnected, abandoned chat message '%s'", req.Message)
+		return
+	case errors.Is(res.err, ErrOllamaQueueFull):
+		log.Printf("Rejecting chat message, Ollama queue is full")
+		writeEvent(w, "error", ErrorResponse{Error: "Server is busy, please try again shortly"})
+	case res.err != nil:
+		log.Printf("Error processing chat message '%s': %v", req.Message, res.err)
+		writeEvent(w, "error", ErrorResponse{Error: "Failed to process message"})
+	default:
+		writeEvent(w, "done", s.chatResponse(r, res.chatMessage))
+	}
+	flusher.Flush()
+}
+
+// acquireChatSlot takes one of the MAX_CONCURRENT_CHATS slots, waiting up to chatSlotWait for one
+// to free up. It reports false when none did or the client went away.
+func (s *Server) acquireChatSlot(ctx context.Context) bool {
+	if s.chatSlots == nil {
+		return true
+	}
+	select {
+	case s.chatSlots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(chatSlotWait)
+	defer timer.Stop()
+	select {
+	case s.chatSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	s.chatsRejected.Add(1)
+	return false
+}
+
+func (s *Server) releaseChatSlot() {
+	if s.chatSlots != nil {
+		<-s.chatSlots
+	}
+}
+
+// rejectBusy answers 503 with a Retry-After hint, for chats the server has no capacity for
+func (s *Server) rejectBusy(w http.ResponseWriter) {
+	log.Printf("Rejecting chat message, %d chats are already being processed", cap(s.chatSlots))
+	w.Header().Set("Retry-After", "5")
+	writeJSONError(w, http.StatusServiceUnavailable, "Server is busy, please try again shortly")
+}
+
+// requestSessionID returns the session ID sent with a chat request, or else the X-Session-ID
+// header or the session_id cookie
+func requestSessionID(r *http.Request, sessionID string) string {
+	if sessionID == "" {
+		sessionID = strings.TrimSpace(r.Header.Get("X-Session-ID"))
+	}
+	if sessionID == "" {
+		if cookie, err := r.Cookie("session_id"); err == nil {
+			sessionID = cookie.Value
+		}
+	}
+	return sessionID
+}
+
+// writeEvent writes a Server-Sent Event with data encoded as JSON, so tokens with newlines fit in one data line
+func writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", event, err)
+		return
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+		log.Printf("Error writing %s event: %v", event, err)
+	}
+}
+
+// writeJSONError writes an ErrorResponse with the given status
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: message}); err != nil {
+		log.Printf("Error encoding error response: %v", err)
+	}
+}
+
+// chatResponse builds the response to an answered chat message, logging its stats if configured
+func (s *Server) chatResponse(r *http.Request, chatMessage *ChatMessage) ChatResponse {
 	response := ChatResponse{
 		Response:  chatMessage.Response,
 		Timestamp: chatMessage.Timestamp.Format("2006-01-02 15:04:05"),
 	}
+	if !chatMessage.ContentUpdatedAt.IsZero() {
+		response.ContentUpdated = chatMessage.ContentUpdatedAt.Format("2006-01-02 15:04:05")
+	}
+	if s.includeContentHash {
+		response.ContentHash = chatMessage.ContentHash
+	}
+	response.Snippets = chatMessage.Snippets
+	if chatMessage.Stats != nil {
+		response.Partial = chatMessage.Stats.Partial
+		if s.includeSources {
+			response.Sources = chatMessage.Stats.SourceCategories
+		}
+	}
+	if s.includeDebug || r.URL.Query().Get("debug") == "1" {
+		response.Debug = chatMessage.Stats
+	}
+	if s.logRequestStats {
+		if statsJSON, err := json.Marshal(chatMessage.Stats); err == nil {
+			log.Printf("Request stats: %s", statsJSON)
+		}
+	}
+	return response
+}
+
+// chatOverrides validates the model and temperature of a chat request. A model must be one
+// Ollama has pulled; the error names the available ones.
+func (s *Server) chatOverrides(ctx context.Context, req ChatRequest) (*OllamaOptions, int, error) {
+	if req.Model == "" && req.Temperature == nil {
+		return nil, 0, nil
+	}
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 2) {
+		return nil, http.StatusBadRequest, fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if req.Model != "" {
+		if s.chatbot.ollamaService == nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("model %q can't be used, Ollama is not configured", req.Model)
+		}
+		models, err := s.chatbot.ollamaService.AvailableModels(ctx)
+		if err != nil {
+			return nil, http.StatusBadGateway, fmt.Errorf("could not list Ollama models to check %q", req.Model)
+		}
+		if !hasModel(models, req.Model) {
+			return nil, http.StatusBadRequest, fmt.Errorf("model %q is not available; available models: %s", req.Model, strings.Join(models, ", "))
+		}
+	}
+	return &OllamaOptions{Model: req.Model, Temperature: req.Temperature}, 0, nil
+}
+
+// handleHealth answers "healthy" without checking anything, so frequent liveness probes stay cheap.
+// With ?deep=true it also checks Ollama and the website and answers 503 if either is down.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := HealthResponse{Status: "healthy"}
+	status := http.StatusOK
+	if r.URL.Query().Get("deep") == "true" {
+		ollama, websiteErr := s.chatbot.Health(r.Context())
+		response.Ollama = ollama
+		response.Website = "reachable"
+		if websiteErr != nil {
+			log.Printf("Health check: website unreachable: %v", websiteErr)
+			response.Website = "unreachable"
+		}
+		if ollama == "down" || websiteErr != nil {
+			response.Status = "degraded"
+			status = http.StatusServiceUnavailable
+		}
+	}
 
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding health response: %v", err)
+	}
+}
+
+// handleScraped returns the scraping log of the last crawl, so operators can see what was fetched and
+// why pages failed. With ?type= only the entries of that type are returned, e.g. ?type=linked.
+func (s *Server) handleScraped(w http.ResponseWriter, r *http.Request) {
+	scrapedUrls := s.chatbot.GetScrapedUrls(r.URL.Query().Get("type"))
+	if scrapedUrls == nil {
+		scrapedUrls = []ScrapedUrl{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(scrapedUrls); err != nil {
+		log.Printf("Error encoding scraped URLs response: %v", err)
+	}
+}
+
+// handleScrapeLog returns the scraping log with its aggregate counters and the session's warnings
+func (s *Server) handleScrapeLog(w http.ResponseWriter, r *http.Request) {
+	response := ScrapeLogResponse{
+		URLs:     s.chatbot.GetScrapedUrls(""),
+		Stats:    s.chatbot.GetScrapeLogStats(),
+		Warnings: s.chatbot.GetScrapeWarnings(),
+	}
+	if response.URLs == nil {
+		response.URLs = []ScrapedUrl{}
+	}
+	if response.Warnings == nil {
+		response.Warnings = []ScrapeWarning{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding chat response: %v", err)
+		log.Printf("Error encoding scrape log response: %v", err)
 	}
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+// handleReload re-scrapes the website bypassing all caches. With ADMIN_TOKEN set the request must
+// carry it as "Authorization: Bearer <token>" or in an X-Admin-Token header.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAdmin(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid or missing admin token"}); err != nil {
+			log.Printf("Error encoding error response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := s.reloadContext(r)
+	defer cancel()
+	stats, err := s.chatbot.ForceRefresh(ctx)
+	response := ReloadResponse{PagesScraped: stats.Pages}
+	status := http.StatusOK
+	if err != nil {
+		log.Printf("Error reloading website data: %v", err)
+		response.Error = err.Error()
+		status = http.StatusBadGateway
+	}
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding reload response: %v", err)
+	}
+}
+
+// handleScrape re-scrapes the website like POST /reload and summarizes the scraping log. It is
+// protected by ADMIN_TOKEN in the same way.
+func (s *Server) handleScrape(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isAdmin(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid or missing admin token"}); err != nil {
+			log.Printf("Error encoding error response: %v", err)
+		}
+		return
+	}
+
+	ctx, cancel := s.reloadContext(r)
+	defer cancel()
+	// The log itself is capped by MAX_SCRAPE_LOG_ENTRIES; its counters cover the whole crawl
+	stats, err := s.chatbot.ForceRefresh(ctx)
+	response := ScrapeSummaryResponse{URLsProcessed: stats.Total, Succeeded: stats.Success, Failed: stats.Failed}
+	status := http.StatusOK
+	if err != nil {
+		log.Printf("Error re-scraping website data: %v", err)
+		response.Error = err.Error()
+		status = http.StatusBadGateway
+	}
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding scrape response: %v", err)
+	}
+}
+
+// reloadContext returns the context of a forced re-scrape. It isn't cancelled when the client
+// disconnects, so a scrape that has cleared the log always runs to the end, but it is bounded by
+// RELOAD_TIMEOUT_MINUTES.
+func (s *Server) reloadContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), s.reloadTimeout)
+}
+
+// isAdmin reports whether a request carries ADMIN_TOKEN, or true when no token is configured
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.adminToken == "" {
+		return true
+	}
+	token := r.Header.Get("X-Admin-Token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+}
+
+// handleDiff returns the lines added and removed between the previous and current cached content of ?url=
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	diff, err := s.chatbot.ContentDiff(r.URL.Query().Get("url"))
+	if err != nil {
+		message := "No scraped content available for this URL"
+		if errors.Is(err, ErrNoSnapshot) {
+			message = "No previous version to compare with; it is kept once the content changes with ENABLE_CONTENT_DIFF=true or CACHE_VERSIONS set"
+		}
+		log.Printf("No content diff for '%s': %v", r.URL.Query().Get("url"), err)
+		w.WriteHeader(http.StatusNotFound)
+		if encErr := json.NewEncoder(w).Encode(ErrorResponse{Error: message}); encErr != nil {
+			log.Printf("Error encoding error response: %v", encErr)
+		}
+		return
+	}
+	if diff.Added == nil {
+		diff.Added = []DiffSection{}
+	}
+	if diff.Removed == nil {
+		diff.Removed = []DiffSection{}
+	}
+
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "healthy"}); err != nil {
-		log.Printf("Error encoding health response: %v", err)
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		log.Printf("Error encoding diff response: %v", err)
+	}
+}
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	targetUrl := r.URL.Query().Get("url")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "md"
+	}
+
+	if format != "md" && format != "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "Unsupported format, use md or json"}); err != nil {
+			log.Printf("Error encoding error response: %v", err)
+		}
+		return
+	}
+
+	content, err := s.chatbot.GetSiteContent(targetUrl)
+	if err != nil || content == nil {
+		log.Printf("No cached content to export for '%s': %v", targetUrl, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		if encErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "No scraped content available for this URL"}); encErr != nil {
+			log.Printf("Error encoding error response: %v", encErr)
+		}
+		return
+	}
+
+	if targetUrl == "" {
+		targetUrl = s.chatbot.websiteURL
+	}
+
+	if format == "json" {
+		// Embedding vectors are only useful to semantic search and would dwarf the text
+		exported := *content
+		exported.Chunks = nil
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&exported); err != nil {
+			log.Printf("Error encoding export response: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(RenderKnowledgeMarkdown(targetUrl, content))); err != nil {
+		log.Printf("Error writing export response: %v", err)
 	}
 }
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// newTestRouter serves the routes of a server for the chatbot
+func newTestRouter(c *Chatbot) *mux.Router {
+	r := mux.NewRouter()
+	NewServer(c).SetupRoutes(r)
+	return r
+}
+
+func TestHandleScraped(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	c := newTestChatbot(t, site.URL, ollama.URL)
+	if err := c.refreshWebsiteData(context.Background()); err != nil {
+		t.Fatalf("refreshWebsiteData: %v", err)
+	}
+	router := newTestRouter(c)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/scraped", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("no scraped URLs after scraping the site")
+	}
+	entry := entries[0]
+	if entry["url"] != site.URL || entry["type"] != "main" || entry["success"] != true {
+		t.Errorf("first entry = %v, want the successful main page", entry)
+	}
+	for _, key := range []string{"scraped_at", "content_type"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("entry has no %q key: %v", key, entry)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/scraped?type=linked", nil))
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Errorf("?type=linked = %q, want an empty list", body)
+	}
+}
+
+// postChat sends a chat request through the router
+func postChat(router http.Handler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestChatOverridesReachOllama(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b", "llama3:8b")
+	router := newTestRouter(newTestChatbot(t, site.URL, ollama.URL))
+
+	rec := postChat(router, `{"message": "What does Jane do?", "model": "llama3:8b", "temperature": 0.3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	rec = postChat(router, `{"message": "What does Jane do?"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	ollama.mu.Lock()
+	defer ollama.mu.Unlock()
+	if len(ollama.requests) != 2 {
+		t.Fatalf("Ollama got %d generation requests, want 2", len(ollama.requests))
+	}
+	overridden, defaults := ollama.requests[0], ollama.requests[1]
+	if overridden.Model != "llama3:8b" {
+		t.Errorf("model = %q, want llama3:8b", overridden.Model)
+	}
+	if got := overridden.Options["temperature"]; got != 0.3 {
+		t.Errorf("temperature option = %v, want 0.3", got)
+	}
+	if _, ok := defaults.Options["temperature"]; ok || defaults.Model != "codellama:13b" {
+		t.Errorf("request without overrides = model %q, options %v, want the defaults", defaults.Model, defaults.Options)
+	}
+}
+
+func TestChatRejectsInvalidOverrides(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b", "llama3:8b")
+	router := newTestRouter(newTestChatbot(t, site.URL, ollama.URL))
+
+	tests := []struct {
+		name      string
+		body      string
+		wantError string
+	}{
+		{"unknown model", `{"message": "Hi", "model": "gpt-4"}`, `model "gpt-4" is not available; available models: codellama:13b, llama3:8b`},
+		{"temperature too high", `{"message": "Hi", "temperature": 2.5}`, "temperature must be between 0 and 2"},
+		{"negative temperature", `{"message": "Hi", "temperature": -1}`, "temperature must be between 0 and 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postChat(router, tt.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+			if response.Error != tt.wantError {
+				t.Errorf("error = %q, want %q", response.Error, tt.wantError)
+			}
+		})
+	}
+	if prompts := ollama.prompts(); len(prompts) != 0 {
+		t.Errorf("Ollama got %d generation requests for rejected chats, want none", len(prompts))
+	}
+}
+
+// closedServerURL returns the URL of a server that is no longer listening
+func closedServerURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestHandleHealth(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	failingSite := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer failingSite.Close()
+
+	tests := []struct {
+		name       string
+		siteURL    string
+		ollamaURL  string
+		query      string
+		wantStatus int
+		want       HealthResponse
+	}{
+		{"shallow check skips dependencies", closedServerURL(t), closedServerURL(t), "", http.StatusOK, HealthResponse{Status: "healthy"}},
+		{"all up", site.URL, ollama.URL, "?deep=true", http.StatusOK, HealthResponse{Status: "healthy", Ollama: "up", Website: "reachable"}},
+		{"Ollama down", site.URL, closedServerURL(t), "?deep=true", http.StatusServiceUnavailable, HealthResponse{Status: "degraded", Ollama: "down", Website: "reachable"}},
+		{"site unreachable", closedServerURL(t), ollama.URL, "?deep=true", http.StatusServiceUnavailable, HealthResponse{Status: "degraded", Ollama: "up", Website: "unreachable"}},
+		{"site failing", failingSite.URL, ollama.URL, "?deep=true", http.StatusServiceUnavailable, HealthResponse{Status: "degraded", Ollama: "up", Website: "unreachable"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(newTestChatbot(t, tt.siteURL, tt.ollamaURL))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/health"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var got HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+			if got != tt.want {
+				t.Errorf("health = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	const perMinute = 3
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	t.Setenv("RATE_LIMIT_PER_MINUTE", fmt.Sprint(perMinute))
+	router := newTestRouter(newTestChatbot(t, site.URL, ollama.URL))
+
+	chatFrom := func(remoteAddr string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/chat", strings.NewReader(`{"message": "What does Jane do?"}`))
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < perMinute; i++ {
+		if rec := chatFrom("192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200: %s", i+1, rec.Code, rec.Body)
+		}
+	}
+
+	rec := chatFrom("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request %d: status = %d, want 429", perMinute+1, rec.Code)
+	}
+	// One token comes back every 60/perMinute seconds
+	if got := rec.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("Retry-After = %q, want 20", got)
+	}
+
+	if rec := chatFrom("198.51.100.7:1234"); rec.Code != http.StatusOK {
+		t.Errorf("another client: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(rw, `<html><head><title>Jane Doe</title></head><body>
+				<p>Jane is a software engineer who builds distributed systems in Go.</p>
+				<a href="/projects.csv">Projects</a>
+			</body></html>`)
+		case "/projects.csv":
+			fmt.Fprint(rw, "name,language\nScheduler,Go\n")
+		default:
+			http.NotFound(rw, r)
+		}
+	}))
+	defer site.Close()
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	t.Setenv("ADMIN_TOKEN", "secret")
+	c := newTestChatbot(t, site.URL, ollama.URL)
+	router := newTestRouter(c)
+
+	reload := func(ctx context.Context, token string) (int, ReloadResponse) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/reload", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(rec, req)
+		var response ReloadResponse
+		if rec.Code != http.StatusUnauthorized {
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+		}
+		return rec.Code, response
+	}
+
+	if status, _ := reload(context.Background(), "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", status)
+	}
+
+	// The scrape outlives a client that has already gone away
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	status, response := reload(cancelled, "secret")
+	if status != http.StatusOK || response.Error != "" {
+		t.Fatalf("reload: status = %d, error %q, want 200", status, response.Error)
+	}
+	// The CSV file is a document, not a page
+	if response.PagesScraped != 1 {
+		t.Errorf("pages_scraped = %d, want 1", response.PagesScraped)
+	}
+	before := c.currentData()
+	if before == nil || before.Title != "Jane Doe" {
+		t.Fatalf("content after reload = %+v, want the scraped site", before)
+	}
+
+	site.Close()
+	status, response = reload(context.Background(), "secret")
+	if status != http.StatusBadGateway || response.Error == "" {
+		t.Errorf("failed reload: status = %d, error %q, want 502 with an error", status, response.Error)
+	}
+	if c.currentData() != before {
+		t.Error("failed reload replaced the current content")
+	}
+}
+
+func TestHandleScrape(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(rw, `<html><head><title>Jane Doe</title></head><body>
+				<p>Jane is a software engineer who builds distributed systems in Go.</p>
+				<a href="/projects.csv">Projects</a> <a href="/talks.csv">Talks</a>
+			</body></html>`)
+		case "/projects.csv":
+			fmt.Fprint(rw, "name,language\nScheduler,Go\n")
+		default:
+			http.NotFound(rw, r)
+		}
+	}))
+	defer site.Close()
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	// The summary counts the whole crawl, not just the entries the log retains
+	t.Setenv("MAX_SCRAPE_LOG_ENTRIES", "1")
+	router := newTestRouter(newTestChatbot(t, site.URL, ollama.URL))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/scrape", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var got ScrapeSummaryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	want := ScrapeSummaryResponse{URLsProcessed: 3, Succeeded: 2, Failed: 1}
+	if got != want {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	site := newTestSite(t)
+	ollama := newFakeOllama(t, "Jane builds distributed systems.", "codellama:13b")
+	c := newTestChatbot(t, site.URL, ollama.URL)
+	if err := c.refreshWebsiteData(context.Background()); err != nil {
+		t.Fatalf("refreshWebsiteData: %v", err)
+	}
+	router := newTestRouter(c)
+
+	tests := []struct {
+		name            string
+		query           string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"markdown", "", http.StatusOK, "text/markdown; charset=utf-8", "# Jane Doe\n\n- URL: " + site.URL},
+		{"json", "?format=json&url=" + site.URL, http.StatusOK, "application/json", `"Title":"Jane Doe"`},
+		{"unknown format", "?format=pdf", http.StatusBadRequest, "application/json", "Unsupported format"},
+		{"unknown URL", "?url=https://unknown.example/page", http.StatusNotFound, "application/json", "No scraped content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", "/export"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body doesn't contain %q:\n%s", tt.wantBody, rec.Body)
+			}
+		})
+	}
+
+	// Looking up an unknown URL leaves no trace in the cache directory
+	if _, err := os.Stat(filepath.Dir(c.scraper.getContentFilePath("https://unknown.example/page"))); !os.IsNotExist(err) {
+		t.Errorf("export of an unknown URL created its cache directory: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// persistedSession is the on-disk form of a chat session, so conversations survive restarts
+type persistedSession struct {
+	ID         string     `json:"id"`
+	Started    time.Time  `json:"started"`
+	LastActive time.Time  `json:"last_active"`
+	Turns      []exchange `json:"turns"`
+}
+
+// persist saves sessions to dir from now on, expiring them ttl after they started, and loads the
+// sessions saved there before that haven't expired
+func (s *conversationStore) persist(dir string, ttl time.Duration) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read sessions directory: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dir = dir
+	s.ttl = ttl
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logWarning("session_load_failed", "Could not read saved session", "path", path, "error", err)
+			continue
+		}
+		var saved persistedSession
+		if err := json.Unmarshal(data, &saved); err != nil || saved.ID == "" {
+			logWarning("session_load_failed", "Ignoring unreadable saved session", "path", path, "error", err)
+			continue
+		}
+
+		turns := saved.Turns
+		if len(turns) > s.maxTurns {
+			turns = turns[len(turns)-s.maxTurns:]
+		}
+		session := &conversation{
+			turns:      append(make([]exchange, 0, s.maxTurns), turns...),
+			started:    saved.Started,
+			lastActive: saved.LastActive,
+		}
+		if len(turns) == 0 || s.expired(session, now) {
+			os.Remove(path)
+			continue
+		}
+		s.sessions[saved.ID] = session
+	}
+
+	// Keep the most recently active sessions if more were saved than are kept
+	for len(s.sessions) > s.maxSessions {
+		leastActive := ""
+		for id, session := range s.sessions {
+			if leastActive == "" || session.lastActive.Before(s.sessions[leastActive].lastActive) {
+				leastActive = id
+			}
+		}
+		s.remove(leastActive)
+	}
+	return nil
+}
+
+// sessionFilePath names a session's file by the hash of its ID, since IDs come from clients
+func (s *conversationStore) sessionFilePath(sessionID string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%x.json", sha256.Sum256([]byte(sessionID))))
+}
+
+// save writes a session to disk when sessions are persisted; the caller holds mu
+func (s *conversationStore) save(sessionID string, session *conversation) {
+	if s.dir == "" {
+		return
+	}
+	data, err := json.Marshal(persistedSession{
+		ID:         sessionID,
+		Started:    session.started,
+		LastActive: session.lastActive,
+		Turns:      session.ordered(),
+	})
+	if err == nil {
+		err = os.WriteFile(s.sessionFilePath(sessionID), data, 0600)
+	}
+	if err != nil {
+		logWarning("session_save_failed", "Could not save session", "error", err)
+	}
+}
+
+// deleteSaved removes a session's file when sessions are persisted; the caller holds mu
+func (s *conversationStore) deleteSaved(sessionID string) {
+	if s.dir == "" {
+		return
+	}
+	if err := os.Remove(s.sessionFilePath(sessionID)); err != nil && !os.IsNotExist(err) {
+		logWarning("session_save_failed", "Could not delete saved session", "error", err)
+	}
+}
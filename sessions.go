@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHistoryAnswerLength bounds how much of each earlier answer is repeated in the prompt
+const maxHistoryAnswerLength = 500
+
+// conversationStore keeps the last exchanges of each chat session for follow-up questions.
+// Sessions idle for longer than idleTimeout are evicted when a message is recorded. Session IDs
+// come from clients, so at most maxSessions are kept, dropping the least recently active.
+type conversationStore struct {
+	mu          sync.Mutex
+	maxTurns    int // 0 disables history
+	maxSessions int
+	idleTimeout time.Duration
+	ttl         time.Duration // Age at which a session expires however active it is, 0 for none
+	dir         string        // Directory sessions are persisted to, see PERSIST_SESSIONS; "" keeps them in memory only
+	sessions    map[string]*conversation
+}
+
+// exchange is a question and its answer; only the text is kept, not the stats and snippets
+type exchange struct {
+	Message  string `json:"message"`
+	Response string `json:"response"`
+}
+
+// conversation is a ring buffer of a session's last exchanges: once full, each new exchange
+// overwrites the oldest at next
+type conversation struct {
+	turns      []exchange
+	next       int
+	started    time.Time
+	lastActive time.Time
+}
+
+// ordered returns a copy of the exchanges, oldest first
+func (c *conversation) ordered() []exchange {
+	ordered := make([]exchange, 0, len(c.turns))
+	ordered = append(ordered, c.turns[c.next:]...)
+	return append(ordered, c.turns[:c.next]...)
+}
+
+func newConversationStore(maxTurns, maxSessions int, idleTimeout time.Duration) *conversationStore {
+	return &conversationStore{
+		maxTurns:    maxTurns,
+		maxSessions: maxSessions,
+		idleTimeout: idleTimeout,
+		sessions:    make(map[string]*conversation),
+	}
+}
+
+// history returns a copy of the recorded exchanges of a session, oldest first
+func (s *conversationStore) history(sessionID string) []exchange {
+	if sessionID == "" || s.maxTurns <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, exists := s.sessions[sessionID]
+	if !exists || s.expired(session, time.Now()) {
+		return nil
+	}
+	return session.ordered()
+}
+
+// record adds an exchange to a session, replacing the oldest once it has maxTurns
+func (s *conversationStore) record(sessionID, message, response string) {
+	if sessionID == "" || s.maxTurns <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	leastActive := ""
+	for id, session := range s.sessions {
+		if s.expired(session, now) {
+			s.remove(id)
+		} else if leastActive == "" || session.lastActive.Before(s.sessions[leastActive].lastActive) {
+			leastActive = id
+		}
+	}
+
+	session, exists := s.sessions[sessionID]
+	if !exists {
+		if len(s.sessions) >= s.maxSessions {
+			s.remove(leastActive)
+		}
+		session = &conversation{turns: make([]exchange, 0, s.maxTurns), started: now}
+		s.sessions[sessionID] = session
+	}
+	turn := exchange{Message: message, Response: response}
+	if len(session.turns) < s.maxTurns {
+		session.turns = append(session.turns, turn)
+	} else {
+		session.turns[session.next] = turn
+		session.next = (session.next + 1) % s.maxTurns
+	}
+	session.lastActive = now
+	s.save(sessionID, session)
+}
+
+// expired reports whether a session has been idle for idleTimeout or is older than ttl
+func (s *conversationStore) expired(session *conversation, now time.Time) bool {
+	return now.Sub(session.lastActive) >= s.idleTimeout || (s.ttl > 0 && now.Sub(session.started) >= s.ttl)
+}
+
+// remove drops a session along with its persisted copy; the caller holds mu
+func (s *conversationStore) remove(sessionID string) {
+	delete(s.sessions, sessionID)
+	s.deleteSaved(sessionID)
+}
+
+// formatConversation renders earlier exchanges for the prompt, shortening long answers
+func formatConversation(history []exchange) string {
+	var builder strings.Builder
+	for _, turn := range history {
+		answer := turn.Response
+		if len(answer) > maxHistoryAnswerLength {
+			answer = answer[:maxHistoryAnswerLength] + "..."
+		}
+		builder.WriteString(fmt.Sprintf("User: %s\nAssistant: %s\n\n", turn.Message, answer))
+	}
+	return builder.String()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func historyMessages(history []exchange) []string {
+	var texts []string
+	for _, turn := range history {
+		texts = append(texts, turn.Message)
+	}
+	return texts
+}
+
+func TestConversationStoreWrapsAround(t *testing.T) {
+	store := newConversationStore(3, 10, time.Hour)
+	for i := 1; i <= 7; i++ {
+		store.record("s", fmt.Sprintf("q%d", i), "")
+		want := []string{}
+		for j := max(1, i-2); j <= i; j++ {
+			want = append(want, fmt.Sprintf("q%d", j))
+		}
+		if got := historyMessages(store.history("s")); !reflect.DeepEqual(got, want) {
+			t.Errorf("after %d messages history = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestConversationStoreSessions(t *testing.T) {
+	store := newConversationStore(6, 10, time.Minute)
+	store.record("a", "from a", "")
+	store.record("b", "from b", "")
+	store.record("", "anonymous", "")
+
+	if got := historyMessages(store.history("a")); !reflect.DeepEqual(got, []string{"from a"}) {
+		t.Errorf("history(a) = %v", got)
+	}
+	if got := store.history(""); got != nil {
+		t.Errorf("anonymous messages were recorded: %v", historyMessages(got))
+	}
+
+	// An idle session is forgotten, and evicted when another session records a message
+	store.sessions["b"].lastActive = time.Now().Add(-2 * time.Minute)
+	if got := store.history("b"); got != nil {
+		t.Errorf("idle session history = %v, want none", historyMessages(got))
+	}
+	store.record("a", "again", "")
+	if _, exists := store.sessions["b"]; exists {
+		t.Error("idle session was not evicted")
+	}
+}
+
+func TestConversationStoreDisabled(t *testing.T) {
+	store := newConversationStore(0, 10, time.Hour)
+	store.record("s", "q", "")
+	if got := store.history("s"); got != nil {
+		t.Errorf("history with MAX_HISTORY_TURNS=0 = %v, want none", historyMessages(got))
+	}
+}
+
+func TestConversationStoreEvictsLeastActiveSession(t *testing.T) {
+	store := newConversationStore(6, 3, time.Hour)
+	for _, id := range []string{"a", "b", "c"} {
+		store.record(id, "from "+id, "answer")
+	}
+	store.sessions["a"].lastActive = time.Now().Add(-time.Minute)
+	store.sessions["b"].lastActive = time.Now().Add(-2 * time.Minute)
+
+	store.record("a", "again", "answer") // An existing session doesn't evict another
+	if len(store.sessions) != 3 {
+		t.Fatalf("%d sessions after a known session's message, want 3", len(store.sessions))
+	}
+	store.record("d", "from d", "answer")
+	if _, exists := store.sessions["b"]; exists {
+		t.Error("least recently active session was not evicted")
+	}
+	if got := historyMessages(store.history("a")); !reflect.DeepEqual(got, []string{"from a", "again"}) {
+		t.Errorf("history(a) = %v", got)
+	}
+	if len(store.sessions) != 3 {
+		t.Errorf("%d sessions, want at most 3", len(store.sessions))
+	}
+}
+
+func TestConversationStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	store := newConversationStore(2, 10, time.Hour)
+	if err := store.persist(dir, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		store.record("a", fmt.Sprintf("q%d", i), fmt.Sprintf("a%d", i))
+	}
+	store.record("b", "from b", "answer")
+	store.record("old", "from old", "answer")
+	store.sessions["old"].started = time.Now().Add(-25 * time.Hour)
+	store.save("old", store.sessions["old"])
+
+	restarted := newConversationStore(2, 10, time.Hour)
+	if err := restarted.persist(dir, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.history("a"); !reflect.DeepEqual(got, []exchange{{"q2", "a2"}, {"q3", "a3"}}) {
+		t.Errorf("reloaded history(a) = %v", got)
+	}
+	if got := historyMessages(restarted.history("b")); !reflect.DeepEqual(got, []string{"from b"}) {
+		t.Errorf("reloaded history(b) = %v", got)
+	}
+	if _, exists := restarted.sessions["old"]; exists {
+		t.Error("session older than the TTL was reloaded")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("%d session files after reloading, want 2", len(files))
+	}
+
+	// Reloaded sessions keep recording in order, and evicted ones are deleted from disk
+	restarted.record("a", "q4", "a4")
+	if got := historyMessages(restarted.history("a")); !reflect.DeepEqual(got, []string{"q3", "q4"}) {
+		t.Errorf("history(a) after reloading = %v", got)
+	}
+	restarted.sessions["b"].lastActive = time.Now().Add(-2 * time.Hour)
+	restarted.record("a", "q5", "a5")
+	if _, err := os.Stat(restarted.sessionFilePath("b")); !os.IsNotExist(err) {
+		t.Errorf("idle session's file is still there: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxSitemapFiles bounds how many sitemaps are read through nested sitemap indexes
+const maxSitemapFiles = 20
+
+// maxSitemapSize is the largest uncompressed sitemap the sitemap protocol allows (50MB)
+const maxSitemapSize = 50 << 20
+
+// sitemapDocument holds either a <urlset> of pages or a <sitemapindex> of further sitemaps
+type sitemapDocument struct {
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+// scrapeSitemapPages scrapes the pages listed in the site's /sitemap.xml that link following
+// didn't reach, as linked pages of the main page logged as "sitemap", within the URL allow-list
+// and the page limit
+func (w *WebScraper) scrapeSitemapPages(ctx context.Context, content *WebsiteContent, targetUrl string) {
+	if !w.canScrapeMore() || ctx.Err() != nil {
+		return
+	}
+
+	parsed, err := url.Parse(targetUrl)
+	if err != nil || parsed.Host == "" {
+		return
+	}
+	sitemapURL := (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/sitemap.xml"}).String()
+
+	var pageURLs []string
+	for _, pageURL := range w.fetchSitemapURLs(ctx, sitemapURL) {
+		if w.isUrlAllowed(pageURL) && w.isInScope(pageURL) && !w.isURLVisited(pageURL) {
+			pageURLs = append(pageURLs, pageURL)
+		}
+	}
+	if len(pageURLs) == 0 {
+		return
+	}
+	log.Printf("Sitemap lists %d pages not scraped yet", len(pageURLs))
+
+	// Scrape pages with up to scrapeWorkers workers
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, w.scrapeWorkers)
+	for _, pageURL := range pageURLs {
+		if !w.canScrapeMore() || ctx.Err() != nil {
+			break
+		}
+
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(pageURL string) {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			linkedContent, err := w.scrapeLinkedPageWithDepthAndContent(ctx, pageURL, 1, "sitemap", "", content)
+			if err == nil && linkedContent != nil {
+				w.addLinkedContent(content, pageURL, linkedContent)
+			}
+		}(pageURL)
+	}
+	wg.Wait()
+}
+
+// fetchSitemapURLs returns the page URLs of a sitemap, following sitemap indexes on the same host.
+// A missing or unreadable sitemap is logged and yields no URLs.
+func (w *WebScraper) fetchSitemapURLs(ctx context.Context, sitemapURL string) []string {
+	var pageURLs []string
+	seenPages := make(map[string]bool)
+	seenSitemaps := map[string]bool{sitemapURL: true}
+	queue := []string{sitemapURL}
+
+	for read := 0; len(queue) > 0 && read < maxSitemapFiles && ctx.Err() == nil; read++ {
+		current := queue[0]
+		queue = queue[1:]
+
+		sitemap, err := w.fetchSitemap(ctx, current)
+		if err != nil {
+			log.Printf("Skipping sitemap %s: %v", current, err)
+			continue
+		}
+
+		for _, entry := range sitemap.Sitemaps {
+			loc := strings.TrimSpace(entry.Loc)
+			if loc == "" {
+				continue
+			}
+			nested := w.resolveURL(current, loc)
+			if !seenSitemaps[nested] && w.isSameDomain(nested, sitemapURL) {
+				seenSitemaps[nested] = true
+				queue = append(queue, nested)
+			}
+		}
+		for _, entry := range sitemap.URLs {
+			loc := strings.TrimSpace(entry.Loc)
+			if loc == "" {
+				continue
+			}
+			pageURL := w.resolveURL(current, loc)
+			normalized := w.normalizeURL(pageURL)
+			if !seenPages[normalized] {
+				seenPages[normalized] = true
+				pageURLs = append(pageURLs, pageURL)
+			}
+		}
+	}
+
+	return pageURLs
+}
+
+// fetchSitemap downloads and parses one sitemap, decompressing gzipped sitemaps (sitemap.xml.gz)
+func (w *WebScraper) fetchSitemap(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.fetchPage(w.client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	limited, err := limitBody(resp, sitemapURL, w.maxDownloadBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect gzip by its magic bytes, since servers label .gz sitemaps inconsistently
+	body := bufio.NewReader(limited)
+	var reader io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	var sitemap sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(reader, maxSitemapSize)).Decode(&sitemap); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %w", err)
+	}
+	return &sitemap, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func urlset(locs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, loc := range locs {
+		fmt.Fprintf(&b, "<url><loc>%s</loc></url>", loc)
+	}
+	b.WriteString("</urlset>")
+	return b.String()
+}
+
+func TestSitemapPagesAreScraped(t *testing.T) {
+	pages := []string{"/about", "/projects", "/contact"}
+
+	tests := []struct {
+		name     string
+		sitemaps func(t *testing.T) map[string][]byte // Sitemap files by path
+	}{
+		{
+			name: "urlset",
+			sitemaps: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{"/sitemap.xml": []byte(urlset(pages...))}
+			},
+		},
+		{
+			name: "gzipped",
+			sitemaps: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{"/sitemap.xml": gzipBytes(t, urlset(pages...))}
+			},
+		},
+		{
+			name: "nested index",
+			sitemaps: func(t *testing.T) map[string][]byte {
+				return map[string][]byte{
+					"/sitemap.xml": []byte(`<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+						`<sitemap><loc>/sitemap-pages.xml.gz</loc></sitemap><sitemap><loc>/sitemap-contact.xml</loc></sitemap></sitemapindex>`),
+					"/sitemap-pages.xml.gz": gzipBytes(t, urlset(pages[:2]...)),
+					"/sitemap-contact.xml":  []byte(urlset(pages[2])),
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sitemaps := tt.sitemaps(t)
+			var mu sync.Mutex
+			fetched := make(map[string]int)
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if sitemap, ok := sitemaps[r.URL.Path]; ok {
+					rw.Header().Set("Content-Type", "application/xml")
+					rw.Write(sitemap)
+					return
+				}
+				mu.Lock()
+				fetched[r.URL.Path]++
+				mu.Unlock()
+				// The home page links nowhere, so the other pages are only found through the sitemap
+				fmt.Fprintf(rw, "<html><head><title>%s</title></head><body><p>Content of %s</p></body></html>", r.URL.Path, r.URL.Path)
+			}))
+			defer server.Close()
+
+			t.Setenv("ENABLE_SITEMAP", "true")
+			t.Setenv("PERSIST_LINKED_PAGES", "false")
+			w := newTestScraper(t)
+			content, err := w.ScrapeWebsite(server.URL + "/")
+			if err != nil {
+				t.Fatalf("ScrapeWebsite: %v", err)
+			}
+
+			for _, page := range pages {
+				if fetched[page] != 1 {
+					t.Errorf("%s fetched %d times, want once", page, fetched[page])
+				}
+				linked, ok := content.LinkedContent[server.URL+page]
+				if !ok {
+					t.Errorf("%s missing from the linked pages", page)
+				} else if !strings.Contains(linked.Text, "Content of "+page) {
+					t.Errorf("%s text = %q", page, linked.Text)
+				}
+			}
+			var logged int
+			for _, scraped := range w.GetScrapedUrls() {
+				if scraped.Type == "sitemap" && scraped.Success {
+					logged++
+				}
+			}
+			if logged != len(pages) {
+				t.Errorf("logged %d sitemap pages, want %d", logged, len(pages))
+			}
+		})
+	}
+}
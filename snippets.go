@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSnippetLength bounds the text of one returned snippet
+const maxSnippetLength = 300
+
+// minSnippetLength skips lines too short to be a useful passage, like menu items
+const minSnippetLength = 20
+
+// snippetStopWords are question words that would match almost any passage
+var snippetStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "what": true, "which": true,
+	"who": true, "how": true, "does": true, "did": true, "has": true, "have": true, "with": true,
+	"from": true, "about": true, "this": true, "that": true, "his": true, "her": true, "their": true,
+	"you": true, "your": true, "can": true, "tell": true, "any": true, "there": true, "where": true,
+}
+
+// Snippet is a passage of scraped content that matched the question
+type Snippet struct {
+	Source string `json:"source"` // URL of the page or document the passage comes from
+	Text   string `json:"text"`
+	Score  int    `json:"score"` // Number of distinct question words found in the passage
+}
+
+// selectSnippets returns up to limit passages of the content that share the most words with the
+// question, best first. Passages are the lines of the main page, linked pages, PDFs and files.
+func selectSnippets(content *WebsiteContent, mainURL, question string, limit int) []Snippet {
+	if content == nil || limit <= 0 {
+		return nil
+	}
+
+	terms := questionTerms(question)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var snippets []Snippet
+	collect := func(source, text string) {
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) < minSnippetLength {
+				continue
+			}
+
+			// Pad words with spaces so "skill" matches "skills" but "file" doesn't match "profile"
+			padded := " " + nonWordChars.ReplaceAllString(strings.ToLower(line), " ")
+			score := 0
+			for _, term := range terms {
+				if strings.Contains(padded, " "+term) {
+					score++
+				}
+			}
+			if score > 0 {
+				snippets = append(snippets, Snippet{Source: source, Text: truncateSnippet(line), Score: score})
+			}
+		}
+	}
+
+	collect(mainURL, content.Text)
+	for _, pageURL := range sortedKeys(content.LinkedContent) {
+		collect(pageURL, content.LinkedContent[pageURL].Text)
+	}
+	for _, pdfURL := range sortedKeys(content.PDFContent) {
+		collect(pdfURL, content.PDFContent[pdfURL].Text)
+	}
+	for _, fileURL := range sortedKeys(content.FileContent) {
+		collect(fileURL, content.FileContent[fileURL].Text)
+	}
+
+	// Prefer more matched words, then shorter passages that are more focused on them
+	sort.SliceStable(snippets, func(i, j int) bool {
+		if snippets[i].Score != snippets[j].Score {
+			return snippets[i].Score > snippets[j].Score
+		}
+		return len(snippets[i].Text) < len(snippets[j].Text)
+	})
+
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets
+}
+
+// questionTerms returns the distinct lowercase words of a question worth matching against content
+func questionTerms(question string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(nonWordChars.ReplaceAllString(strings.ToLower(question), " ")) {
+		if len(word) < 3 || snippetStopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// truncateSnippet cuts a passage to maxSnippetLength at a word boundary
+func truncateSnippet(text string) string {
+	if len(text) <= maxSnippetLength {
+		return text
+	}
+	cut := text[:maxSnippetLength]
+	if space := strings.LastIndex(cut, " "); space > maxSnippetLength/2 {
+		cut = cut[:space]
+	}
+	return cut + "..."
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ServiceStats is a snapshot of the lifetime counters reported by GET /stats
+type ServiceStats struct {
+	Uptime               string  `json:"uptime"`
+	ChatsHandled         int64   `json:"chats_handled"`
+	ChatsRejected        int64   `json:"chats_rejected"` // Turned away by MAX_CONCURRENT_CHATS
+	LLMAnswers           int64   `json:"llm_answers"`
+	FallbackAnswers      int64   `json:"fallback_answers"`
+	OllamaFailures       int64   `json:"ollama_failures"`
+	AverageChatLatencyMs float64 `json:"average_chat_latency_ms"`
+	PagesScraped         int64   `json:"pages_scraped"`
+	CacheHits            int64   `json:"cache_hits"`
+	CacheMisses          int64   `json:"cache_misses"`
+	CacheHitRate         float64 `json:"cache_hit_rate"` // 0-1, over page and document cache lookups
+}
+
+// collectStats reads the counters kept by the server, chatbot and scraper
+func (s *Server) collectStats() ServiceStats {
+	stats := ServiceStats{
+		Uptime:          time.Since(s.startedAt).Round(time.Second).String(),
+		ChatsHandled:    s.chatsHandled.Load(),
+		ChatsRejected:   s.chatsRejected.Load(),
+		LLMAnswers:      s.chatbot.llmAnswers.Load(),
+		FallbackAnswers: s.chatbot.fallbackAnswers.Load(),
+		OllamaFailures:  s.chatbot.ollamaFailures.Load(),
+		PagesScraped:    s.chatbot.scraper.pagesScraped.Load(),
+		CacheHits:       s.chatbot.scraper.cacheHits.Load(),
+		CacheMisses:     s.chatbot.scraper.cacheMisses.Load(),
+	}
+
+	if stats.ChatsHandled > 0 {
+		stats.AverageChatLatencyMs = float64(s.chatLatencyMicros.Load()) / float64(stats.ChatsHandled) / 1000
+	}
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.CacheHitRate = float64(stats.CacheHits) / float64(lookups)
+	}
+	return stats
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(s.collectStats()); err != nil {
+		log.Printf("Error encoding stats response: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxStructuredDataLength bounds the JSON-LD included in the prompt
+const maxStructuredDataLength = 4000
+
+// extractStructuredData parses the page's JSON-LD blocks and merges their entities by @type, e.g.
+// {"Person": {...}, "WebSite": {...}}. Entities of a type that occurs more than once are collected
+// in a list, @graph containers are flattened, and blocks that aren't valid JSON are skipped.
+func (w *WebScraper) extractStructuredData(doc *goquery.Document) map[string]interface{} {
+	merged := make(map[string]interface{})
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var block interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &block); err != nil {
+			return
+		}
+		for _, entity := range jsonLDEntities(block) {
+			delete(entity, "@context")
+			entityType := jsonLDType(entity)
+			switch existing := merged[entityType].(type) {
+			case nil:
+				merged[entityType] = entity
+			case []interface{}:
+				merged[entityType] = append(existing, entity)
+			default:
+				merged[entityType] = []interface{}{existing, entity}
+			}
+		}
+	})
+
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// jsonLDEntities returns the top-level objects of a JSON-LD block, which may be a single object,
+// an array of objects or an object with an @graph array
+func jsonLDEntities(block interface{}) []map[string]interface{} {
+	var entities []map[string]interface{}
+	switch value := block.(type) {
+	case []interface{}:
+		for _, item := range value {
+			entities = append(entities, jsonLDEntities(item)...)
+		}
+	case map[string]interface{}:
+		if graph, ok := value["@graph"]; ok {
+			return jsonLDEntities(graph)
+		}
+		entities = append(entities, value)
+	}
+	return entities
+}
+
+// jsonLDType returns an entity's @type, the first one if it has several, or "Thing"
+func jsonLDType(entity map[string]interface{}) string {
+	switch value := entity["@type"].(type) {
+	case string:
+		return value
+	case []interface{}:
+		if len(value) > 0 {
+			if first, ok := value[0].(string); ok {
+				return first
+			}
+		}
+	}
+	return "Thing"
+}
+
+// formatStructuredData renders merged JSON-LD as indented JSON for the prompt, cut to maxStructuredDataLength
+func formatStructuredData(data map[string]interface{}) string {
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return ""
+	}
+	text := string(encoded)
+	if len(text) > maxStructuredDataLength {
+		text = text[:maxStructuredDataLength] + "\n..."
+	}
+	return text
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScrapeWarning is a warning raised during a scraping session, as listed by GET /scrape/log
+type ScrapeWarning struct {
+	Event   string            `json:"event"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Time    time.Time         `json:"time"`
+}
+
+var (
+	warningLoggerOnce sync.Once
+	warningLogger     *slog.Logger // Set when STRUCTURED_WARNINGS is enabled
+)
+
+// logWarning reports a problem worth monitoring. event is a stable snake_case name to alert on and
+// fields are alternating keys and values, as with slog. With STRUCTURED_WARNINGS=true the warning is
+// a JSON record at WARN level on stderr; otherwise it is printed as a "Warning: ..." line.
+func logWarning(event, message string, fields ...any) {
+	warningLoggerOnce.Do(func() {
+		// Check if warnings should be emitted as structured JSON events (default: false)
+		if strings.ToLower(os.Getenv("STRUCTURED_WARNINGS")) == "true" {
+			warningLogger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+		}
+	})
+
+	if warningLogger != nil {
+		warningLogger.Warn(message, append([]any{"event", event}, fields...)...)
+		return
+	}
+
+	var line strings.Builder
+	line.WriteString("Warning: " + message)
+	for i := 0; i+1 < len(fields); i += 2 {
+		line.WriteString(fmt.Sprintf(" %v=%v", fields[i], fields[i+1]))
+	}
+	fmt.Println(line.String())
+}
+
+// warn logs a warning and, with COLLECT_SCRAPE_WARNINGS, adds it to the warnings of the current
+// scraping session, keeping the last maxScrapeLogEntries
+func (w *WebScraper) warn(event, message string, fields ...any) {
+	logWarning(event, message, fields...)
+	if !w.collectWarnings {
+		return
+	}
+
+	warning := ScrapeWarning{Event: event, Message: message, Time: time.Now()}
+	if len(fields) > 1 {
+		warning.Fields = make(map[string]string, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			warning.Fields[fmt.Sprint(fields[i])] = fmt.Sprint(fields[i+1])
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, warning)
+	if w.maxScrapeLogEntries > 0 && len(w.warnings) > w.maxScrapeLogEntries {
+		w.warnings = w.warnings[len(w.warnings)-w.maxScrapeLogEntries:]
+	}
+}
+
+// GetWarnings returns a copy of the warnings of the current scraping session
+func (w *WebScraper) GetWarnings() []ScrapeWarning {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]ScrapeWarning(nil), w.warnings...)
+}